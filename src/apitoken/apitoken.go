@@ -0,0 +1,73 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"strings"
+	"time"
+)
+
+// Prefix 令牌的前缀，便于在日志和配置中识别
+const Prefix = "am_"
+
+var ErrInvalidToken = fmt.Errorf("invalid api token")
+
+// New 创建一个令牌，数据库中只保存其哈希，令牌明文只在创建时返回一次
+func New(name string) (string, error) {
+	if !database.Ready() {
+		return "", fmt.Errorf("sqlite is not enabled")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("token name is empty")
+	} else if len(name) > 40 {
+		return "", fmt.Errorf("token name is too long")
+	}
+
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	token := Prefix + hex.EncodeToString(buf)
+
+	err = database.SaveAPIToken(name, Hash(token), time.Now())
+	if err != nil {
+		return "", fmt.Errorf("save token %s failed (the name may already exist): %s", name, err.Error())
+	}
+
+	return token, nil
+}
+
+// Check 校验令牌并记录最后使用时间
+func Check(token string) (*database.APIToken, error) {
+	if !strings.HasPrefix(token, Prefix) {
+		return nil, ErrInvalidToken
+	}
+
+	record, err := database.FindAPIToken(Hash(token))
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	_ = database.UpdateAPITokenUsed(record.ID, time.Now())
+	return record, nil
+}
+
+func Hash(token string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(token))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
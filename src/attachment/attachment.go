@@ -0,0 +1,196 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package attachment
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxNameLength 保存到磁盘时文件名的最大长度（字节）
+const MaxNameLength = 100
+
+// File 从邮件中取出的附件，Data 为空表示附件超过 --attachment-max-size，只记录名字和大小
+type File struct {
+	Name        string
+	ContentType string
+	Size        int64
+	Data        []byte
+}
+
+func InitAttachment() error {
+	if flagparser.RawMail && !database.Ready() {
+		return fmt.Errorf("raw mail requires sqlite")
+	} else if flagparser.RawMailAttach && !flagparser.RawMail {
+		return fmt.Errorf("raw mail attach requires raw mail")
+	}
+
+	if !Enabled() {
+		return nil
+	} else if !database.Ready() {
+		return fmt.Errorf("attachment path requires sqlite")
+	} else if flagparser.AttachmentMaxSize <= 0 {
+		return fmt.Errorf("attachment max size must be positive")
+	}
+
+	err := os.MkdirAll(flagparser.AttachmentPath, 0750)
+	if err != nil {
+		return fmt.Errorf("create attachment path %s: %s", flagparser.AttachmentPath, err.Error())
+	}
+
+	return nil
+}
+
+// Enabled 没有设置 --attachment-path 时不保存附件
+func Enabled() bool {
+	return flagparser.AttachmentPath != ""
+}
+
+// MaxSize 单个附件保存的最大字节数
+func MaxSize() int64 {
+	return int64(flagparser.AttachmentMaxSize) * 1024
+}
+
+// Save 把附件写入以信件ID命名的目录并记录到数据库，返回通知中使用的附件列表
+func Save(mailID string, files []File, t time.Time) ([]notifier.Attachment, error) {
+	if !Enabled() || len(files) == 0 {
+		return nil, nil
+	}
+
+	dir := filepath.Join(flagparser.AttachmentPath, mailID)
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]database.Attachment, 0, len(files))
+	for i, f := range files {
+		record := database.Attachment{
+			MailID:      mailID,
+			Name:        f.Name,
+			ContentType: f.ContentType,
+			Size:        f.Size,
+			Time:        t,
+		}
+
+		if f.Data != nil {
+			record.Path = filepath.Join(mailID, fileName(i+1, f.Name))
+
+			err = os.WriteFile(filepath.Join(flagparser.AttachmentPath, record.Path), f.Data, 0640)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	err = database.SaveAttachment(records)
+	if err != nil {
+		return nil, err
+	}
+
+	return toNotifier(records), nil
+}
+
+// SaveOriginal 保存原始邮件，没有设置 --raw-mail 时不保存
+func SaveOriginal(mailID string, raw []byte, t time.Time) error {
+	if !flagparser.RawMail {
+		return nil
+	}
+
+	return database.SaveRawMail(mailID, raw, t)
+}
+
+// Original 信件的原始邮件，没有保存时返回 database.ErrNotFound
+func Original(mailID string) ([]byte, error) {
+	return database.FindRawMail(mailID)
+}
+
+// Find 信件已经保存的附件，供重新投递通知使用
+func Find(mailID string) ([]notifier.Attachment, error) {
+	records, err := database.FindAttachment(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toNotifier(records), nil
+}
+
+func toNotifier(records []database.Attachment) []notifier.Attachment {
+	res := make([]notifier.Attachment, 0, len(records))
+	for _, r := range records {
+		a := notifier.Attachment{
+			Name:        r.Name,
+			ContentType: r.ContentType,
+			Size:        r.Size,
+		}
+
+		if r.Path != "" {
+			a.Path = filepath.Join(flagparser.AttachmentPath, r.Path)
+		}
+
+		res = append(res, a)
+	}
+	return res
+}
+
+// Describe 附件列表的说明，用于通知中的“附件”一栏
+func Describe(list []notifier.Attachment) string {
+	res := make([]string, 0, len(list))
+	for _, a := range list {
+		if a.Path == "" {
+			res = append(res, fmt.Sprintf("%s（%s，太大未保存）", a.Name, FormatSize(a.Size)))
+		} else {
+			res = append(res, fmt.Sprintf("%s（%s）", a.Name, FormatSize(a.Size)))
+		}
+	}
+	return strings.Join(res, "、")
+}
+
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMiB", float64(size)/1024/1024)
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKiB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
+
+// fileName 磁盘上的文件名：序号加上去掉路径和控制字符的原文件名，避免重名和路径穿越
+func fileName(index int, name string) string {
+	name, _ = utils.ChangeDisplaySafeUTF8(name)
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), ".")
+
+	for len(name) > MaxNameLength {
+		_, size := utf8.DecodeRuneInString(name)
+		name = name[size:] // 保留扩展名
+	}
+
+	if name == "" {
+		name = "attachment"
+	}
+
+	return fmt.Sprintf("%02d-%s", index, name)
+}
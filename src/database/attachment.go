@@ -0,0 +1,23 @@
+package database
+
+func SaveAttachment(list []Attachment) error {
+	if db == nil || len(list) == 0 {
+		return nil
+	}
+
+	return db.Create(&list).Error
+}
+
+func FindAttachment(mailID string) ([]Attachment, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []Attachment
+	err := db.Model(&Attachment{}).Where("mail_id = ?", mailID).Order("id asc").Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
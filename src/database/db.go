@@ -32,7 +32,7 @@ func SaveMailRecord(MailID string, MailType MsgType) error {
 	return nil
 }
 
-func SaveAMMail(mailID string, name string, email string, content string, refer string, origin string, host string, clientIP string, t time.Time) error {
+func SaveAMMail(siteID string, mailID string, name string, email string, content string, refer string, origin string, host string, clientIP string, t time.Time) error {
 	if db == nil {
 		return nil
 	}
@@ -42,6 +42,7 @@ func SaveAMMail(mailID string, name string, email string, content string, refer
 	}
 
 	mail := &AMMail{
+		SiteID:  siteID,
 		MailID:  mailID,
 		Name:    name,
 		Email:   email,
@@ -66,7 +67,7 @@ func SaveAMMail(mailID string, name string, email string, content string, refer
 	return nil
 }
 
-func SaveIMAPMail(mailID string, messageID string, sender string, from string, to string, replyTo string, subject string, content string, date time.Time, t time.Time) error {
+func SaveIMAPMail(siteID string, mailID string, messageID string, sender string, from string, to string, replyTo string, subject string, content string, spf string, dkim string, dmarc string, date time.Time, t time.Time) error {
 	if db == nil {
 		return nil
 	}
@@ -100,6 +101,7 @@ func SaveIMAPMail(mailID string, messageID string, sender string, from string, t
 	}
 
 	mail := &IMAPMail{
+		SiteID:     siteID,
 		MailID:     mailID,
 		MessageID:  messageID,
 		Sender:     sender,
@@ -108,6 +110,9 @@ func SaveIMAPMail(mailID string, messageID string, sender string, from string, t
 		ReplyTo:    replyTo,
 		Subject:    subject,
 		Content:    content,
+		SPF:        spf,
+		DKIM:       dkim,
+		DMARC:      dmarc,
 		SendTime:   date,
 		Time:       t,
 		Version:    resource.Version,
@@ -383,6 +388,10 @@ func UpdateIMAPThankEmailSendMsg(mailID string, smtpID string) error {
 }
 
 func FindIMAPMessageID(messageID string) (*IMAPMail, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
 	var mail IMAPMail
 	err := db.Model(&IMAPMail{}).Where("message_id = ?", messageID).Order("time desc").First(&mail).Error
 	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
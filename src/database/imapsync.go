@@ -0,0 +1,46 @@
+package database
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+func FindIMAPSyncState(account string, mailbox string) (*IMAPSyncState, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var state IMAPSyncState
+	err := db.Model(&IMAPSyncState{}).Where("account = ? AND mailbox = ?", account, mailbox).First(&state).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// SaveIMAPSyncState 保存邮箱的 UIDVALIDITY 和最后处理的 UID，没有记录时新建
+func SaveIMAPSyncState(account string, mailbox string, uidValidity uint32, lastUID uint32, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	state, err := FindIMAPSyncState(account, mailbox)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	} else if state == nil {
+		state = &IMAPSyncState{
+			Account: account,
+			MailBox: mailbox,
+		}
+	}
+
+	state.UIDValidity = uidValidity
+	state.LastUID = lastUID
+	state.Time = t
+
+	return db.Save(state).Error
+}
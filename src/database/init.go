@@ -19,7 +19,14 @@ func InitSQLite() error {
 		return fmt.Errorf("connect to sqlite (%s) failed: %s", flagparser.SQLitePath, err)
 	}
 
-	err = _db.AutoMigrate(&MailRecord{}, &AMMail{}, &IMAPMail{}, &SystemNotifyMail{}, &WxRobotRecord{}, &SMTPRecord{}, &SMTPRecipientRecord{})
+	err = _db.AutoMigrate(&MailRecord{}, &AMMail{}, &IMAPMail{}, &SystemNotifyMail{}, &MailState{}, &MailAudit{}, &MailReply{}, &ReplyAlias{}, &Ticket{}, &POP3Message{}, &IMAPSyncState{}, &Attachment{}, &RawMail{}, &WxRobotRecord{}, &SMTPRecord{}, &SMTPRecipientRecord{}, &Outbox{}, &APIToken{})
+	if err != nil {
+		return fmt.Errorf("migrate sqlite (%s) failed: %s", flagparser.SQLitePath, err)
+	}
+
+	// 旧版本只记录了已读和归档时间
+	err = _db.Exec("UPDATE mail_state SET status = CASE WHEN archived_at IS NOT NULL THEN ? WHEN read_at IS NOT NULL THEN ? ELSE ? END WHERE status = ''",
+		MailStatusArchived, MailStatusRead, MailStatusNew).Error
 	if err != nil {
 		return fmt.Errorf("migrate sqlite (%s) failed: %s", flagparser.SQLitePath, err)
 	}
@@ -28,6 +35,10 @@ func InitSQLite() error {
 	return nil
 }
 
+func Ready() bool {
+	return db != nil
+}
+
 func CloseSQLite() {
 	if db == nil {
 		return
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"errors"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// MessageFilter 信件列表的查询条件，零值表示不限制
+type MessageFilter struct {
+	Type     MsgType
+	SiteID   *string // 系统留言属于默认站点
+	Since    time.Time
+	Until    time.Time
+	Email    string       // 模糊匹配，邮箱留言匹配发送人、宣称发送人和回复地址
+	IP       string       // 只有网页留言有IP
+	Keyword  string       // 模糊匹配名字、邮箱、主题和内容
+	Status   []MailStatus // 为空表示不限制
+	Assignee *string
+	Offset   int
+	Limit    int
+}
+
+// MessageSummary 信件列表中的一项
+type MessageSummary struct {
+	MailID   string
+	MailType MsgType
+	SiteID   string
+	Name     string
+	Email    string
+	IP       string
+	Subject  string
+	Time     time.Time
+	Status   MailStatus
+	Assignee string
+}
+
+// messageTable 查询信件列表时一种信件对应的表和列，列为空表示该种信件没有这一项
+type messageTable struct {
+	mailType MsgType
+	table    string
+	site     string
+	name     string
+	email    string
+	emails   []string // 用于模糊匹配邮箱的列
+	ip       string
+	subject  string
+}
+
+var messageTables = []messageTable{
+	{MsgTypeWebsite, (&AMMail{}).TableName(), "m.site_id", "m.name", "m.email", []string{"m.email"}, "m.ip", ""},
+	{MsgTypeEmail, (&IMAPMail{}).TableName(), "m.site_id", `m."from"`, "m.reply_to", []string{"m.sender", `m."from"`, "m.reply_to"}, "", "m.subject"},
+	{MsgTypeSystem, (&SystemNotifyMail{}).TableName(), "", "", "", nil, "", "m.subject"},
+}
+
+func columnOrEmpty(column string) string {
+	if column == "" {
+		return "''"
+	}
+	return column
+}
+
+// FindMessages 按条件查询三种信件，按时间倒序分页，同时返回符合条件的总数
+func FindMessages(filter MessageFilter) ([]MessageSummary, int64, error) {
+	if db == nil {
+		return nil, 0, nil
+	}
+
+	var parts []string
+	var args []any
+
+	for _, t := range messageTables {
+		if filter.Type != "" && filter.Type != t.mailType {
+			continue
+		} else if filter.Email != "" && len(t.emails) == 0 {
+			continue
+		} else if filter.IP != "" && t.ip == "" {
+			continue
+		} else if filter.SiteID != nil && t.site == "" && *filter.SiteID != flagparser.DefaultSiteID {
+			continue
+		}
+
+		where := []string{"m.deleted_at IS NULL"}
+		args = append(args, string(t.mailType))
+
+		if filter.SiteID != nil && t.site != "" {
+			where = append(where, t.site+" = ?")
+			args = append(args, *filter.SiteID)
+		}
+
+		if !filter.Since.IsZero() {
+			where = append(where, "m.time >= ?")
+			args = append(args, filter.Since.In(flagparser.TimeZone()))
+		}
+
+		if !filter.Until.IsZero() {
+			where = append(where, "m.time < ?")
+			args = append(args, filter.Until.In(flagparser.TimeZone()))
+		}
+
+		if filter.Email != "" {
+			like := make([]string, 0, len(t.emails))
+			for _, c := range t.emails {
+				like = append(like, c+" LIKE ?")
+				args = append(args, "%"+filter.Email+"%")
+			}
+			where = append(where, "("+strings.Join(like, " OR ")+")")
+		}
+
+		if filter.IP != "" {
+			where = append(where, t.ip+" = ?")
+			args = append(args, filter.IP)
+		}
+
+		if filter.Keyword != "" {
+			columns := []string{"m.content"}
+			for _, c := range []string{t.name, t.subject} {
+				if c != "" {
+					columns = append(columns, c)
+				}
+			}
+			columns = append(columns, t.emails...)
+
+			like := make([]string, 0, len(columns))
+			for _, c := range columns {
+				like = append(like, c+" LIKE ?")
+				args = append(args, "%"+filter.Keyword+"%")
+			}
+			where = append(where, "("+strings.Join(like, " OR ")+")")
+		}
+
+		if len(filter.Status) != 0 {
+			status := make([]string, 0, len(filter.Status))
+			for _, st := range filter.Status {
+				status = append(status, string(st))
+			}
+
+			where = append(where, "COALESCE(s.status, ?) IN ?")
+			args = append(args, string(MailStatusNew), status)
+		}
+
+		if filter.Assignee != nil {
+			where = append(where, "COALESCE(s.assignee, '') = ?")
+			args = append(args, *filter.Assignee)
+		}
+
+		parts = append(parts, "SELECT m.mail_id AS mail_id, ? AS mail_type, "+
+			columnOrEmpty(t.site)+" AS site_id, "+columnOrEmpty(t.name)+" AS name, "+columnOrEmpty(t.email)+" AS email, "+
+			columnOrEmpty(t.ip)+" AS ip, "+columnOrEmpty(t.subject)+" AS subject, m.time AS time, "+
+			"COALESCE(s.status, '"+string(MailStatusNew)+"') AS status, COALESCE(s.assignee, '') AS assignee "+
+			"FROM "+t.table+" AS m LEFT JOIN "+(&MailState{}).TableName()+" AS s ON s.mail_id = m.mail_id AND s.deleted_at IS NULL "+
+			"WHERE "+strings.Join(where, " AND "))
+	}
+
+	if len(parts) == 0 {
+		return nil, 0, nil
+	}
+
+	union := strings.Join(parts, " UNION ALL ")
+
+	var total int64
+	err := db.Raw("SELECT COUNT(*) FROM ("+union+")", args...).Scan(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // sqlite 中 -1 表示不限制
+	}
+
+	var res []MessageSummary
+	err = db.Raw("SELECT * FROM ("+union+") ORDER BY time DESC LIMIT ? OFFSET ?", append(args, limit, filter.Offset)...).Scan(&res).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
+
+func FindWxRobotRecord(wxrobotID string) (*WxRobotRecord, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var record WxRobotRecord
+	err := db.Model(&WxRobotRecord{}).Where("wxrobot_id = ?", wxrobotID).Order("time desc").First(&record).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func FindSMTPRecord(smtpID string) (*SMTPRecord, []SMTPRecipientRecord, error) {
+	if db == nil {
+		return nil, nil, ErrNotFound
+	}
+
+	var record SMTPRecord
+	err := db.Model(&SMTPRecord{}).Where("smtp_id = ?", smtpID).Order("time desc").First(&record).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	var recipients []SMTPRecipientRecord
+	err = db.Model(&SMTPRecipientRecord{}).Where("smtp_id = ?", smtpID).Order("id asc").Find(&recipients).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &record, recipients, nil
+}
+
+// FindMailOutbox 信件在投递队列中的所有投递记录
+func FindMailOutbox(mailID string) ([]Outbox, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []Outbox
+	err := db.Model(&Outbox{}).Where("mail_id = ?", mailID).Order("id asc").Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
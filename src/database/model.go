@@ -35,6 +35,7 @@ func (*MailRecord) TableName() string {
 type AMMail struct {
 	Model
 	MailID       string         `gorm:"column:mail_id;type:VARCHAR(100);not null;uniqueIndex;"`
+	SiteID       string         `gorm:"column:site_id;type:VARCHAR(60);not null;default:'';index"`
 	Name         string         `gorm:"column:name;type:VARCHAR(40);not null"`
 	Email        string         `gorm:"column:email;type:VARCHAR(128);not null"`
 	Content      string         `gorm:"column:content;type:TEXT;not null"`
@@ -43,7 +44,7 @@ type AMMail struct {
 	Host         string         `gorm:"column:host;type:VARCHAR(60);not null"`
 	IP           string         `gorm:"column:ip;type:VARCHAR(50);not null"`
 	Time         time.Time      `gorm:"column:time;not null"`
-	WxRobotID    sql.NullString `gorm:"column:email_id;type:VARCHAR(100);"`
+	WxRobotID    sql.NullString `gorm:"column:wxrobot_id;type:VARCHAR(100);"`
 	EmailID      sql.NullString `gorm:"column:email_id;type:VARCHAR(100);"`
 	ThankEmailID sql.NullString `gorm:"column:thank_email_id;type:VARCHAR(100);"`
 	SystemName   string         `gorm:"column:system_name;type:VARCHAR(20);not null"`
@@ -57,6 +58,7 @@ func (*AMMail) TableName() string {
 type IMAPMail struct {
 	Model
 	MailID       string         `gorm:"column:mail_id;type:VARCHAR(100);not null;uniqueIndex;"`
+	SiteID       string         `gorm:"column:site_id;type:VARCHAR(60);not null;default:'';index"`
 	MessageID    string         `gorm:"column:message_id;type:VARCHAR(128);not null"`
 	Sender       string         `gorm:"column:sender;type:VARCHAR(128);not null"`
 	From         string         `gorm:"column:from;type:VARCHAR(128);not null"`
@@ -66,9 +68,12 @@ type IMAPMail struct {
 	Content      string         `gorm:"column:content;type:TEXT;not null"`
 	SendTime     time.Time      `gorm:"column:send_time;not null"`
 	Time         time.Time      `gorm:"column:time;not null"`
-	WxRobotID    sql.NullString `gorm:"column:email_id;type:VARCHAR(100);"`
+	WxRobotID    sql.NullString `gorm:"column:wxrobot_id;type:VARCHAR(100);"`
 	EmailID      sql.NullString `gorm:"column:email_id;type:VARCHAR(100);"`
 	ThankEmailID sql.NullString `gorm:"column:thank_email_id;type:VARCHAR(100);"`
+	SPF          string         `gorm:"column:spf;type:VARCHAR(20);not null;default:''"` // 发件人认证结果，启用认证检查之前保存的邮件为空
+	DKIM         string         `gorm:"column:dkim;type:VARCHAR(20);not null;default:''"`
+	DMARC        string         `gorm:"column:dmarc;type:VARCHAR(20);not null;default:''"`
 	SystemName   string         `gorm:"column:system_name;type:VARCHAR(20);not null"`
 	Version      string         `gorm:"column:version;type:VARCHAR(20);not null"`
 }
@@ -83,7 +88,7 @@ type SystemNotifyMail struct {
 	Subject    string         `gorm:"column:subject;type:VARCHAR(128);not null"`
 	Content    string         `gorm:"column:content;type:VARCHAR(4096);not null"` // 限制长度在2048以内
 	Time       time.Time      `gorm:"column:time;not null"`
-	WxRobotID  sql.NullString `gorm:"column:email_id;type:VARCHAR(100);"`
+	WxRobotID  sql.NullString `gorm:"column:wxrobot_id;type:VARCHAR(100);"`
 	EmailID    sql.NullString `gorm:"column:email_id;type:VARCHAR(100);"`
 	SystemName string         `gorm:"column:system_name;type:VARCHAR(20);not null"`
 	Version    string         `gorm:"column:version;type:VARCHAR(20);not null"`
@@ -93,6 +98,160 @@ func (*SystemNotifyMail) TableName() string {
 	return "sys_mail"
 }
 
+type MailStatus string
+
+const (
+	MailStatusNew      MailStatus = "new"
+	MailStatusRead     MailStatus = "read"
+	MailStatusReplied  MailStatus = "replied"
+	MailStatusArchived MailStatus = "archived"
+	MailStatusSpam     MailStatus = "spam"
+)
+
+// MailState 信件的处理状态，没有记录表示新信件且未指派，各时间为最近一次进入该状态的时间
+type MailState struct {
+	Model
+	MailID     string       `gorm:"column:mail_id;type:VARCHAR(100);not null;uniqueIndex;"`
+	Status     MailStatus   `gorm:"column:status;type:VARCHAR(20);not null;default:'';index;"`
+	Assignee   string       `gorm:"column:assignee;type:VARCHAR(40);not null;default:'';index;"`
+	ReadAt     sql.NullTime `gorm:"column:read_at;"`
+	RepliedAt  sql.NullTime `gorm:"column:replied_at;"`
+	ArchivedAt sql.NullTime `gorm:"column:archived_at;"`
+	SpamAt     sql.NullTime `gorm:"column:spam_at;"`
+	AssignedAt sql.NullTime `gorm:"column:assigned_at;"`
+}
+
+func (*MailState) TableName() string {
+	return "mail_state"
+}
+
+type MailAuditAction string
+
+const (
+	MailAuditStatus MailAuditAction = "status"
+	MailAuditAssign MailAuditAction = "assign"
+)
+
+// MailAudit 信件处理状态和指派的变更记录
+type MailAudit struct {
+	Model
+	MailID   string          `gorm:"column:mail_id;type:VARCHAR(100);not null;index;"`
+	Action   MailAuditAction `gorm:"column:action;type:VARCHAR(20);not null"`
+	From     string          `gorm:"column:from;type:VARCHAR(40);not null"`
+	To       string          `gorm:"column:to;type:VARCHAR(40);not null"`
+	Operator string          `gorm:"column:operator;type:VARCHAR(60);not null"`
+	Note     string          `gorm:"column:note;type:VARCHAR(200);not null"`
+	Time     time.Time       `gorm:"column:time;not null"`
+}
+
+func (*MailAudit) TableName() string {
+	return "mail_audit"
+}
+
+// MailReply 管理员回复留言时发出的邮件
+type MailReply struct {
+	Model
+	ReplyID    string         `gorm:"column:reply_id;type:VARCHAR(100);not null;uniqueIndex;"`
+	MailID     string         `gorm:"column:mail_id;type:VARCHAR(100);not null;index;"` // 所回复的信件
+	SiteID     string         `gorm:"column:site_id;type:VARCHAR(60);not null;default:''"`
+	MessageID  string         `gorm:"column:message_id;type:VARCHAR(256);not null"`
+	InReplyTo  string         `gorm:"column:in_reply_to;type:VARCHAR(256);not null"`
+	References string         `gorm:"column:references;type:TEXT;not null"` // 以空格分隔
+	To         string         `gorm:"column:to;type:VARCHAR(128);not null"`
+	Subject    string         `gorm:"column:subject;type:VARCHAR(128);not null"`
+	Content    string         `gorm:"column:content;type:TEXT;not null"`
+	Operator   string         `gorm:"column:operator;type:VARCHAR(60);not null"`
+	SmtpID     sql.NullString `gorm:"column:smtp_id;type:VARCHAR(100);"`
+	Success    bool           `gorm:"column:success;not null"`
+	ErrMsg     sql.NullString `gorm:"column:err_msg;type:VARCHAR(200);"`
+	Time       time.Time      `gorm:"column:time;not null"`
+	SystemName string         `gorm:"column:system_name;type:VARCHAR(20);not null"`
+	Version    string         `gorm:"column:version;type:VARCHAR(20);not null"`
+}
+
+func (*MailReply) TableName() string {
+	return "mail_reply"
+}
+
+// ReplyAlias 信件的回复别名，通知邮件的回复地址为该别名，管理员发往别名的邮件会转发给留言人
+type ReplyAlias struct {
+	Model
+	Token  string    `gorm:"column:token;type:VARCHAR(40);not null;uniqueIndex;"`
+	MailID string    `gorm:"column:mail_id;type:VARCHAR(100);not null;uniqueIndex;"`
+	SiteID string    `gorm:"column:site_id;type:VARCHAR(60);not null;default:''"`
+	Time   time.Time `gorm:"column:time;not null"`
+}
+
+func (*ReplyAlias) TableName() string {
+	return "reply_alias"
+}
+
+// Ticket 网页留言的查询令牌，留言人凭令牌查看处理状态和回复，只保存令牌的 SHA-256
+type Ticket struct {
+	Model
+	TokenHash string    `gorm:"column:token_hash;type:VARCHAR(64);not null;uniqueIndex;"`
+	MailID    string    `gorm:"column:mail_id;type:VARCHAR(100);not null;uniqueIndex;"`
+	Time      time.Time `gorm:"column:time;not null"`
+}
+
+func (*Ticket) TableName() string {
+	return "ticket"
+}
+
+// POP3Message POP3 邮箱中已经收取过的邮件，以 UIDL 去重
+type POP3Message struct {
+	Model
+	Account string    `gorm:"column:account;type:VARCHAR(200);not null;uniqueIndex:idx_pop3_message;"` // 用户名@服务器地址
+	UIDL    string    `gorm:"column:uidl;type:VARCHAR(100);not null;uniqueIndex:idx_pop3_message;"`
+	Time    time.Time `gorm:"column:time;not null"` // 第一次收取的时间，用于判断何时从服务器删除
+}
+
+func (*POP3Message) TableName() string {
+	return "pop3_message"
+}
+
+// IMAPSyncState IMAP 邮箱的同步状态，UIDVALIDITY 不变时只收取 UID 大于 LastUID 的邮件
+type IMAPSyncState struct {
+	Model
+	Account     string    `gorm:"column:account;type:VARCHAR(200);not null;uniqueIndex:idx_imap_sync_state;"` // 用户名@服务器地址
+	MailBox     string    `gorm:"column:mailbox;type:VARCHAR(200);not null;uniqueIndex:idx_imap_sync_state;"`
+	UIDValidity uint32    `gorm:"column:uid_validity;not null"`
+	LastUID     uint32    `gorm:"column:last_uid;not null"`
+	Time        time.Time `gorm:"column:time;not null"` // 状态最后一次变化的时间
+}
+
+func (*IMAPSyncState) TableName() string {
+	return "imap_sync_state"
+}
+
+// Attachment 邮箱留言的附件，文件保存在 --attachment-path 下以信件ID命名的目录中
+type Attachment struct {
+	Model
+	MailID      string    `gorm:"column:mail_id;type:VARCHAR(100);not null;index"`
+	Name        string    `gorm:"column:name;type:VARCHAR(256);not null"`
+	ContentType string    `gorm:"column:content_type;type:VARCHAR(128);not null"`
+	Size        int64     `gorm:"column:size;not null"`
+	Path        string    `gorm:"column:path;type:VARCHAR(512);not null"` // 相对于 --attachment-path 的路径，附件太大没有保存时为空
+	Time        time.Time `gorm:"column:time;not null"`
+}
+
+func (*Attachment) TableName() string {
+	return "attachment"
+}
+
+// RawMail 邮箱留言的原始邮件（RFC 5322），以 gzip 压缩保存
+type RawMail struct {
+	Model
+	MailID string    `gorm:"column:mail_id;type:VARCHAR(100);not null;uniqueIndex"`
+	Size   int64     `gorm:"column:size;not null"` // 压缩前的大小
+	Data   []byte    `gorm:"column:data;not null"`
+	Time   time.Time `gorm:"column:time;not null"`
+}
+
+func (*RawMail) TableName() string {
+	return "raw_mail"
+}
+
 type WxRobotRecord struct {
 	Model
 	WxRobotID string `gorm:"column:wxrobot_id;type:VARCHAR(100);not null;uniqueIndex;"`
@@ -123,7 +282,7 @@ type SMTPRecord struct {
 	From           string         `gorm:"column:from;type:VARCHAR(128);not null"`
 	Subject        string         `gorm:"column:subject;type:VARCHAR(128);not null"`
 	Content        string         `gorm:"column:content;type:TEXT;not null"`
-	ReplyMessageID sql.NullString `gorm:"column:content;type:VARCHAR(1024)"`
+	ReplyMessageID sql.NullString `gorm:"column:reply_message_id;type:VARCHAR(1024)"`
 	Time           time.Time      `gorm:"column:time;not null"`
 	Success        bool           `gorm:"column:success;not null"`
 	ErrMsg         sql.NullString `gorm:"column:err_msg;type:VARCHAR(200);"`
@@ -144,3 +303,44 @@ type SMTPRecipientRecord struct {
 func (*SMTPRecipientRecord) TableName() string {
 	return "smtp_recipient_record"
 }
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusSuccess OutboxStatus = "success"
+	OutboxStatusDead    OutboxStatus = "dead"   // 超过最大重试次数，不再自动投递
+	OutboxStatusResent  OutboxStatus = "resent" // 死信已被手动重新投递
+)
+
+type Outbox struct {
+	Model
+	DeliveryID string         `gorm:"column:delivery_id;type:VARCHAR(100);not null;uniqueIndex;"`
+	MailID     string         `gorm:"column:mail_id;type:VARCHAR(100);not null;index;"`
+	Notifier   string         `gorm:"column:notifier;type:VARCHAR(40);not null"`
+	Payload    string         `gorm:"column:payload;type:TEXT;not null"`
+	Status     OutboxStatus   `gorm:"column:status;type:VARCHAR(20);not null;index;"`
+	Attempts   int            `gorm:"column:attempts;not null"`
+	NextTime   time.Time      `gorm:"column:next_time;not null;index;"`
+	ErrMsg     sql.NullString `gorm:"column:err_msg;type:VARCHAR(200);"`
+	Time       time.Time      `gorm:"column:time;not null"`
+	SystemName string         `gorm:"column:system_name;type:VARCHAR(20);not null"`
+	Version    string         `gorm:"column:version;type:VARCHAR(20);not null"`
+}
+
+func (*Outbox) TableName() string {
+	return "outbox"
+}
+
+// APIToken 管理API的令牌，只保存令牌的SHA-256
+type APIToken struct {
+	Model
+	Name      string       `gorm:"column:name;type:VARCHAR(40);not null;uniqueIndex;"`
+	TokenHash string       `gorm:"column:token_hash;type:VARCHAR(64);not null;uniqueIndex;"`
+	LastUsed  sql.NullTime `gorm:"column:last_used;"`
+	Time      time.Time    `gorm:"column:time;not null"`
+}
+
+func (*APIToken) TableName() string {
+	return "api_token"
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	resource "github.com/SongZihuan/anonymous-message"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"gorm.io/gorm"
+	"time"
+)
+
+// SaveOutbox 保存一条投递记录，记录创建时即视为第一次投递已被领取，lease 为领取的有效期
+func SaveOutbox(deliveryID string, mailID string, notifier string, payload string, lease time.Time, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	record := Outbox{
+		DeliveryID: deliveryID,
+		MailID:     mailID,
+		Notifier:   notifier,
+		Payload:    payload,
+		Status:     OutboxStatusPending,
+		Attempts:   1,
+		NextTime:   lease,
+		Time:       t,
+		SystemName: flagparser.Name,
+		Version:    resource.Version,
+	}
+
+	err := db.Create(&record).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func FindDueOutbox(now time.Time, limit int) ([]Outbox, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []Outbox
+	err := db.Model(&Outbox{}).Where("status = ? AND next_time <= ?", OutboxStatusPending, now).Order("next_time asc").Limit(limit).Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ClaimOutbox 领取一条到期的投递记录并增加尝试次数，返回 false 表示已被其他协程领取
+func ClaimOutbox(deliveryID string, now time.Time, lease time.Time) (bool, error) {
+	if db == nil {
+		return false, nil
+	}
+
+	res := db.Model(&Outbox{}).
+		Where("delivery_id = ? AND status = ? AND next_time <= ?", deliveryID, OutboxStatusPending, now).
+		Updates(map[string]any{
+			"attempts":  gorm.Expr("attempts + 1"),
+			"next_time": lease,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected == 1, nil
+}
+
+// UpdateOutboxRecord 记录一次投递的结果，失败时 next 为下次重试的时间，dead 表示转入死信
+func UpdateOutboxRecord(deliveryID string, sendErr error, next time.Time, dead bool) error {
+	if db == nil {
+		return nil
+	}
+
+	var record Outbox
+	err := db.Model(&Outbox{}).Where("delivery_id = ?", deliveryID).First(&record).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("record not found")
+	} else if err != nil {
+		return err
+	}
+
+	if sendErr == nil {
+		record.Status = OutboxStatusSuccess
+		record.ErrMsg = sql.NullString{
+			Valid: false,
+		}
+	} else {
+		errMsg := sendErr.Error()
+		if len(errMsg) > 190 {
+			errMsg = utils.TruncateUTF8(errMsg, 190)
+		}
+
+		if dead {
+			record.Status = OutboxStatusDead
+		} else {
+			record.Status = OutboxStatusPending
+			record.NextTime = next
+		}
+
+		record.ErrMsg = sql.NullString{
+			Valid:  true,
+			String: errMsg,
+		}
+	}
+
+	err = db.Save(&record).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateOutboxPayload 更新投递记录中的通知内容，用于记录已经投递成功的部分
+func UpdateOutboxPayload(deliveryID string, payload string) error {
+	if db == nil {
+		return nil
+	}
+
+	return db.Model(&Outbox{}).Where("delivery_id = ?", deliveryID).Update("payload", payload).Error
+}
+
+func FindDeadOutbox(since time.Time) ([]Outbox, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []Outbox
+	err := db.Model(&Outbox{}).Where("status = ? AND time >= ?", OutboxStatusDead, since).Order("time asc").Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// FindLastOutbox 查找信件最近一次的投递记录，用于取回当时投递的通知内容
+func FindLastOutbox(mailID string) (*Outbox, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var record Outbox
+	err := db.Model(&Outbox{}).Where("mail_id = ?", mailID).Order("time desc").First(&record).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// HasPendingOutbox 信件在该渠道上是否还有等待投递的记录
+func HasPendingOutbox(mailID string, notifier string) (bool, error) {
+	if db == nil {
+		return false, nil
+	}
+
+	var count int64
+	err := db.Model(&Outbox{}).Where("mail_id = ? AND notifier = ? AND status = ?", mailID, notifier, OutboxStatusPending).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// MarkOutboxResent 手动重新投递成功后，将该信件在该渠道上的死信标记为已重新投递
+func MarkOutboxResent(mailID string, notifier string) error {
+	if db == nil {
+		return nil
+	}
+
+	return db.Model(&Outbox{}).
+		Where("mail_id = ? AND notifier = ? AND status = ?", mailID, notifier, OutboxStatusDead).
+		Update("status", OutboxStatusResent).Error
+}
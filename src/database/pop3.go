@@ -0,0 +1,46 @@
+package database
+
+import (
+	"time"
+)
+
+// FindPOP3Message 账号已经收取过的邮件，键为 UIDL，值为第一次收取的时间
+func FindPOP3Message(account string) (map[string]time.Time, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var list []POP3Message
+	err := db.Model(&POP3Message{}).Where("account = ?", account).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]time.Time, len(list))
+	for _, m := range list {
+		res[m.UIDL] = m.Time
+	}
+
+	return res, nil
+}
+
+func SavePOP3Message(account string, uidl string, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	return db.Create(&POP3Message{
+		Account: account,
+		UIDL:    uidl,
+		Time:    t,
+	}).Error
+}
+
+// DeletePOP3Message 删除已经不在服务器上的邮件的记录
+func DeletePOP3Message(account string, uidl []string) error {
+	if db == nil || len(uidl) == 0 {
+		return nil
+	}
+
+	return db.Unscoped().Where("account = ? AND uidl IN ?", account, uidl).Delete(&POP3Message{}).Error
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// UndeliveredMail 企业微信或电子邮件通知没有成功送达的信件
+type UndeliveredMail struct {
+	MailID       string
+	MailType     MsgType
+	SiteID       string // 系统留言属于默认站点
+	WxRobotFail  bool
+	EmailFail    bool
+	WxRobotError string
+	EmailError   string
+	Time         time.Time
+}
+
+func FindMailRecord(mailID string) (*MailRecord, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var record MailRecord
+	err := db.Model(&MailRecord{}).Where("mail_id = ?", mailID).First(&record).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func FindAMMail(mailID string) (*AMMail, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var mail AMMail
+	err := db.Model(&AMMail{}).Where("mail_id = ?", mailID).Order("time desc").First(&mail).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &mail, nil
+}
+
+func FindIMAPMail(mailID string) (*IMAPMail, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var mail IMAPMail
+	err := db.Model(&IMAPMail{}).Where("mail_id = ?", mailID).Order("time desc").First(&mail).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &mail, nil
+}
+
+func FindSNMail(mailID string) (*SystemNotifyMail, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var mail SystemNotifyMail
+	err := db.Model(&SystemNotifyMail{}).Where("mail_id = ?", mailID).Order("time desc").First(&mail).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &mail, nil
+}
+
+// FindUndeliveredMail 查找 since 之后企业微信或电子邮件通知没有记录或发送失败的信件
+func FindUndeliveredMail(since time.Time) ([]UndeliveredMail, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []UndeliveredMail
+
+	tables := []struct {
+		table    string
+		mailType MsgType
+		site     string
+	}{
+		{(&AMMail{}).TableName(), MsgTypeWebsite, "m.site_id"},
+		{(&IMAPMail{}).TableName(), MsgTypeEmail, "m.site_id"},
+		{(&SystemNotifyMail{}).TableName(), MsgTypeSystem, ""},
+	}
+
+	for _, t := range tables {
+		var rows []struct {
+			MailID         string
+			SiteID         string
+			Time           time.Time
+			WxRobotID      *string
+			WxRobotSuccess *bool
+			WxRobotErrMsg  *string
+			EmailID        *string
+			EmailSuccess   *bool
+			EmailErrMsg    *string
+		}
+
+		err := db.Table(t.table+" AS m").
+			Select("m.mail_id AS mail_id, "+columnOrEmpty(t.site)+" AS site_id, m.time AS time, "+
+				"m.wxrobot_id AS wx_robot_id, w.success AS wx_robot_success, w.err_msg AS wx_robot_err_msg, "+
+				"m.email_id AS email_id, s.success AS email_success, s.err_msg AS email_err_msg").
+			Joins("LEFT JOIN "+(&WxRobotRecord{}).TableName()+" AS w ON w.wxrobot_id = m.wxrobot_id").
+			Joins("LEFT JOIN "+(&SMTPRecord{}).TableName()+" AS s ON s.smtp_id = m.email_id").
+			Where("m.deleted_at IS NULL AND m.time >= ?", since).
+			Order("m.time asc").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range rows {
+			mail := UndeliveredMail{
+				MailID:   r.MailID,
+				MailType: t.mailType,
+				SiteID:   r.SiteID,
+				Time:     r.Time,
+			}
+
+			if r.WxRobotID == nil || r.WxRobotSuccess == nil || !*r.WxRobotSuccess {
+				mail.WxRobotFail = true
+				if r.WxRobotErrMsg != nil {
+					mail.WxRobotError = *r.WxRobotErrMsg
+				}
+			}
+
+			if r.EmailID == nil || r.EmailSuccess == nil || !*r.EmailSuccess {
+				mail.EmailFail = true
+				if r.EmailErrMsg != nil {
+					mail.EmailError = *r.EmailErrMsg
+				}
+			}
+
+			if mail.WxRobotFail || mail.EmailFail {
+				res = append(res, mail)
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"gorm.io/gorm"
+	"io"
+	"time"
+)
+
+func SaveRawMail(mailID string, raw []byte, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(raw)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return db.Create(&RawMail{
+		MailID: mailID,
+		Size:   int64(len(raw)),
+		Data:   buf.Bytes(),
+		Time:   t,
+	}).Error
+}
+
+// FindRawMail 解压后的原始邮件
+func FindRawMail(mailID string) ([]byte, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var record RawMail
+	err := db.Model(&RawMail{}).Where("mail_id = ?", mailID).First(&record).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(record.Data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+func SaveReplyAlias(token string, mailID string, siteID string, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	return db.Create(&ReplyAlias{
+		Token:  token,
+		MailID: mailID,
+		SiteID: siteID,
+		Time:   t,
+	}).Error
+}
+
+func FindReplyAliasByMailID(mailID string) (*ReplyAlias, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var alias ReplyAlias
+	err := db.Model(&ReplyAlias{}).Where("mail_id = ?", mailID).First(&alias).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &alias, nil
+}
+
+func FindReplyAliasByToken(token string) (*ReplyAlias, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var alias ReplyAlias
+	err := db.Model(&ReplyAlias{}).Where("token = ?", token).First(&alias).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &alias, nil
+}
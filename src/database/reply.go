@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	resource "github.com/SongZihuan/anonymous-message"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"strings"
+	"time"
+)
+
+// SaveMailReply 在发送前保存回复，发送结果通过 UpdateMailReply 更新
+func SaveMailReply(replyID string, mailID string, siteID string, messageID string, inReplyTo string, references []string, to string, subject string, content string, operator string, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	if len(to) > 120 {
+		to = utils.TruncateUTF8(to, 120)
+	}
+
+	if len(subject) > 120 {
+		subject = utils.TruncateUTF8(subject, 120)
+	}
+
+	if len(operator) > 60 {
+		operator = utils.TruncateUTF8(operator, 60)
+	}
+
+	reply := &MailReply{
+		ReplyID:    replyID,
+		MailID:     mailID,
+		SiteID:     siteID,
+		MessageID:  messageID,
+		InReplyTo:  inReplyTo,
+		References: strings.Join(references, " "),
+		To:         to,
+		Subject:    subject,
+		Content:    content,
+		Operator:   operator,
+		Time:       t,
+		SystemName: flagparser.Name,
+		Version:    resource.Version,
+	}
+
+	return db.Create(reply).Error
+}
+
+func UpdateMailReply(replyID string, smtpID string, sendErr error) error {
+	if db == nil {
+		return nil
+	}
+
+	updates := map[string]any{
+		"smtp_id": sql.NullString{Valid: smtpID != "", String: smtpID},
+		"success": sendErr == nil,
+		"err_msg": sql.NullString{},
+	}
+
+	if sendErr != nil {
+		errMsg := sendErr.Error()
+		if len(errMsg) > 190 {
+			errMsg = utils.TruncateUTF8(errMsg, 190)
+		}
+		updates["err_msg"] = sql.NullString{Valid: true, String: errMsg}
+	}
+
+	return db.Model(&MailReply{}).Where("reply_id = ?", replyID).Updates(updates).Error
+}
+
+// FindMailReply 信件的所有回复，按时间排序
+func FindMailReply(mailID string) ([]MailReply, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []MailReply
+	err := db.Model(&MailReply{}).Where("mail_id = ?", mailID).Order("time asc, id asc").Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
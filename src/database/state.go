@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"gorm.io/gorm"
+	"time"
+)
+
+// UnhandledStatus 未处理的信件状态
+var UnhandledStatus = []MailStatus{MailStatusNew, MailStatusRead}
+
+func FindMailState(mailID string) (*MailState, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var state MailState
+	err := db.Model(&MailState{}).Where("mail_id = ?", mailID).First(&state).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func FindMailAudit(mailID string) ([]MailAudit, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []MailAudit
+	err := db.Model(&MailAudit{}).Where("mail_id = ?", mailID).Order("id asc").Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// UpdateMailStatus 修改信件的处理状态并记录变更，状态没有变化时返回 false
+func UpdateMailStatus(mailID string, status MailStatus, operator string, note string, t time.Time) (bool, error) {
+	if db == nil {
+		return false, nil
+	}
+
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		state, err := firstOrCreateMailState(tx, mailID)
+		if err != nil {
+			return err
+		} else if state.Status == status {
+			return nil
+		}
+
+		now := sql.NullTime{Time: t, Valid: true}
+		updates := map[string]any{"status": status}
+
+		switch status {
+		case MailStatusNew:
+			updates["read_at"] = sql.NullTime{} // 标记为未读
+		case MailStatusRead:
+			updates["read_at"] = now
+		case MailStatusReplied:
+			updates["replied_at"] = now
+		case MailStatusArchived:
+			updates["archived_at"] = now
+		case MailStatusSpam:
+			updates["spam_at"] = now
+		}
+
+		if status != MailStatusNew && !state.ReadAt.Valid {
+			updates["read_at"] = now
+		}
+
+		err = tx.Model(&MailState{}).Where("id = ?", state.ID).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+
+		changed = true
+		return saveMailAudit(tx, mailID, MailAuditStatus, string(state.Status), string(status), operator, note, t)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return changed, nil
+}
+
+// UpdateMailAssignee 修改信件的处理人并记录变更，assignee 为空表示取消指派，没有变化时返回 false
+func UpdateMailAssignee(mailID string, assignee string, operator string, note string, t time.Time) (bool, error) {
+	if db == nil {
+		return false, nil
+	}
+
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		state, err := firstOrCreateMailState(tx, mailID)
+		if err != nil {
+			return err
+		} else if state.Assignee == assignee {
+			return nil
+		}
+
+		err = tx.Model(&MailState{}).Where("id = ?", state.ID).Updates(map[string]any{
+			"assignee":    assignee,
+			"assigned_at": sql.NullTime{Time: t, Valid: assignee != ""},
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		changed = true
+		return saveMailAudit(tx, mailID, MailAuditAssign, state.Assignee, assignee, operator, note, t)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return changed, nil
+}
+
+func firstOrCreateMailState(tx *gorm.DB, mailID string) (*MailState, error) {
+	var state MailState
+	err := tx.Where(MailState{MailID: mailID}).Attrs(MailState{Status: MailStatusNew}).FirstOrCreate(&state).Error
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func saveMailAudit(tx *gorm.DB, mailID string, action MailAuditAction, from string, to string, operator string, note string, t time.Time) error {
+	if len(operator) > 60 {
+		operator = utils.TruncateUTF8(operator, 60)
+	}
+
+	if len(note) > 200 {
+		note = utils.TruncateUTF8(note, 200)
+	}
+
+	return tx.Create(&MailAudit{
+		MailID:   mailID,
+		Action:   action,
+		From:     from,
+		To:       to,
+		Operator: operator,
+		Note:     note,
+		Time:     t,
+	}).Error
+}
+
+// CountUnhandledMail 未处理（新信件或已读）的网页留言和邮箱留言数量
+func CountUnhandledMail() (int64, error) {
+	if db == nil {
+		return 0, nil
+	}
+
+	var res int64
+	for _, mailType := range []MsgType{MsgTypeWebsite, MsgTypeEmail} {
+		_, total, err := FindMessages(MessageFilter{
+			Type:   mailType,
+			Status: UnhandledStatus,
+			Limit:  1,
+		})
+		if err != nil {
+			return 0, err
+		}
+		res += total
+	}
+
+	return res, nil
+}
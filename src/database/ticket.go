@@ -0,0 +1,50 @@
+package database
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+func SaveTicket(tokenHash string, mailID string, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	return db.Create(&Ticket{
+		TokenHash: tokenHash,
+		MailID:    mailID,
+		Time:      t,
+	}).Error
+}
+
+func FindTicket(tokenHash string) (*Ticket, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var ticket Ticket
+	err := db.Model(&Ticket{}).Where("token_hash = ?", tokenHash).First(&ticket).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+// HasTicket 信件是否有查询令牌
+func HasTicket(mailID string) (bool, error) {
+	if db == nil {
+		return false, nil
+	}
+
+	var count int64
+	err := db.Model(&Ticket{}).Where("mail_id = ?", mailID).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+func SaveAPIToken(name string, tokenHash string, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	record := APIToken{
+		Name:      name,
+		TokenHash: tokenHash,
+		Time:      t,
+	}
+
+	err := db.Create(&record).Error
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func FindAPIToken(tokenHash string) (*APIToken, error) {
+	if db == nil {
+		return nil, ErrNotFound
+	}
+
+	var record APIToken
+	err := db.Model(&APIToken{}).Where("token_hash = ?", tokenHash).First(&record).Error
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func FindAllAPIToken() ([]APIToken, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	var res []APIToken
+	err := db.Model(&APIToken{}).Order("id asc").Find(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func UpdateAPITokenUsed(id uint, t time.Time) error {
+	if db == nil {
+		return nil
+	}
+
+	return db.Model(&APIToken{}).Where("id = ?", id).Update("last_used", sql.NullTime{Time: t, Valid: true}).Error
+}
+
+// DeleteAPIToken 彻底删除令牌（不是软删除），以便重新创建同名令牌
+func DeleteAPIToken(name string) error {
+	if db == nil {
+		return ErrNotFound
+	}
+
+	res := db.Unscoped().Where("name = ?", name).Delete(&APIToken{})
+	if res.Error != nil {
+		return res.Error
+	} else if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
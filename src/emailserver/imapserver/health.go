@@ -0,0 +1,70 @@
+package imapserver
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	HealthConnecting = "connecting"
+	HealthConnected  = "connected"
+	HealthError      = "error"
+	HealthStopped    = "stopped"
+)
+
+// Health IMAP账号的运行状态
+type Health struct {
+	ID            string // 默认账号的ID为空
+	Address       string
+	User          string
+	MailBox       []string
+	SiteID        string
+	Status        string
+	IDLE          bool
+	ConnectedAt   time.Time
+	LastCheck     time.Time // 最后一次检查新邮件的时间
+	LastError     string
+	LastErrorTime time.Time
+	ErrorCount    int // 连续出现命令错误的次数，超过10次停止该账号
+	Reconnect     int
+	Processed     int // 启动以来处理过的邮件数量
+}
+
+var healthLock sync.Mutex
+var healthList []*Health
+
+func newHealth(acc *account) *Health {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+
+	h := &Health{
+		ID:      acc.ID,
+		Address: acc.Address,
+		User:    acc.User,
+		MailBox: acc.MailBoxList(),
+		SiteID:  acc.SiteID,
+		Status:  HealthConnecting,
+	}
+	healthList = append(healthList, h)
+	return h
+}
+
+func updateHealth(h *Health, fn func(h *Health)) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	fn(h)
+}
+
+// AccountHealth 所有IMAP账号的运行状态
+func AccountHealth() []Health {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+
+	res := make([]Health, 0, len(healthList))
+	for _, h := range healthList {
+		item := *h
+		item.MailBox = append([]string(nil), h.MailBox...)
+		res = append(res, item)
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+package imapserver
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/inbound"
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"sort"
+)
+
+// folder 处理结果对应的目标文件夹，为空时邮件留在原邮箱
+func (acc *account) folder(result inbound.Result) string {
+	switch result {
+	case inbound.ResultAccepted:
+		return acc.AcceptedFolder
+	case inbound.ResultRejected:
+		return acc.RejectedFolder
+	case inbound.ResultLoop:
+		return acc.LoopFolder
+	default:
+		return ""
+	}
+}
+
+// moveMessages 把当前选中邮箱中的邮件移动到目标文件夹，文件夹不存在时自动创建
+// 服务端不支持 MOVE 时 imapclient 会改用 COPY、STORE \Deleted 和 EXPUNGE
+func moveMessages(imapClient *imapclient.Client, move map[string][]imap.UID) error {
+	folders := make([]string, 0, len(move))
+	for folder := range move {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	for _, folder := range folders {
+		err := createFolder(imapClient, folder)
+		if err != nil {
+			return err
+		}
+
+		_, err = imapClient.Move(imap.UIDSetNum(move[folder]...), folder).Wait()
+		if err != nil {
+			return fmt.Errorf("move mail to %s failed: %s", folder, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func createFolder(imapClient *imapclient.Client, folder string) error {
+	list, err := imapClient.List("", folder, nil).Collect()
+	if err != nil {
+		return fmt.Errorf("failed to list mailbox %s: %s", folder, err.Error())
+	} else if len(list) > 0 {
+		return nil
+	}
+
+	err = imapClient.Create(folder, nil).Wait()
+	if err != nil {
+		return fmt.Errorf("failed to create mailbox %s: %s", folder, err.Error())
+	}
+
+	fmt.Printf("创建 IMAP 文件夹: %s\n", folder)
+	return nil
+}
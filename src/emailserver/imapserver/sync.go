@@ -0,0 +1,217 @@
+package imapserver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/inbound"
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"sort"
+	"sync"
+	"time"
+)
+
+// syncState 邮箱的同步状态，没有启用SQLite时只保存在内存中，重启后重新从一天内的邮件开始同步
+type syncState struct {
+	UIDValidity uint32
+	LastUID     imap.UID
+	Time        time.Time
+}
+
+var memoryStateLock sync.Mutex
+var memoryState = make(map[string]syncState)
+
+func loadSyncState(acc *account, mailbox string) (*syncState, error) {
+	if !database.Ready() {
+		memoryStateLock.Lock()
+		defer memoryStateLock.Unlock()
+
+		state, ok := memoryState[acc.key()+"\n"+mailbox]
+		if !ok {
+			return nil, nil
+		}
+		return &state, nil
+	}
+
+	state, err := database.FindIMAPSyncState(acc.key(), mailbox)
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &syncState{
+		UIDValidity: state.UIDValidity,
+		LastUID:     imap.UID(state.LastUID),
+		Time:        state.Time,
+	}, nil
+}
+
+func saveSyncState(acc *account, mailbox string, state syncState) error {
+	if !database.Ready() {
+		memoryStateLock.Lock()
+		defer memoryStateLock.Unlock()
+
+		memoryState[acc.key()+"\n"+mailbox] = state
+		return nil
+	}
+
+	return database.SaveIMAPSyncState(acc.key(), mailbox, state.UIDValidity, uint32(state.LastUID), state.Time)
+}
+
+// fetchMailbox 处理邮箱中 UID 大于上次处理的邮件，返回处理的邮件数量
+// 第一次同步时只处理一天内的邮件；UIDVALIDITY 变化时重新同步上次同步以来的所有邮件，已保存的邮件按 Message-ID 跳过
+func fetchMailbox(imapClient *imapclient.Client, acc *account, mailbox string) (processed int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("imap panic: %v", r)
+		}
+	}()
+
+	sel, err := imapClient.Select(mailbox, nil).Wait()
+	if err != nil {
+		return 0, fmt.Errorf("fail to select mail box %s: %s", mailbox, err.Error())
+	}
+
+	state, err := loadSyncState(acc, mailbox)
+	if err != nil {
+		return 0, fmt.Errorf("load sync state of mail box %s: %s", mailbox, err.Error())
+	}
+
+	now := time.Now()
+	criteria := &imap.SearchCriteria{}
+	lastUID := imap.UID(0)
+
+	if state == nil {
+		criteria.Since = now.Add(-1 * 24 * time.Hour) // 避免第一次同步时处理整个邮箱
+	} else if state.UIDValidity != sel.UIDValidity {
+		fmt.Printf("IMAP 账号 %s 的邮箱 %s UIDVALIDITY 发生变化，重新同步\n", acc.label(), mailbox)
+		criteria.Since = state.Time.Add(-1 * 24 * time.Hour) // SINCE 只比较日期，多留一天
+	} else if sel.UIDNext != 0 && sel.UIDNext <= state.LastUID+1 {
+		return 0, nil // 没有新邮件
+	} else {
+		lastUID = state.LastUID
+		criteria.UID = []imap.UIDSet{{imap.UIDRange{Start: lastUID + 1, Stop: 0}}}
+	}
+
+	newLastUID := lastUID
+	if sel.UIDNext > 0 && sel.UIDNext-1 > newLastUID {
+		newLastUID = sel.UIDNext - 1 // SELECT 时已经存在的邮件都会被下面的搜索找到
+	}
+
+	save := true // 搜索或获取失败且没有处理任何邮件时保留原来的状态
+	defer func() {
+		if !save || state != nil && state.UIDValidity == sel.UIDValidity && state.LastUID == newLastUID {
+			return
+		}
+
+		_err := saveSyncState(acc, mailbox, syncState{
+			UIDValidity: sel.UIDValidity,
+			LastUID:     newLastUID,
+			Time:        now,
+		})
+		if _err != nil && err == nil {
+			err = fmt.Errorf("save sync state of mail box %s: %s", mailbox, _err.Error())
+		}
+	}()
+
+	if sel.NumMessages == 0 {
+		return 0, nil
+	}
+
+	searchData, err := imapClient.UIDSearch(criteria, nil).Wait()
+	if err != nil {
+		save = false
+		return 0, fmt.Errorf("search failed: %s", err.Error())
+	}
+
+	uids := make([]imap.UID, 0, 10)
+	for _, uid := range searchData.AllUIDs() {
+		if uid > lastUID { // 范围 n:* 在没有新邮件时也会返回最后一封邮件
+			uids = append(uids, uid)
+		}
+	}
+
+	if len(uids) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(uids, func(i, j int) bool {
+		return uids[i] < uids[j]
+	})
+
+	if uids[len(uids)-1] > newLastUID {
+		newLastUID = uids[len(uids)-1]
+	}
+
+	msgCMD := imapClient.Fetch(imap.UIDSetNum(uids...), &imap.FetchOptions{
+		UID:      true,
+		Envelope: true,
+		BodySection: []*imap.FetchItemBodySection{
+			&imap.FetchItemBodySection{}, // 获取整个正文
+		},
+	})
+
+	maxUID := lastUID
+	move := make(map[string][]imap.UID, 3) // 目标文件夹 -> 邮件
+
+MsgCycle:
+	for {
+		msg := msgCMD.Next()
+		if msg == nil {
+			break MsgCycle
+		}
+
+		buf, err := msg.Collect()
+		if err != nil {
+			continue MsgCycle
+		}
+
+		var body []byte
+	BodySessionCycle:
+		for session, bd := range buf.BodySection {
+			if session.Specifier == "" {
+				body = bd
+				break BodySessionCycle
+			}
+		}
+
+		if buf.Envelope != nil && body != nil {
+			if folder := acc.folder(process(buf.Envelope, body)); folder != "" {
+				move[folder] = append(move[folder], buf.UID)
+			}
+			processed += 1
+		}
+
+		if buf.UID > maxUID {
+			maxUID = buf.UID
+		}
+	}
+
+	err = msgCMD.Close()
+	if err != nil {
+		newLastUID = maxUID // 只记录已经处理的邮件，剩下的邮件下次重新获取
+		save = maxUID > lastUID
+		return processed, fmt.Errorf("close fetch failed: %s", err.Error())
+	}
+
+	err = moveMessages(imapClient, move)
+	if err != nil {
+		return processed, err // 已经处理的邮件不会重复处理，只是留在原邮箱中
+	}
+
+	return processed, nil
+}
+
+// process 单封邮件出现致命错误时不影响其他邮件，也不会在下次同步时重复处理
+func process(envelope *imap.Envelope, body []byte) (res inbound.Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("处理 IMAP 邮件出现致命错误: %v\n", r)
+			res = inbound.ResultIgnored
+		}
+	}()
+
+	return inbound.Process(envelope.To, envelope, body)
+}
@@ -0,0 +1,609 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package inbound
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/attachment"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/mailauth"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/smtpserver"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/maxlimit"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/relay"
+	"github.com/SongZihuan/anonymous-message/src/reply"
+	"github.com/SongZihuan/anonymous-message/src/reqrate"
+	"github.com/SongZihuan/anonymous-message/src/sender"
+	"github.com/SongZihuan/anonymous-message/src/site"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset" // 注册 GBK、GB18030、Big5、ISO-2022-JP 等字符集，读取邮件时转换为 UTF-8
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+	"github.com/jaytaylor/html2text"
+	"io"
+	"mime"
+	"strings"
+	"time"
+)
+
+// unknownCharsetMsg 字符集由 go-message/charset 转换为 UTF-8，其中不支持的字符集拒收
+const unknownCharsetMsg = "邮件编码错误，无法识别邮件的字符集，我们支持UTF-8、GBK、GB18030、Big5、ISO-2022-JP等常见编码"
+
+// Result 邮件的处理结果，IMAP 据此把邮件移动到不同的文件夹
+type Result int
+
+const (
+	ResultIgnored  Result = iota // 不是发给我们的邮件、已经处理过的邮件或发件人无效
+	ResultAccepted               // 已保存并通知，或作为回复转发给留言人
+	ResultRejected               // 因频率限制、编码、内容太大等原因拒收，已通过错误邮件告知发件人
+	ResultLoop                   // 由我们自己的地址发出，为避免循环不做处理
+)
+
+// IsRecipient 地址是否为我们的收件地址（站点收件地址或回复别名）
+func IsRecipient(address string) bool {
+	if _, ok := relay.Match(address); ok {
+		return true
+	}
+	return site.IsRecipient(address)
+}
+
+// ReadEnvelope 从邮件头中读取与 IMAP ENVELOPE 相同的信息，供直接收取的邮件使用
+func ReadEnvelope(body []byte) (*imap.Envelope, error) {
+	header, err := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	h := mail.Header{Header: message.Header{Header: header}}
+
+	subject, err := h.Subject()
+	if err != nil {
+		subject = h.Get("Subject")
+	}
+
+	date, _ := h.Date()
+	messageID, _ := h.MessageID()
+	inReplyTo, _ := h.MsgIDList("In-Reply-To")
+
+	envelope := &imap.Envelope{
+		Date:      date,
+		Subject:   subject,
+		From:      readAddressList(h, "From"),
+		Sender:    readAddressList(h, "Sender"),
+		ReplyTo:   readAddressList(h, "Reply-To"),
+		To:        readAddressList(h, "To"),
+		Cc:        readAddressList(h, "Cc"),
+		Bcc:       readAddressList(h, "Bcc"),
+		InReplyTo: inReplyTo,
+		MessageID: messageID,
+	}
+
+	// 与 IMAP 服务器相同，没有 Sender 和 Reply-To 时使用 From（RFC 3501）
+	if len(envelope.Sender) == 0 {
+		envelope.Sender = envelope.From
+	}
+
+	if len(envelope.ReplyTo) == 0 {
+		envelope.ReplyTo = envelope.From
+	}
+
+	return envelope, nil
+}
+
+func readAddressList(h mail.Header, key string) []imap.Address {
+	list, _ := h.AddressList(key)
+
+	res := make([]imap.Address, 0, len(list))
+	for _, addr := range list {
+		mailbox, host, ok := strings.Cut(addr.Address, "@")
+		if !ok {
+			continue
+		}
+
+		res = append(res, imap.Address{
+			Name:    addr.Name,
+			Mailbox: mailbox,
+			Host:    host,
+		})
+	}
+
+	return res
+}
+
+// Process 处理一封收到的邮件：检查、限流、保存并通知，然后发送感谢信
+// recipients 为邮件的收件地址，IMAP 收取的邮件使用信封中的 To，直接收取的邮件使用 RCPT TO
+// 无法处理的邮件会通过错误邮件告知发件人，不返回错误，只返回处理结果
+func Process(recipients []imap.Address, envelope *imap.Envelope, body []byte) Result {
+	return process(recipients, envelope, body, false)
+}
+
+// ProcessTooBig 拒收一封超过大小限制的邮件，body 为截断后的邮件，经过与 Process 相同的检查后通过错误邮件告知发件人
+func ProcessTooBig(recipients []imap.Address, envelope *imap.Envelope, body []byte) Result {
+	return process(recipients, envelope, body, true)
+}
+
+func process(recipients []imap.Address, envelope *imap.Envelope, body []byte, tooBig bool) Result {
+	now := time.Now().In(flagparser.TimeZone())
+
+	subject, _ := utils.ChangeDisplaySafeUTF8(envelope.Subject)
+	messageID, _ := utils.ChangeDisplaySafeUTF8(envelope.MessageID)
+	messageDate := envelope.Date.In(flagparser.TimeZone())
+
+	if len(recipients) == 0 {
+		return ResultIgnored
+	}
+
+	var st *site.Site
+	var alias *database.ReplyAlias
+	myAddr := func() *mail.Address { // to addr getter
+		for _, to := range recipients {
+			if to.Addr() == "" || !utils.IsValidEmail(to.Addr()) {
+				continue
+			}
+
+			if a, ok := relay.Match(to.Addr()); ok {
+				st = site.Get(a.SiteID)
+				alias = a
+				return &mail.Address{
+					Name:    st.Name,
+					Address: to.Addr(),
+				} // return to add getter
+			}
+
+			if s, _, ok := site.MatchRecipient(to.Addr()); ok {
+				st = s
+				return &mail.Address{
+					Name:    to.Name,
+					Address: to.Addr(),
+				} // return to add getter
+			}
+		}
+		return nil // m to addr getter
+	}()
+
+	if myAddr == nil {
+		return ResultIgnored
+	}
+
+	if m, _ := database.FindIMAPMessageID(messageID); m != nil {
+		// 消息已经处理过
+		return ResultIgnored
+	}
+
+	if envelope.Sender == nil || len(envelope.Sender) == 0 || envelope.Sender[0].Addr() == "" || !utils.IsValidEmail(envelope.Sender[0].Addr()) {
+		return ResultIgnored
+	}
+
+	userSendAddr := &mail.Address{
+		Name:    envelope.Sender[0].Name,
+		Address: envelope.Sender[0].Addr(),
+	}
+
+	var userFromAddr *mail.Address
+	if envelope.From == nil || len(envelope.From) == 0 || envelope.From[0].Addr() == "" || !utils.IsValidEmail(envelope.From[0].Addr()) {
+		userFromAddr = &mail.Address{
+			Name:    userSendAddr.Name,
+			Address: userSendAddr.Address,
+		}
+	} else {
+		userFromAddr = &mail.Address{
+			Name:    envelope.From[0].Name,
+			Address: envelope.From[0].Addr(),
+		}
+	}
+
+	var userAddr *mail.Address
+	if envelope.ReplyTo != nil && len(envelope.ReplyTo) == 0 {
+		userAddr = &mail.Address{
+			Name:    userFromAddr.Name,
+			Address: userFromAddr.Address,
+		}
+	} else {
+	ReplyToCycle:
+		for _, r := range envelope.ReplyTo {
+			if r.Addr() != "" && utils.IsValidEmail(r.Addr()) {
+				userAddr = &mail.Address{
+					Name:    r.Name,
+					Address: r.Addr(),
+				}
+				break ReplyToCycle
+			}
+		}
+
+		if userAddr == nil {
+			userAddr = &mail.Address{
+				Name:    userFromAddr.Name,
+				Address: userFromAddr.Address,
+			}
+		}
+	}
+
+	auth := mailauth.Check(body, userFromAddr.Address)
+
+	if utils.NormalizeEmailAddress(userAddr.Address) != utils.NormalizeEmailAddress(userFromAddr.Address) && !auth.AllowReplyTo(userAddr.Address) {
+		// Reply-To 不受 DKIM、DMARC 保护，与认证通过的域名不一致时改为回复发件人
+		fmt.Printf("邮件 %s 的回复地址 %s 与认证通过的域名不一致，改为回复发件人 %s\n", messageID, userAddr.Address, userFromAddr.Address)
+		userAddr = &mail.Address{
+			Name:    userFromAddr.Name,
+			Address: userFromAddr.Address,
+		}
+	}
+
+	allowReply := auth.AllowReply(userAddr.Address) // 最终的回复地址（包括发件人地址）同样需要通过认证
+	if !allowReply {
+		fmt.Printf("邮件 %s 的发件人认证未通过（%s），不发送自动回复\n", messageID, auth.String())
+	}
+
+	isMyAddr := site.IsRecipient(userAddr.Address) || relay.IsAlias(userAddr.Address)
+
+	if isMyAddr || utils.NormalizeEmailAddress(userAddr.Address) == utils.NormalizeEmailAddress(myAddr.Address) {
+		return ResultLoop // 消息不做处理，否则可能形成循环
+	}
+
+	errFunc := func(errMsg string) error {
+		if !allowReply {
+			return nil // 回复地址可能是伪造的，避免向第三方发送邮件
+		}
+
+		_, err := smtpserver.SendErrorMsg(st.ID, subject, messageID, myAddr, userAddr, errMsg)
+		if err != nil && errors.Is(err, smtpserver.ErrRateLimit) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if !reqrate.CheckIMAPRate(st.ID, envelope) {
+		_ = errFunc("信件发送速度过快、次数过多")
+		return ResultRejected
+	} else if tooBig {
+		_ = errFunc("邮件太大了，建议使用云附件哦")
+		return ResultRejected
+	}
+
+	mailmsg, err := mail.CreateReader(bytes.NewReader(body))
+	if err != nil && message.IsUnknownCharset(err) {
+		_ = errFunc(unknownCharsetMsg)
+		return ResultRejected
+	} else if err != nil {
+		_ = errFunc("无法读取邮件内容，请检查您的邮件以及其编码")
+		return ResultRejected
+	}
+	defer func() {
+		_ = mailmsg.Close()
+	}()
+
+	var contentType string
+	var mimeType string
+	var mimeParams map[string]string
+	var encoding string
+	var bodyStr string
+	var bodySafe bool
+	var files []attachment.File
+	var unknownCharset bool
+
+BodyPartCycle:
+	for {
+		p, err := mailmsg.NextPart()
+		if err == io.EOF || (err != nil && strings.Contains(strings.ToUpper(err.Error()), "EOF")) {
+			break BodyPartCycle // 遍历结束
+		} else if err != nil && !message.IsUnknownCharset(err) {
+			continue BodyPartCycle
+		}
+
+		if file, ok := readAttachment(p); ok { // 字符集无法识别的附件按原样保存
+			if file != nil {
+				files = append(files, *file)
+			}
+			continue BodyPartCycle
+		}
+
+		if err != nil {
+			unknownCharset = true // 正文部分的字符集无法识别，没有其他正文时拒收
+			continue BodyPartCycle
+		}
+
+		var _ct = strings.ToLower(p.Header.Get("Content-Type"))
+		var _ed = strings.ToLower(p.Header.Get("Content-Transfer-Encoding"))
+		_mt, _mtp, err := mime.ParseMediaType(_ct)
+		if err != nil {
+			continue BodyPartCycle
+		}
+
+		var data []byte
+
+		switch _mt {
+		case "text/html":
+			fallthrough
+		case "text/plain":
+			data, err = io.ReadAll(p.Body)
+			if err != nil {
+				continue BodyPartCycle
+			}
+		default:
+			continue BodyPartCycle
+		}
+
+		if mimeType != "text/plain" && (_mt == "text/plain" || _mt == "text/html") {
+			contentType = _ct
+			mimeType = _mt
+			mimeParams = _mtp
+			encoding = _ed
+			bodyStr = string(data)
+		}
+
+		if mimeType == "text/plain" && !attachment.Enabled() {
+			break BodyPartCycle // 保存附件时需要读完所有部分
+		}
+	}
+
+	_ = mimeParams // 目前暂时不使用 mimeParams 这里写个语句防止 not use 保存
+	_ = encoding   // 目前暂时不使用 encoding 这里写个语句防止 not use 保存
+
+	if (contentType == "" || mimeType == "") && unknownCharset {
+		_ = errFunc(unknownCharsetMsg)
+		return ResultRejected
+	} else if contentType == "" || mimeType == "" || bodyStr == "" {
+		_ = errFunc("邮件无法被读取，我们只接受 text/plain 和 text/html")
+		return ResultRejected
+	}
+
+	switch mimeType {
+	case "text/plain":
+		// ok
+	case "text/html":
+		data, err := html2text.FromString(bodyStr)
+		if err != nil {
+			_ = errFunc("邮件无法被读取，text/html 格式可能存在问题，无法被转换为纯文本，建议发送 text/plain 格式的邮件")
+			return ResultRejected
+		}
+		bodyStr = data
+	default:
+		_ = errFunc("邮件无法被读取，我们只接受 text/plain 和 text/html")
+		return ResultRejected
+	}
+
+	bodyStr = strings.ReplaceAll(bodyStr, "\r\n", "\n")
+	bodyStr = strings.TrimLeft(bodyStr, "\n")
+	bodyStr = strings.TrimRight(bodyStr, "\n")
+	bodyStr = strings.TrimSpace(bodyStr)
+
+	if bodyStr == "" {
+		_ = errFunc("邮件内容为空")
+		return ResultRejected
+	}
+
+	bodyStr, bodySafe = utils.ChangeDisplaySafeUTF8(bodyStr)
+	if bodyStr == "" {
+		_ = errFunc("邮件存在不安全因素")
+		return ResultRejected
+	} else if maxlimit.StringTooBig(bodyStr) {
+		_ = errFunc("邮件太大了，建议使用云附件哦")
+		return ResultRejected
+	}
+
+	if alias != nil && st.IsNoticeAddress(userFromAddr.Address) {
+		// 管理员通过回复别名回复留言，转发给留言人
+		if !auth.AllowReply(userFromAddr.Address) || !auth.AllowReplyTo(userFromAddr.Address) {
+			return ResultRejected // 伪造管理员地址的邮件不能转发给留言人
+		}
+		return forwardReply(alias, userFromAddr, bodyStr, errFunc)
+	}
+
+	mailID := utils.GetIMAPMailID(messageID, userSendAddr.String(), userFromAddr.String(), myAddr.String(), userAddr.String(), subject, bodyStr, messageDate, now)
+
+	initchan := make(chan bool)
+	var attachments []notifier.Attachment
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _err, ok := r.(error); ok {
+					fmt.Printf("数据库提交消息出现致命错误: %s\n", _err.Error())
+				} else {
+					fmt.Printf("数据库提交消息出现致命错误（非error）: %v\n", r)
+				}
+			}
+		}()
+
+		defer close(initchan)
+
+		err := sender.IMAPDataBase(st.ID, mailID, messageID, userSendAddr.String(), userFromAddr.String(), myAddr.String(), userAddr.String(), subject, bodyStr, string(auth.SPF), string(auth.DKIM), string(auth.DMARC), messageDate, now)
+		if err != nil {
+			return
+		}
+
+		attachments, err = attachment.Save(mailID, files, now)
+		if err != nil {
+			fmt.Printf("保存附件出现错误: %s\n", err.Error())
+		}
+
+		err = attachment.SaveOriginal(mailID, body, now)
+		if err != nil {
+			fmt.Printf("保存原始邮件出现错误: %s\n", err.Error())
+		}
+	}()
+
+	go func() {
+		<-initchan
+
+		fields := []notifier.Field{
+			{Name: "主题", Value: subject},
+			{Name: "邮件 MessageID", Value: messageID},
+			{Name: "发送人", Value: utils.FormatEmailAddressToHumanStringMustSafe(userSendAddr)},
+			{Name: "宣称发送人", Value: utils.FormatEmailAddressToHumanStringMustSafe(userFromAddr)},
+			{Name: "回复地址", Value: utils.FormatEmailAddressToHumanStringMustSafe(userAddr)},
+			{Name: "收件人", Value: utils.FormatEmailAddressToHumanStringMustSafe(myAddr)},
+			{Name: "邮件日期", Value: fmt.Sprintf("%s %s", messageDate.Format("2006-01-02 15:04:05"), messageDate.Location().String())},
+		}
+
+		if !st.IsDefault() {
+			fields = append(fields, notifier.Field{Name: "所属站点", Value: st.Label()})
+		}
+
+		if alias != nil {
+			fields = append(fields, notifier.Field{Name: "回复的信件", Value: alias.MailID})
+		}
+
+		if len(attachments) > 0 {
+			fields = append(fields, notifier.Field{Name: "附件", Value: attachment.Describe(attachments)})
+		}
+
+		fields = append(fields, notifier.Field{Name: "发件人认证", Value: auth.String()})
+
+		if bodySafe {
+			fields = append(fields, notifier.Field{Name: "邮件内容是否安全", Value: "是"})
+		} else {
+			fields = append(fields, notifier.Field{Name: "邮件内容是否安全", Value: "否，已处理"})
+		}
+
+		notifier.SendAll(notifier.Notification{
+			SiteID:  st.ID,
+			Type:    database.MsgTypeEmail,
+			MailID:  mailID,
+			Time:    now,
+			Subject: sender.IMAPSubject(subject, utils.FormatEmailAddressToHumanStringJustNameMustSafe(userFromAddr)),
+			Fields:  fields,
+			Content: bodyStr,
+			Meta: notifier.Meta{
+				Name:        utils.FormatEmailAddressToHumanStringJustNameMustSafe(userFromAddr),
+				Email:       userAddr.Address,
+				Subject:     subject,
+				MessageID:   messageID,
+				Sender:      userSendAddr.String(),
+				From:        userFromAddr.String(),
+				ReplyTo:     userAddr.String(),
+				To:          myAddr.String(),
+				SendTime:    messageDate,
+				SPF:         string(auth.SPF),
+				DKIM:        string(auth.DKIM),
+				DMARC:       string(auth.DMARC),
+				NameSafe:    true,
+				ContentSafe: bodySafe,
+			},
+			Attachments: attachments,
+		})
+	}()
+
+	if alias != nil {
+		return ResultAccepted // 通过回复别名发来的邮件是会话中的回复，不发送感谢信
+	} else if !allowReply {
+		return ResultAccepted
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _err, ok := r.(error); ok {
+					fmt.Printf("感谢信-邮件发送消息出现致命错误: %s\n", _err.Error())
+					if err != nil {
+						err = _err
+						return
+					}
+				} else {
+					fmt.Printf("感谢信-邮件发送消息出现致命错误（非error）: %v\n", r)
+					if err != nil {
+						err = fmt.Errorf("%v", r)
+						return
+					}
+				}
+			}
+		}()
+
+		<-initchan
+
+		smtpID, _ := smtpserver.SendThankMsg(st.ID, subject, messageID, myAddr, userAddr)
+		_ = database.UpdateIMAPThankEmailSendMsg(mailID, smtpID)
+	}()
+
+	return ResultAccepted
+}
+
+// readAttachment 附件和非文本的内嵌部分（例如图片）作为附件读取，超过大小限制时只记录名字和大小
+// 返回 false 表示这是正文部分；没有设置 --attachment-path 时不读取附件
+func readAttachment(p *mail.Part) (*attachment.File, bool) {
+	if !attachment.Enabled() {
+		return nil, false
+	}
+
+	var name string
+	switch h := p.Header.(type) {
+	case *mail.AttachmentHeader:
+		name, _ = h.Filename()
+	case *mail.InlineHeader:
+		mt, params, err := h.ContentType()
+		if err != nil || strings.HasPrefix(mt, "text/") || strings.HasPrefix(mt, "multipart/") {
+			return nil, false
+		}
+		name = params["name"]
+	default:
+		return nil, false
+	}
+
+	contentType, _, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if err != nil || contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	name, _ = utils.ChangeDisplaySafeUTF8(name)
+	if name == "" {
+		name = "attachment"
+	}
+
+	data, err := io.ReadAll(io.LimitReader(p.Body, attachment.MaxSize()+1))
+	if err != nil {
+		return nil, true
+	}
+
+	size := int64(len(data))
+	if size > attachment.MaxSize() {
+		n, err := io.Copy(io.Discard, p.Body)
+		if err != nil {
+			return nil, true
+		}
+		size += n
+		data = nil
+	}
+
+	return &attachment.File{
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		Data:        data,
+	}, true
+}
+
+// forwardReply 把管理员发往回复别名的邮件转发给留言人，失败时通过 errFunc 告知管理员
+func forwardReply(alias *database.ReplyAlias, adminAddr *mail.Address, body string, errFunc func(errMsg string) error) Result {
+	_, err := reply.Send(alias.MailID, "", relay.StripQuote(body), "email:"+adminAddr.Address)
+	if err == nil {
+		return ResultAccepted
+	}
+
+	fmt.Printf("转发回复出现错误: %s\n", err.Error())
+
+	switch {
+	case errors.Is(err, reply.ErrEmptyContent):
+		_ = errFunc("回复内容为空（引用的原邮件不会被转发）")
+	case errors.Is(err, reply.ErrContentTooLong):
+		_ = errFunc("回复内容太长")
+	case errors.Is(err, reply.ErrNoAddress):
+		_ = errFunc("留言人没有留下有效的邮箱地址，无法回复")
+	case errors.Is(err, reply.ErrRateLimit):
+		_ = errFunc("回复过于频繁，请稍后再试")
+	default:
+		_ = errFunc("转发回复失败，请稍后在管理页面重试")
+	}
+
+	return ResultRejected
+}
@@ -0,0 +1,336 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSignatures 一封邮件最多验证的 DKIM 签名数量
+const MaxSignatures = 5
+
+// dkimError 验证失败的原因，result 为 fail、permerror 或 temperror
+type dkimError struct {
+	result Result
+	msg    string
+}
+
+func (e *dkimError) Error() string {
+	return e.msg
+}
+
+func failf(result Result, format string, a ...any) error {
+	return &dkimError{result: result, msg: fmt.Sprintf(format, a...)}
+}
+
+type dkimSignature struct {
+	algorithm string
+	signature []byte
+	bodyHash  []byte
+	header    string // 头部的规范化算法
+	body      string // 正文的规范化算法
+	domain    string
+	selector  string
+	headers   []string
+	length    int64 // l= 正文长度，-1 表示没有设置
+	expire    int64 // x= 过期时间，0 表示没有设置
+}
+
+// verifyDKIM 验证邮件中所有的 DKIM-Signature（RFC 6376），有一个通过即为通过，返回结果和通过验证的签名域名
+func verifyDKIM(header []headerField, body []byte) (Result, string) {
+	var res Result
+	count := 0
+
+	for i, f := range header {
+		if f.name != "dkim-signature" {
+			continue
+		}
+
+		count += 1
+		if count > MaxSignatures {
+			break
+		}
+
+		domain, err := verifySignature(header, i, body)
+		if err == nil {
+			return ResultPass, domain
+		}
+
+		var e *dkimError
+		if errors.As(err, &e) {
+			res = better(res, e.result)
+		} else {
+			res = better(res, ResultPermError)
+		}
+	}
+
+	if res == "" {
+		return ResultNone, ""
+	}
+	return res, ""
+}
+
+func verifySignature(header []headerField, index int, body []byte) (string, error) {
+	sig, err := parseSignature(header[index].value())
+	if err != nil {
+		return "", err
+	}
+
+	if sig.expire != 0 && time.Now().Unix() > sig.expire {
+		return "", failf(ResultFail, "signature expired")
+	}
+
+	canonBody := canonicalBody(body, sig.body)
+	if sig.length >= 0 {
+		if sig.length > int64(len(canonBody)) {
+			return "", failf(ResultFail, "body is shorter than l=")
+		}
+		canonBody = canonBody[:sig.length]
+	}
+
+	bodyHash := sha256.Sum256(canonBody)
+	if string(bodyHash[:]) != string(sig.bodyHash) {
+		return "", failf(ResultFail, "body hash did not verify")
+	}
+
+	hasher := sha256.New()
+	used := make(map[int]bool, len(sig.headers))
+	for _, name := range sig.headers {
+		for i := len(header) - 1; i >= 0; i-- { // 同名的头部从下往上依次使用
+			if used[i] || i == index || header[i].name != name {
+				continue
+			}
+			used[i] = true
+			hasher.Write([]byte(canonicalHeader(header[i].raw, sig.header)))
+			break
+		}
+	}
+
+	self := canonicalHeader(removeSignature(header[index].raw), sig.header)
+	hasher.Write([]byte(strings.TrimSuffix(self, "\r\n")))
+	hashed := hasher.Sum(nil)
+
+	key, err := lookupKey(sig)
+	if err != nil {
+		return "", err
+	}
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed, sig.signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, hashed, sig.signature) {
+			err = fmt.Errorf("ed25519 verification failed")
+		}
+	default:
+		return "", failf(ResultPermError, "unsupported key type")
+	}
+	if err != nil {
+		return "", failf(ResultFail, "signature did not verify: %s", err.Error())
+	}
+
+	return sig.domain, nil
+}
+
+func parseTags(value string) map[string]string {
+	res := make(map[string]string, 12)
+	for _, tag := range strings.Split(value, ";") {
+		k, v, ok := strings.Cut(tag, "=")
+		if !ok {
+			continue
+		}
+		res[strings.TrimSpace(k)] = strings.Join(strings.Fields(v), "") // 去掉折行和空白
+	}
+	return res
+}
+
+func parseSignature(value string) (*dkimSignature, error) {
+	tags := parseTags(value)
+
+	if tags["v"] != "1" {
+		return nil, failf(ResultPermError, "unsupported version")
+	}
+
+	sig := &dkimSignature{
+		algorithm: strings.ToLower(tags["a"]),
+		domain:    strings.ToLower(tags["d"]),
+		selector:  tags["s"],
+		header:    "simple",
+		body:      "simple",
+		length:    -1,
+	}
+
+	if sig.algorithm != "rsa-sha256" && sig.algorithm != "ed25519-sha256" {
+		return nil, failf(ResultPermError, "unsupported algorithm %s", sig.algorithm)
+	} else if sig.domain == "" || sig.selector == "" {
+		return nil, failf(ResultPermError, "missing domain or selector")
+	}
+
+	var err error
+	sig.signature, err = base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil || len(sig.signature) == 0 {
+		return nil, failf(ResultPermError, "invalid signature")
+	}
+
+	sig.bodyHash, err = base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil || len(sig.bodyHash) == 0 {
+		return nil, failf(ResultPermError, "invalid body hash")
+	}
+
+	if c := strings.ToLower(tags["c"]); c != "" {
+		h, b, ok := strings.Cut(c, "/")
+		sig.header = h
+		if ok {
+			sig.body = b
+		}
+	}
+	if (sig.header != "simple" && sig.header != "relaxed") || (sig.body != "simple" && sig.body != "relaxed") {
+		return nil, failf(ResultPermError, "unsupported canonicalization")
+	}
+
+	hasFrom := false
+	for _, h := range strings.Split(tags["h"], ":") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" {
+			sig.headers = append(sig.headers, h)
+		}
+		hasFrom = hasFrom || h == "from"
+	}
+	if !hasFrom {
+		return nil, failf(ResultPermError, "from is not signed")
+	}
+
+	if l, ok := tags["l"]; ok {
+		sig.length, err = strconv.ParseInt(l, 10, 64)
+		if err != nil || sig.length < 0 {
+			return nil, failf(ResultPermError, "invalid body length")
+		}
+	}
+
+	if x, ok := tags["x"]; ok {
+		sig.expire, err = strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return nil, failf(ResultPermError, "invalid expiration")
+		}
+	}
+
+	if i := strings.ToLower(tags["i"]); i != "" {
+		_, domain, _ := strings.Cut(i, "@")
+		if domain != sig.domain && !strings.HasSuffix(domain, "."+sig.domain) {
+			return nil, failf(ResultPermError, "identity does not match domain")
+		}
+	}
+
+	return sig, nil
+}
+
+func lookupKey(sig *dkimSignature) (crypto.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), LookupTimeout)
+	defer cancel()
+
+	txt, err := resolver.LookupTXT(ctx, sig.selector+"._domainkey."+sig.domain)
+	var dnsErr *net.DNSError
+	if err != nil && errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, failf(ResultPermError, "no key for signature")
+	} else if err != nil {
+		return nil, failf(ResultTempError, "key lookup failed: %s", err.Error())
+	} else if len(txt) == 0 {
+		return nil, failf(ResultPermError, "no key for signature")
+	}
+
+	tags := parseTags(strings.Join(txt, ""))
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, failf(ResultPermError, "unsupported key version")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(tags["p"])
+	if err != nil {
+		return nil, failf(ResultPermError, "invalid key")
+	} else if len(data) == 0 {
+		return nil, failf(ResultPermError, "key revoked")
+	}
+
+	keyType := strings.ToLower(tags["k"])
+	switch {
+	case (keyType == "" || keyType == "rsa") && sig.algorithm == "rsa-sha256":
+		if pub, err := x509.ParsePKIXPublicKey(data); err == nil {
+			if rsaPub, ok := pub.(*rsa.PublicKey); ok {
+				return rsaPub, nil
+			}
+			return nil, failf(ResultPermError, "key is not rsa")
+		}
+
+		pub, err := x509.ParsePKCS1PublicKey(data)
+		if err != nil {
+			return nil, failf(ResultPermError, "invalid rsa key")
+		}
+		return pub, nil
+	case keyType == "ed25519" && sig.algorithm == "ed25519-sha256":
+		if len(data) != ed25519.PublicKeySize {
+			return nil, failf(ResultPermError, "invalid ed25519 key")
+		}
+		return ed25519.PublicKey(data), nil
+	default:
+		return nil, failf(ResultPermError, "key type does not match algorithm")
+	}
+}
+
+var signatureValue = regexp.MustCompile(`(^|;)(\s*b\s*=)[^;]*`)
+
+// removeSignature 把 DKIM-Signature 中 b= 的值置空，用于计算头部的哈希
+func removeSignature(raw string) string {
+	i := strings.Index(raw, ":")
+	return raw[:i+1] + signatureValue.ReplaceAllString(raw[i+1:], "$1$2")
+}
+
+var wsp = regexp.MustCompile(`[ \t]+`)
+
+func canonicalHeader(raw string, algorithm string) string {
+	if algorithm == "simple" {
+		return raw
+	}
+
+	i := strings.Index(raw, ":")
+	name := strings.ToLower(strings.TrimRight(raw[:i], " \t"))
+	value := strings.ReplaceAll(raw[i+1:], "\r\n", "")
+	value = strings.Trim(wsp.ReplaceAllString(value, " "), " ")
+	return name + ":" + value + "\r\n"
+}
+
+func canonicalBody(body []byte, algorithm string) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	if algorithm == "relaxed" {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(wsp.ReplaceAllString(line, " "), " ")
+		}
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1] // 去掉结尾的空行
+	}
+
+	if len(lines) == 0 {
+		if algorithm == "relaxed" {
+			return nil
+		}
+		return []byte("\r\n")
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
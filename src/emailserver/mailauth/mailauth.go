@@ -0,0 +1,198 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailauth
+
+import (
+	"context"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	PolicyNone    = "none"     // 只记录认证结果
+	PolicyNoReply = "no-reply" // 认证失败的邮件不发送感谢信和错误邮件
+	PolicyStrict  = "strict"   // 只有认证通过的邮件才发送感谢信和错误邮件
+)
+
+// Result 单项认证的结果，取值与 Authentication-Results 相同
+type Result string
+
+const (
+	ResultNone      Result = "none"
+	ResultPass      Result = "pass"
+	ResultFail      Result = "fail"
+	ResultSoftFail  Result = "softfail"
+	ResultNeutral   Result = "neutral"
+	ResultTempError Result = "temperror"
+	ResultPermError Result = "permerror"
+)
+
+// Results 一封邮件的 SPF、DKIM、DMARC 认证结果
+type Results struct {
+	SPF        Result
+	DKIM       Result
+	DMARC      Result
+	DKIMDomain string // 通过验证的 DKIM 签名所属的域名
+	FromDomain string // 发件人（From）的域名
+}
+
+// Resolver 查询 DKIM 公钥使用的 DNS 解析器，可以替换为其他实现
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// LookupTimeout 查询一个 DKIM 公钥的超时时间
+const LookupTimeout = 10 * time.Second
+
+var resolver Resolver = net.DefaultResolver
+var trustedServID []string
+
+func InitMailAuth() error {
+	switch flagparser.AuthPolicy {
+	case PolicyNone, PolicyNoReply, PolicyStrict:
+	default:
+		return fmt.Errorf("auth policy must be %s, %s or %s", PolicyNone, PolicyNoReply, PolicyStrict)
+	}
+
+	trustedServID = nil
+	for _, id := range strings.Split(flagparser.AuthServID, ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id != "" {
+			trustedServID = append(trustedServID, id)
+		}
+	}
+
+	if flagparser.ReceiverAddress != "" && len(trustedServID) == 0 {
+		// 内置收件服务器直接接收 MTA 投递的邮件，最上方的 Authentication-Results 可能就是发件人伪造的
+		return fmt.Errorf("auth serv id must be set when the receiver is enabled")
+	}
+
+	if flagparser.AuthDNS != "" {
+		server := flagparser.AuthDNS
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+
+		SetResolver(&net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		})
+	}
+
+	return nil
+}
+
+// SetResolver 替换查询 DKIM 公钥使用的 DNS 解析器
+func SetResolver(r Resolver) {
+	resolver = r
+}
+
+// Check 读取可信的 Authentication-Results 并在本地验证 DKIM 签名，body 为完整的原始邮件，from 为发件人地址
+func Check(body []byte, from string) Results {
+	header, content := splitMessage(body)
+	fromDomain := from[strings.LastIndex(from, "@")+1:]
+
+	res := authResults(header)
+	res.FromDomain = strings.ToLower(fromDomain)
+
+	local, domain := verifyDKIM(header, content)
+	if local == ResultPass || res.DKIM == "" {
+		res.DKIM = local
+		res.DKIMDomain = domain
+	}
+
+	if res.DMARC == "" && res.DKIM == ResultPass && aligned(res.DKIMDomain, fromDomain) {
+		res.DMARC = ResultPass // 与发件人域名一致的 DKIM 签名通过验证即满足 DMARC
+	}
+
+	if res.SPF == "" {
+		res.SPF = ResultNone
+	}
+	if res.DKIM == "" {
+		res.DKIM = ResultNone
+	}
+	if res.DMARC == "" {
+		res.DMARC = ResultNone
+	}
+
+	return res
+}
+
+// Passed 发件人通过认证：DMARC 通过，或者没有 DMARC 失败且有与发件人域名对齐的 DKIM 签名通过验证
+// SPF 只认证信封发件人，不能证明 From 的真实性
+func (r Results) Passed() bool {
+	if r.DMARC == ResultPass {
+		return true
+	} else if r.DMARC == ResultFail {
+		return false
+	}
+	return r.DKIM == ResultPass && aligned(r.DKIMDomain, r.FromDomain)
+}
+
+// Failed 认证失败：DMARC 失败，或者没有任何一项通过且 SPF、DKIM 其中一项失败
+func (r Results) Failed() bool {
+	if r.DMARC == ResultFail {
+		return true
+	} else if r.Passed() {
+		return false
+	}
+	return r.SPF == ResultFail || r.SPF == ResultSoftFail || r.DKIM == ResultFail || r.DKIM == ResultPermError
+}
+
+// AllowReply 根据 --auth-policy 判断是否可以向 address 发送感谢信、错误邮件或转发管理员的回复
+// strict 时 address 本身也必须与认证通过的域名对齐，即使它就是发件人地址
+func (r Results) AllowReply(address string) bool {
+	switch flagparser.AuthPolicy {
+	case PolicyNoReply:
+		return !r.Failed()
+	case PolicyStrict:
+		return r.Passed() && r.Authenticated(address)
+	default:
+		return true
+	}
+}
+
+// Authenticated 地址的域名与认证通过的域名对齐：DKIM 通过时的签名域名，或者 DMARC 通过时的发件人域名
+func (r Results) Authenticated(address string) bool {
+	domain := address[strings.LastIndex(address, "@")+1:]
+	if address == "" || domain == "" {
+		return false
+	} else if r.DKIM == ResultPass && aligned(r.DKIMDomain, domain) {
+		return true
+	}
+	return r.DMARC == ResultPass && aligned(r.FromDomain, domain)
+}
+
+// AllowReplyTo 根据 --auth-policy 判断是否可以把自动回复发送到该地址，policy 不为 none 时只允许与认证通过的域名对齐的地址
+func (r Results) AllowReplyTo(address string) bool {
+	if flagparser.AuthPolicy == PolicyNone {
+		return true
+	}
+	return r.Authenticated(address)
+}
+
+func (r Results) String() string {
+	dkim := string(r.DKIM)
+	if r.DKIMDomain != "" {
+		dkim = fmt.Sprintf("%s（%s）", r.DKIM, r.DKIMDomain)
+	}
+	return fmt.Sprintf("SPF：%s，DKIM：%s，DMARC：%s", r.SPF, dkim, r.DMARC)
+}
+
+// aligned 签名域名与发件人域名相同，或者其中一个是另一个的子域名（宽松对齐）
+func aligned(domain string, fromDomain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	fromDomain = strings.ToLower(strings.TrimSuffix(fromDomain, "."))
+	if !strings.Contains(domain, ".") || fromDomain == "" {
+		return false // 顶级域名的签名不算对齐
+	}
+	return domain == fromDomain || strings.HasSuffix(fromDomain, "."+domain) || strings.HasSuffix(domain, "."+fromDomain)
+}
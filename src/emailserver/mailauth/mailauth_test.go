@@ -0,0 +1,389 @@
+package mailauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"net"
+	"strings"
+	"testing"
+)
+
+// stubResolver 测试使用的 DNS 解析器，err 不为空时所有查询都返回该错误
+type stubResolver struct {
+	txt map[string][]string
+	err error
+}
+
+func (r *stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	txt, ok := r.txt[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return txt, nil
+}
+
+func TestParseAuthResults(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		servID string
+		items  []resultItem
+	}{
+		{
+			name:   "all methods",
+			value:  " mx.example.com; spf=pass smtp.mailfrom=a@b.com; dkim=pass header.d=b.com; dmarc=pass header.from=b.com",
+			servID: "mx.example.com",
+			items: []resultItem{
+				{method: "spf", result: ResultPass, props: map[string]string{"smtp.mailfrom": "a@b.com"}},
+				{method: "dkim", result: ResultPass, props: map[string]string{"header.d": "b.com"}},
+				{method: "dmarc", result: ResultPass, props: map[string]string{"header.from": "b.com"}},
+			},
+		},
+		{
+			name:   "comments, version and quoted value",
+			value:  " MX.Example.com 1; dkim/1=PASS (good; signature) header.d=\"b.com\"",
+			servID: "mx.example.com",
+			items: []resultItem{
+				{method: "dkim", result: ResultPass, props: map[string]string{"header.d": "b.com"}},
+			},
+		},
+		{
+			name:   "no result",
+			value:  " mx.example.com; none",
+			servID: "mx.example.com",
+		},
+		{
+			name:   "hardfail and unknown result",
+			value:  " mx.example.com; spf=hardfail; dkim=whatever",
+			servID: "mx.example.com",
+			items: []resultItem{
+				{method: "spf", result: ResultFail, props: map[string]string{}},
+				{method: "dkim", result: ResultNeutral, props: map[string]string{}},
+			},
+		},
+		{
+			name:   "semicolon in comment",
+			value:  " mx.example.com (a; b); dmarc=fail (p=reject; dis=none)",
+			servID: "mx.example.com",
+			items: []resultItem{
+				{method: "dmarc", result: ResultFail, props: map[string]string{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servID, items := parseAuthResults(tt.value)
+			if servID != tt.servID {
+				t.Fatalf("serv id = %q, want %q", servID, tt.servID)
+			}
+			if len(items) != len(tt.items) {
+				t.Fatalf("items = %v, want %v", items, tt.items)
+			}
+			for i, item := range items {
+				want := tt.items[i]
+				if item.method != want.method || item.result != want.result || fmt.Sprint(item.props) != fmt.Sprint(want.props) {
+					t.Fatalf("item %d = %v, want %v", i, item, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthResults(t *testing.T) {
+	message := "Authentication-Results: mx.example.com; spf=fail; dmarc=fail\r\n" +
+		"Authentication-Results: mx.example.com; spf=pass; dkim=pass header.d=b.com; dmarc=pass\r\n" +
+		"Authentication-Results: forged.example.com; dmarc=pass\r\n" +
+		"From: a@b.com\r\n\r\nbody\r\n"
+
+	tests := []struct {
+		name    string
+		trusted []string
+		want    Results
+	}{
+		{name: "topmost header", want: Results{SPF: ResultFail, DMARC: ResultFail}},
+		{name: "topmost trusted header", trusted: []string{"mx.example.com"}, want: Results{SPF: ResultFail, DMARC: ResultFail}},
+		{name: "trusted header below", trusted: []string{"forged.example.com"}, want: Results{DMARC: ResultPass}},
+		{name: "no trusted header", trusted: []string{"other.example.com"}, want: Results{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trustedServID = tt.trusted
+			defer func() { trustedServID = nil }()
+
+			header, _ := splitMessage([]byte(message))
+			if res := authResults(header); res != tt.want {
+				t.Fatalf("results = %+v, want %+v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetter(t *testing.T) {
+	tests := []struct {
+		a    Result
+		b    Result
+		want Result
+	}{
+		{"", ResultFail, ResultFail},
+		{ResultFail, "", ResultFail},
+		{ResultFail, ResultPermError, ResultPermError},
+		{ResultPermError, ResultSoftFail, ResultSoftFail},
+		{ResultSoftFail, ResultTempError, ResultTempError},
+		{ResultTempError, ResultNone, ResultNone},
+		{ResultNone, ResultNeutral, ResultNeutral},
+		{ResultNeutral, ResultPass, ResultPass},
+		{ResultPass, ResultFail, ResultPass},
+	}
+
+	for _, tt := range tests {
+		if res := better(tt.a, tt.b); res != tt.want {
+			t.Errorf("better(%q, %q) = %q, want %q", tt.a, tt.b, res, tt.want)
+		}
+	}
+}
+
+func TestAligned(t *testing.T) {
+	tests := []struct {
+		domain     string
+		fromDomain string
+		want       bool
+	}{
+		{"example.com", "example.com", true},
+		{"Example.COM.", "example.com", true},
+		{"example.com", "mail.example.com", true},
+		{"mail.example.com", "example.com", true},
+		{"attacker.example", "bank.example", false},
+		{"notexample.com", "example.com", false},
+		{"com", "example.com", false},
+		{"", "example.com", false},
+		{"example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		if res := aligned(tt.domain, tt.fromDomain); res != tt.want {
+			t.Errorf("aligned(%q, %q) = %v, want %v", tt.domain, tt.fromDomain, res, tt.want)
+		}
+	}
+}
+
+// sign 按 RFC 6376 为 message 添加 DKIM-Signature，tags 为除 b= 以外的所有标签，以空的 bh= 结尾
+func sign(t *testing.T, key crypto.Signer, tags string, message string) string {
+	t.Helper()
+
+	header, body := splitMessage([]byte(message))
+	sig, err := parseSignature(tags + "AA==; b=AA==") // bh= 为最后一个标签，先用占位值解析
+	if err != nil {
+		t.Fatalf("parse signature: %s", err.Error())
+	}
+
+	bh := sha256.Sum256(canonicalBody(body, sig.body))
+	tags = strings.Replace(tags, "bh=", "bh="+base64.StdEncoding.EncodeToString(bh[:]), 1)
+	raw := "DKIM-Signature: " + tags + "; b="
+
+	hasher := sha256.New()
+	used := make(map[int]bool, len(sig.headers))
+	for _, name := range sig.headers {
+		for i := len(header) - 1; i >= 0; i-- {
+			if !used[i] && header[i].name == name {
+				used[i] = true
+				hasher.Write([]byte(canonicalHeader(header[i].raw, sig.header)))
+				break
+			}
+		}
+	}
+	hasher.Write([]byte(strings.TrimSuffix(canonicalHeader(raw+"\r\n", sig.header), "\r\n")))
+	hashed := hasher.Sum(nil)
+
+	var b []byte
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		b = ed25519.Sign(k, hashed)
+	case *rsa.PrivateKey:
+		b, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hashed)
+		if err != nil {
+			t.Fatalf("sign: %s", err.Error())
+		}
+	}
+
+	return raw + base64.StdEncoding.EncodeToString(b) + "\r\n" + message
+}
+
+func TestVerifyDKIM(t *testing.T) {
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dns := &stubResolver{txt: map[string][]string{
+		"ed._domainkey.b.com":      {"v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub)},
+		"rsa._domainkey.b.com":     {"v=DKIM1; k=rsa; ", "p=" + base64.StdEncoding.EncodeToString(rsaPub)},
+		"revoked._domainkey.b.com": {"v=DKIM1; p="},
+	}}
+
+	const message = "From: Alice <alice@b.com>\r\nTo: support@example.com\r\nSubject:  Hello   world\r\n\r\nHello,\r\n\r\nworld  \r\n\r\n\r\n"
+	const edTags = "v=1; a=ed25519-sha256; c=relaxed/relaxed; d=b.com; s=ed; h=from:to:subject; bh="
+	const rsaTags = "v=1; a=rsa-sha256; c=simple/simple; d=b.com; s=rsa; h=from:subject; bh="
+
+	tests := []struct {
+		name     string
+		message  string
+		resolver Resolver
+		result   Result
+		domain   string
+	}{
+		{name: "no signature", message: message, result: ResultNone},
+		{name: "ed25519 relaxed", message: sign(t, edKey, edTags, message), result: ResultPass, domain: "b.com"},
+		{name: "rsa simple", message: sign(t, rsaKey, rsaTags, message), result: ResultPass, domain: "b.com"},
+		{
+			name:    "relaxed tolerates whitespace changes",
+			message: strings.Replace(sign(t, edKey, edTags, message), "Subject:  Hello   world", "Subject: Hello world", 1),
+			result:  ResultPass,
+			domain:  "b.com",
+		},
+		{
+			name:    "simple rejects whitespace changes",
+			message: strings.Replace(sign(t, rsaKey, rsaTags, message), "Subject:  Hello   world", "Subject: Hello world", 1),
+			result:  ResultFail,
+		},
+		{
+			name:    "body changed",
+			message: strings.Replace(sign(t, edKey, edTags, message), "world  \r\n", "attacker\r\n", 1),
+			result:  ResultFail,
+		},
+		{
+			name:    "from changed",
+			message: strings.Replace(sign(t, edKey, edTags, message), "alice@b.com", "mallory@b.com", 1),
+			result:  ResultFail,
+		},
+		{
+			name:    "from not signed",
+			message: strings.Replace(sign(t, edKey, edTags, message), "h=from:to:subject", "h=to:subject", 1),
+			result:  ResultPermError,
+		},
+		{
+			name:    "no key",
+			message: sign(t, edKey, strings.Replace(edTags, "s=ed", "s=missing", 1), message),
+			result:  ResultPermError,
+		},
+		{
+			name:    "revoked key",
+			message: sign(t, edKey, strings.Replace(edTags, "s=ed", "s=revoked", 1), message),
+			result:  ResultPermError,
+		},
+		{
+			name:     "dns failure",
+			message:  sign(t, edKey, edTags, message),
+			resolver: &stubResolver{err: &net.DNSError{Err: "timeout", IsTimeout: true}},
+			result:   ResultTempError,
+		},
+		{
+			name:    "key type mismatch",
+			message: sign(t, edKey, strings.Replace(edTags, "s=ed", "s=rsa", 1), message),
+			result:  ResultPermError,
+		},
+	}
+
+	defer SetResolver(net.DefaultResolver)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.resolver != nil {
+				SetResolver(tt.resolver)
+			} else {
+				SetResolver(dns)
+			}
+
+			header, body := splitMessage([]byte(tt.message))
+			result, domain := verifyDKIM(header, body)
+			if result != tt.result || domain != tt.domain {
+				t.Fatalf("verify = %q %q, want %q %q", result, domain, tt.result, tt.domain)
+			}
+		})
+	}
+}
+
+func TestAllowReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     Results
+		address string
+		policy  string
+		want    bool
+	}{
+		{
+			name:    "aligned dkim",
+			res:     Results{SPF: ResultNone, DKIM: ResultPass, DMARC: ResultNone, DKIMDomain: "b.com", FromDomain: "b.com"},
+			address: "alice@b.com", policy: PolicyStrict, want: true,
+		},
+		{
+			name:    "unaligned dkim with forged from",
+			res:     Results{SPF: ResultNone, DKIM: ResultPass, DMARC: ResultNone, DKIMDomain: "attacker.example", FromDomain: "bank.example"},
+			address: "victim@bank.example", policy: PolicyStrict, want: false,
+		},
+		{
+			name:    "spf only",
+			res:     Results{SPF: ResultPass, DKIM: ResultNone, DMARC: ResultNone, FromDomain: "bank.example"},
+			address: "victim@bank.example", policy: PolicyStrict, want: false,
+		},
+		{
+			name:    "dmarc pass",
+			res:     Results{SPF: ResultPass, DKIM: ResultNone, DMARC: ResultPass, FromDomain: "b.com"},
+			address: "alice@b.com", policy: PolicyStrict, want: true,
+		},
+		{
+			name:    "dmarc pass with other reply domain",
+			res:     Results{SPF: ResultPass, DKIM: ResultNone, DMARC: ResultPass, FromDomain: "b.com"},
+			address: "victim@bank.example", policy: PolicyStrict, want: false,
+		},
+		{
+			name:    "dmarc fail",
+			res:     Results{SPF: ResultPass, DKIM: ResultPass, DMARC: ResultFail, DKIMDomain: "b.com", FromDomain: "b.com"},
+			address: "alice@b.com", policy: PolicyNoReply, want: false,
+		},
+		{
+			name:    "no result",
+			res:     Results{SPF: ResultNone, DKIM: ResultNone, DMARC: ResultNone, FromDomain: "b.com"},
+			address: "alice@b.com", policy: PolicyNoReply, want: true,
+		},
+		{
+			name:    "spf fail",
+			res:     Results{SPF: ResultFail, DKIM: ResultNone, DMARC: ResultNone, FromDomain: "b.com"},
+			address: "alice@b.com", policy: PolicyNoReply, want: false,
+		},
+		{
+			name:    "none policy",
+			res:     Results{SPF: ResultFail, DKIM: ResultFail, DMARC: ResultFail, FromDomain: "b.com"},
+			address: "alice@b.com", policy: PolicyNone, want: true,
+		},
+	}
+
+	defer func(policy string) { flagparser.AuthPolicy = policy }(flagparser.AuthPolicy)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagparser.AuthPolicy = tt.policy
+			if res := tt.res.AllowReply(tt.address); res != tt.want {
+				t.Fatalf("allow reply = %v, want %v", res, tt.want)
+			}
+		})
+	}
+}
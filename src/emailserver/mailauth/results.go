@@ -0,0 +1,229 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mailauth
+
+import (
+	"bytes"
+	"strings"
+)
+
+// headerField 原始的邮件头，raw 包含折行和结尾的 CRLF，DKIM 的 simple 规范化需要使用
+type headerField struct {
+	name string
+	raw  string
+}
+
+func (f headerField) value() string {
+	i := strings.Index(f.raw, ":")
+	if i < 0 {
+		return ""
+	}
+	return f.raw[i+1:]
+}
+
+// splitMessage 按原样拆分邮件头和正文，只有 LF 的换行统一为 CRLF
+func splitMessage(body []byte) ([]headerField, []byte) {
+	if !bytes.Contains(body, []byte("\r\n")) {
+		body = bytes.ReplaceAll(body, []byte("\n"), []byte("\r\n"))
+	}
+
+	var header []headerField
+	rest := body
+
+	for len(rest) > 0 {
+		if bytes.HasPrefix(rest, []byte("\r\n")) {
+			return header, rest[2:]
+		}
+
+		end := 0
+		for {
+			i := bytes.Index(rest[end:], []byte("\r\n"))
+			if i < 0 {
+				end = len(rest)
+				break
+			}
+			end += i + 2
+			if end >= len(rest) || (rest[end] != ' ' && rest[end] != '\t') {
+				break // 下一行不是折行
+			}
+		}
+
+		raw := string(rest[:end])
+		rest = rest[end:]
+
+		i := strings.Index(raw, ":")
+		if i <= 0 {
+			continue
+		}
+		header = append(header, headerField{
+			name: strings.ToLower(strings.TrimSpace(raw[:i])),
+			raw:  raw,
+		})
+	}
+
+	return header, nil
+}
+
+// authResults 读取可信的 Authentication-Results（RFC 8601）
+// 只读取最上方（即最后添加）的一个，设置了 --auth-serv-id 时为这些服务器添加的最上方的一个，发件人伪造的结果在其下方
+func authResults(header []headerField) Results {
+	var res Results
+
+	for _, f := range header {
+		if f.name != "authentication-results" {
+			continue
+		}
+
+		servID, items := parseAuthResults(f.value())
+		if len(trustedServID) > 0 && !containsID(trustedServID, servID) {
+			continue
+		}
+
+		for _, item := range items {
+			switch item.method {
+			case "spf":
+				res.SPF = better(res.SPF, item.result)
+			case "dkim":
+				if res.DKIM != ResultPass && item.result == ResultPass {
+					res.DKIMDomain = item.props["header.d"]
+				}
+				res.DKIM = better(res.DKIM, item.result)
+			case "dmarc":
+				res.DMARC = better(res.DMARC, item.result)
+			}
+		}
+
+		break
+	}
+
+	return res
+}
+
+type resultItem struct {
+	method string
+	result Result
+	props  map[string]string
+}
+
+// parseAuthResults 返回 authserv-id 和各项结果，忽略注释和无法识别的部分
+func parseAuthResults(value string) (string, []resultItem) {
+	parts := strings.Split(stripComments(value), ";")
+
+	servID := ""
+	if fields := strings.Fields(parts[0]); len(fields) > 0 {
+		servID = strings.ToLower(fields[0])
+	}
+
+	items := make([]resultItem, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+
+		method, result, ok := strings.Cut(fields[0], "=")
+		if !ok {
+			continue // 例如 none
+		}
+
+		method, _, _ = strings.Cut(method, "/") // 方法的版本号
+		item := resultItem{
+			method: strings.ToLower(method),
+			result: normalize(result),
+			props:  make(map[string]string, len(fields)-1),
+		}
+
+		for _, prop := range fields[1:] {
+			k, v, ok := strings.Cut(prop, "=")
+			if ok {
+				item.props[strings.ToLower(k)] = strings.Trim(v, `"`)
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return servID, items
+}
+
+func stripComments(s string) string {
+	var b strings.Builder
+	depth := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' && depth == 0:
+			quoted = !quoted
+		case c == '\\' && (quoted || depth > 0) && i+1 < len(s):
+			if depth == 0 {
+				b.WriteByte(c)
+				b.WriteByte(s[i+1])
+			}
+			i++
+			continue
+		case c == '(' && !quoted:
+			depth++
+			continue
+		case c == ')' && !quoted && depth > 0:
+			depth--
+			continue
+		}
+
+		if depth == 0 {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func normalize(result string) Result {
+	switch r := Result(strings.ToLower(strings.TrimSpace(result))); r {
+	case ResultNone, ResultPass, ResultFail, ResultSoftFail, ResultNeutral, ResultTempError, ResultPermError:
+		return r
+	case "hardfail":
+		return ResultFail
+	default:
+		return ResultNeutral
+	}
+}
+
+// better 同一项有多个结果时（例如多个 DKIM 签名）取最好的一个
+func better(a Result, b Result) Result {
+	rank := func(r Result) int {
+		switch r {
+		case ResultPass:
+			return 6
+		case ResultNeutral:
+			return 5
+		case ResultNone:
+			return 4
+		case ResultTempError:
+			return 3
+		case ResultSoftFail:
+			return 2
+		case ResultPermError:
+			return 1
+		case ResultFail:
+			return 0
+		default:
+			return -1 // 没有结果
+		}
+	}
+
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+func containsID(list []string, id string) bool {
+	for _, i := range list {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -6,13 +6,17 @@ package emailserver
 
 import (
 	"fmt"
-	"github.com/SongZihuan/anonymous-message/src/emailserver/emailaddress"
+	"github.com/SongZihuan/anonymous-message/src/attachment"
 	"github.com/SongZihuan/anonymous-message/src/emailserver/imapserver"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/mailauth"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/pop3server"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/receiver"
 	"github.com/SongZihuan/anonymous-message/src/emailserver/smtpserver"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/tpl"
 )
 
 var ready = false
-var imapStopChannel chan bool
+var stopChannel chan bool
 
 func InitEmailSystem() (err error) {
 	defer func() {
@@ -31,7 +35,31 @@ func InitEmailSystem() (err error) {
 		return err
 	}
 
-	err = emailaddress.InitGlobalAddress()
+	err = pop3server.InitPOP3()
+	if err != nil {
+		return err
+	}
+
+	err = receiver.InitReceiver()
+	if err != nil {
+		return err
+	}
+
+	err = attachment.InitAttachment()
+	if err != nil {
+		return err
+	}
+
+	err = mailauth.InitMailAuth()
+	if err != nil {
+		return err
+	}
+
+	if !imapserver.Enabled() && !pop3server.Enabled() && !receiver.Enabled() {
+		return fmt.Errorf("no inbound mail service, please set --imap-address, --pop3-address or --receiver-address")
+	}
+
+	err = tpl.InitTemplate()
 	if err != nil {
 		return err
 	}
@@ -39,34 +67,72 @@ func InitEmailSystem() (err error) {
 	return nil
 }
 
+// StartEmailServer 启动IMAP、POP3收信和内置收件服务，任一服务结束时返回的 channel 关闭
 func StartEmailServer() (chan bool, error) {
 	if !ready {
 		return nil, fmt.Errorf("email server is not ready")
-	} else if imapStopChannel != nil {
+	} else if stopChannel != nil {
 		return nil, fmt.Errorf("email server is running")
 	}
 
-	imapStopChannel = make(chan bool)
+	stopChannel = make(chan bool)
 
-	imapchan, err := imapserver.StartIMAPServer(imapStopChannel)
-	if err != nil {
-		close(imapStopChannel)
-		imapStopChannel = nil
-		return nil, err
+	var imapchan, pop3chan, receiverchan chan bool // 未启用的服务为 nil，不会被选中
+
+	if imapserver.Enabled() {
+		var err error
+		imapchan, err = imapserver.StartIMAPServer(stopChannel)
+		if err != nil {
+			close(stopChannel)
+			stopChannel = nil
+			return nil, err
+		}
+	}
+
+	if pop3server.Enabled() {
+		var err error
+		pop3chan, err = pop3server.StartPOP3Server(stopChannel)
+		if err != nil {
+			close(stopChannel)
+			stopChannel = nil
+			return nil, err
+		}
 	}
 
-	return imapchan, nil
+	if receiver.Enabled() {
+		var err error
+		receiverchan, err = receiver.StartReceiver(stopChannel)
+		if err != nil {
+			close(stopChannel)
+			stopChannel = nil
+			return nil, err
+		}
+	}
+
+	emailchan := make(chan bool)
+
+	go func() {
+		defer close(emailchan)
+
+		select {
+		case <-imapchan:
+		case <-pop3chan:
+		case <-receiverchan:
+		}
+	}()
+
+	return emailchan, nil
 }
 
 func StopEmailServer() error {
 	if !ready {
 		return fmt.Errorf("email server is not ready")
-	} else if imapStopChannel == nil {
+	} else if stopChannel == nil {
 		return fmt.Errorf("email server is not runned")
 	}
 
-	close(imapStopChannel)
-	imapStopChannel = nil
+	close(stopChannel)
+	stopChannel = nil
 
 	return nil
 }
@@ -0,0 +1,439 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pop3server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/inbound"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/maxlimit"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultPOP3CycleTime = 1 * time.Minute
+const DefaultPOP3Timeout = 2 * time.Minute
+
+const (
+	TLSModeTLS      = "tls"
+	TLSModeStartTLS = "starttls"
+	TLSModeInsecure = "insecure"
+	TLSModeAuto     = "auto"
+)
+
+func InitPOP3() error {
+	if !Enabled() {
+		return nil
+	} else if !database.Ready() {
+		return fmt.Errorf("pop3 requires sqlite to remember the fetched mail, please set --sqlite-path")
+	} else if flagparser.POP3DeleteAfter < 0 {
+		return fmt.Errorf("pop3 delete after must not be negative")
+	}
+
+	switch flagparser.POP3TLS {
+	case TLSModeTLS, TLSModeStartTLS, TLSModeInsecure, TLSModeAuto:
+	default:
+		return fmt.Errorf("unknown pop3 tls mode: %s (tls, starttls, insecure or auto)", flagparser.POP3TLS)
+	}
+
+	if flagparser.POP3User == "" && flagparser.POP3Password == "" {
+		flagparser.POP3User = flagparser.SMTPUser
+		flagparser.POP3Password = flagparser.SMTPPassword
+	} else if flagparser.POP3Password == "" && flagparser.SMTPUser == flagparser.POP3User {
+		flagparser.POP3Password = flagparser.SMTPPassword
+	}
+
+	if flagparser.POP3User == "" {
+		return fmt.Errorf("pop3 is not ready")
+	}
+
+	return nil
+}
+
+func Enabled() bool {
+	return flagparser.POP3Address != ""
+}
+
+// StartPOP3Server 定时收取 POP3 邮箱中的新邮件，stopchan 关闭时停止
+func StartPOP3Server(stopchan chan bool) (chan bool, error) {
+	if !Enabled() || flagparser.POP3User == "" {
+		return nil, fmt.Errorf("pop3 is not ready")
+	}
+
+	pop3chan := make(chan bool)
+
+	go func() {
+		defer close(pop3chan)
+
+		fmt.Printf("POP3 服务启动: %s\n", flagparser.POP3Address)
+
+		for {
+			err := poll()
+			if err != nil {
+				fmt.Printf("收取 POP3 邮件出现错误: %s\n", err.Error())
+			}
+
+			select {
+			case <-stopchan:
+				fmt.Printf("POP3 服务结束\n")
+				return
+			case <-time.After(DefaultPOP3CycleTime):
+			}
+		}
+	}()
+
+	return pop3chan, nil
+}
+
+// account 数据库中区分不同邮箱的名字
+func account() string {
+	return flagparser.POP3User + "@" + flagparser.POP3Address
+}
+
+// poll 收取一次：处理未见过的邮件，删除超过保留天数的邮件
+func poll() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	c, err := dial(flagparser.POP3Address, flagparser.POP3TLS)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	err = c.login(flagparser.POP3User, flagparser.POP3Password)
+	if err != nil {
+		return err
+	}
+
+	list, err := c.uidl()
+	if err != nil {
+		return err
+	}
+
+	seen, err := database.FindPOP3Message(account())
+	if err != nil {
+		return err
+	}
+
+	// 第一次收取（没有任何记录）时只处理服务器一天内收到的邮件，避免处理整个邮箱
+	// 之后的收取处理所有新邮件，不论停机多久或者邮件的 Date 是什么
+	first := len(seen) == 0
+
+	now := time.Now()
+	onServer := make(map[string]bool, len(list)+1)
+	onServer[initUIDL] = true
+	dele := make([]int, 0, 10)
+
+	for _, m := range list {
+		onServer[m.uidl] = true
+
+		if t, ok := seen[m.uidl]; ok {
+			if flagparser.POP3DeleteAfter > 0 && now.Sub(t) >= time.Duration(flagparser.POP3DeleteAfter)*24*time.Hour {
+				dele = append(dele, m.num)
+			}
+			continue
+		}
+
+		body, tooBig, err := c.retr(m.num)
+		if err != nil {
+			return err
+		}
+
+		process(body, tooBig, first, now)
+
+		err = database.SavePOP3Message(account(), m.uidl, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if first {
+		// 邮箱为空时也要记录，否则下一次收取仍会被当作第一次
+		err = database.SavePOP3Message(account(), initUIDL, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	gone := make([]string, 0, 10)
+	for uidl := range seen {
+		if !onServer[uidl] {
+			gone = append(gone, uidl)
+		}
+	}
+
+	err = database.DeletePOP3Message(account(), gone)
+	if err != nil {
+		return err
+	}
+
+	for _, num := range dele {
+		_, err = c.cmd("DELE %d", num)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.quit() // 服务端在 QUIT 后才真正删除邮件
+}
+
+// initUIDL 记录账号已经完成第一次收取，UIDL 不会为空
+const initUIDL = ""
+
+func process(body []byte, tooBig bool, first bool, now time.Time) {
+	if first {
+		t, ok := receivedTime(body)
+		if !ok || t.Before(now.Add(-1*24*time.Hour)) {
+			return // 第一次收取时的旧邮件只记录不处理
+		}
+	}
+
+	envelope, err := inbound.ReadEnvelope(body)
+	if err != nil {
+		fmt.Printf("POP3 邮件无法读取，已跳过: %s\n", err.Error())
+		return
+	}
+
+	if tooBig {
+		fmt.Printf("POP3 邮件 %s 超过 %d 字节，已拒收\n", envelope.MessageID, maxlimit.MAX_BYTES_LIMIT)
+		inbound.ProcessTooBig(envelope.To, envelope, body)
+		return
+	}
+
+	inbound.Process(envelope.To, envelope, body)
+}
+
+// receivedTime 最上方的 Received 头中的时间，由收件服务器添加，不受发件人控制
+func receivedTime(body []byte) (time.Time, bool) {
+	msg, err := mail.ReadMessage(bytes.NewReader(body))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	received := msg.Header["Received"]
+	if len(received) == 0 {
+		return time.Time{}, false
+	}
+
+	i := strings.LastIndex(received[0], ";")
+	if i < 0 {
+		return time.Time{}, false
+	}
+
+	t, err := mail.ParseDate(strings.TrimSpace(received[0][i+1:]))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+type client struct {
+	conn *textproto.Conn
+	raw  net.Conn
+}
+
+type message struct {
+	num  int
+	uidl string
+}
+
+// dial 根据 mode 连接服务器：tls 直接使用 TLS，starttls 明文连接后通过 STLS 升级，auto 依次尝试两者
+// 只有 insecure 使用明文连接，其他方式无法建立加密连接时返回错误，不会退回明文，避免密码被明文发送
+func dial(address string, mode string) (*client, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	switch mode {
+	case TLSModeTLS:
+		return dialTLS(dialer, address, host)
+	case TLSModeStartTLS:
+		return dialStartTLS(dialer, address, host)
+	case TLSModeInsecure:
+		return dialInsecure(dialer, address)
+	}
+
+	c, err := dialTLS(dialer, address, host)
+	if err != nil {
+		c, err = dialStartTLS(dialer, address, host)
+	}
+	return c, err
+}
+
+func dialTLS(dialer *net.Dialer, address string, host string) (*client, error) {
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host})
+	if err != nil {
+		return nil, fmt.Errorf("connect pop3 server with tls failed: %s", err.Error())
+	}
+
+	c := newClient(conn)
+	if _, err = c.response(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func dialStartTLS(dialer *net.Dialer, address string, host string) (*client, error) {
+	c, err := dialInsecure(dialer, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = c.cmd("STLS"); err != nil {
+		c.close()
+		return nil, fmt.Errorf("pop3 server does not support stls: %s", err.Error())
+	}
+
+	conn := tls.Client(c.raw, &tls.Config{ServerName: host})
+	if err = conn.Handshake(); err != nil {
+		c.close()
+		return nil, fmt.Errorf("pop3 stls failed: %s", err.Error())
+	}
+
+	return newClient(conn), nil
+}
+
+func dialInsecure(dialer *net.Dialer, address string) (*client, error) {
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("connect pop3 server failed: %s", err.Error())
+	}
+
+	c := newClient(conn)
+	if _, err = c.response(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func newClient(conn net.Conn) *client {
+	return &client{
+		conn: textproto.NewConn(conn),
+		raw:  conn,
+	}
+}
+
+func (c *client) close() {
+	_ = c.conn.Close()
+}
+
+// response 读取单行响应，-ERR 作为错误返回
+func (c *client) response() (string, error) {
+	_ = c.raw.SetDeadline(time.Now().Add(DefaultPOP3Timeout))
+
+	line, err := c.conn.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(line, "+OK") {
+		return "", fmt.Errorf("pop3 server error: %s", line)
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
+
+func (c *client) cmd(format string, args ...any) (string, error) {
+	_ = c.raw.SetDeadline(time.Now().Add(DefaultPOP3Timeout))
+
+	err := c.conn.PrintfLine(format, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return c.response()
+}
+
+func (c *client) login(user string, password string) error {
+	_, err := c.cmd("USER %s", user)
+	if err != nil {
+		return fmt.Errorf("login pop3 server failed: %s", err.Error())
+	}
+
+	_, err = c.cmd("PASS %s", password)
+	if err != nil {
+		return fmt.Errorf("login pop3 server failed: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (c *client) uidl() ([]message, error) {
+	_, err := c.cmd("UIDL")
+	if err != nil {
+		return nil, fmt.Errorf("pop3 server does not support UIDL: %s", err.Error())
+	}
+
+	lines, err := c.conn.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]message, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		num, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+
+		res = append(res, message{num: num, uidl: fields[1]})
+	}
+
+	return res, nil
+}
+
+// retr 读取整封邮件，邮件太大时返回截断后的邮件，并且 tooBig 为 true
+func (c *client) retr(num int) (body []byte, tooBig bool, err error) {
+	_, err = c.cmd("RETR %d", num)
+	if err != nil {
+		return nil, false, err
+	}
+
+	reader := c.conn.DotReader()
+	body, err = io.ReadAll(io.LimitReader(reader, maxlimit.MAX_BYTES_LIMIT+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if maxlimit.DataTooBig(body) {
+		_, err = io.Copy(io.Discard, reader)
+		if err != nil {
+			return nil, false, err
+		}
+		return body, true, nil
+	}
+
+	return body, false, nil
+}
+
+func (c *client) quit() error {
+	_, err := c.cmd("QUIT")
+	return err
+}
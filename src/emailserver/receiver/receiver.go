@@ -0,0 +1,359 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package receiver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/inbound"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/maxlimit"
+	"github.com/emersion/go-imap/v2"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ProtocolSMTP = "smtp"
+	ProtocolLMTP = "lmtp"
+)
+
+const DefaultCommandTimeout = 5 * time.Minute
+const DefaultDataTimeout = 10 * time.Minute
+const MaxRecipients = 100
+
+var protocol = ProtocolSMTP
+var hostname = "localhost"
+
+// InitReceiver 检查内置收件服务的配置，未设置 --receiver-address 时不启用
+func InitReceiver() error {
+	if !Enabled() {
+		return nil
+	}
+
+	switch p := strings.ToLower(strings.TrimSpace(flagparser.ReceiverProtocol)); p {
+	case ProtocolSMTP, ProtocolLMTP:
+		protocol = p
+	default:
+		return fmt.Errorf("unknown receiver protocol: %s (smtp or lmtp)", flagparser.ReceiverProtocol)
+	}
+
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hostname = name
+	}
+
+	return nil
+}
+
+func Enabled() bool {
+	return flagparser.ReceiverAddress != ""
+}
+
+// StartReceiver 监听 SMTP 或 LMTP，接收发往站点收件地址和回复别名的邮件，stopchan 关闭时停止
+func StartReceiver(stopchan chan bool) (chan bool, error) {
+	if !Enabled() {
+		return nil, fmt.Errorf("receiver is not enabled")
+	}
+
+	listener, err := net.Listen("tcp", flagparser.ReceiverAddress)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %s", flagparser.ReceiverAddress, err.Error())
+	}
+
+	receiverchan := make(chan bool)
+
+	go func() {
+		<-stopchan
+		_ = listener.Close()
+	}()
+
+	go func() {
+		defer close(receiverchan)
+
+		fmt.Printf("邮件接收服务（%s）启动于: %s\n", strings.ToUpper(protocol), flagparser.ReceiverAddress)
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil && errors.Is(err, net.ErrClosed) {
+				break
+			} else if err != nil {
+				fmt.Printf("邮件接收服务接受连接出现错误: %s\n", err.Error())
+				time.Sleep(1 * time.Second)
+				continue
+			}
+
+			go serve(conn)
+		}
+
+		fmt.Printf("邮件接收服务结束\n")
+	}()
+
+	return receiverchan, nil
+}
+
+type session struct {
+	conn *textproto.Conn
+	raw  net.Conn
+	helo bool
+	from *string // 为空表示还没有收到 MAIL 命令，空字符串表示退信地址 <>
+	rcpt []string
+}
+
+func serve(conn net.Conn) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("邮件接收服务出现致命错误: %v\n", r)
+		}
+	}()
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	s := &session{
+		conn: textproto.NewConn(conn),
+		raw:  conn,
+	}
+
+	s.reply(220, fmt.Sprintf("%s %s %s ready", hostname, strings.ToUpper(protocol), flagparser.Name))
+
+	for {
+		_ = conn.SetDeadline(time.Now().Add(DefaultCommandTimeout))
+
+		line, err := s.conn.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		arg = strings.TrimSpace(arg)
+
+		switch cmd = strings.ToUpper(cmd); {
+		case cmd == "LHLO" && protocol == ProtocolLMTP, (cmd == "HELO" || cmd == "EHLO") && protocol == ProtocolSMTP:
+			s.hello(cmd, arg)
+		case cmd == "MAIL":
+			s.mail(arg)
+		case cmd == "RCPT":
+			s.recipient(arg)
+		case cmd == "DATA":
+			if !s.data() {
+				return
+			}
+		case cmd == "RSET":
+			s.reset()
+			s.reply(250, "2.0.0 OK")
+		case cmd == "NOOP":
+			s.reply(250, "2.0.0 OK")
+		case cmd == "VRFY":
+			s.reply(252, "2.5.2 Cannot VRFY user")
+		case cmd == "QUIT":
+			s.reply(221, "2.0.0 Bye")
+			return
+		default:
+			s.reply(500, "5.5.2 Unknown command")
+		}
+	}
+}
+
+func (s *session) reply(code int, msg string) {
+	_ = s.conn.PrintfLine("%d %s", code, msg)
+}
+
+func (s *session) reset() {
+	s.from = nil
+	s.rcpt = nil
+}
+
+func (s *session) hello(cmd string, arg string) {
+	if arg == "" {
+		s.reply(501, "5.5.4 Domain name required")
+		return
+	}
+
+	s.reset()
+	s.helo = true
+
+	if cmd == "HELO" {
+		s.reply(250, hostname)
+		return
+	}
+
+	lines := []string{hostname, "PIPELINING", "8BITMIME", "ENHANCEDSTATUSCODES", "SIZE " + strconv.Itoa(maxlimit.MAX_BYTES_LIMIT)}
+	for i, l := range lines {
+		if i == len(lines)-1 {
+			_ = s.conn.PrintfLine("250 %s", l)
+		} else {
+			_ = s.conn.PrintfLine("250-%s", l)
+		}
+	}
+}
+
+func (s *session) mail(arg string) {
+	if !s.helo {
+		s.reply(503, "5.5.1 Send HELO first")
+		return
+	} else if s.from != nil {
+		s.reply(503, "5.5.1 Nested MAIL command")
+		return
+	}
+
+	from, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		s.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	for _, param := range strings.Fields(params) {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(key, "SIZE") {
+			size, err := strconv.Atoi(value)
+			if err == nil && size > maxlimit.MAX_BYTES_LIMIT {
+				s.reply(552, "5.3.4 Message size exceeds fixed limit")
+				return
+			}
+		}
+	}
+
+	s.from = &from
+	s.reply(250, "2.1.0 OK")
+}
+
+func (s *session) recipient(arg string) {
+	if s.from == nil {
+		s.reply(503, "5.5.1 Need MAIL command")
+		return
+	}
+
+	to, _, ok := parsePath(arg, "TO:")
+	if !ok || to == "" {
+		s.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	} else if len(s.rcpt) >= MaxRecipients {
+		s.reply(452, "4.5.3 Too many recipients")
+		return
+	} else if !inbound.IsRecipient(to) {
+		s.reply(550, "5.1.1 No such user here")
+		return
+	}
+
+	s.rcpt = append(s.rcpt, to)
+	s.reply(250, "2.1.5 OK")
+}
+
+// data 读取并处理邮件，连接出错时返回 false
+func (s *session) data() bool {
+	if s.from == nil || len(s.rcpt) == 0 {
+		s.reply(503, "5.5.1 Need RCPT command")
+		return true
+	}
+
+	s.reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+	_ = s.raw.SetDeadline(time.Now().Add(DefaultDataTimeout))
+
+	reader := s.conn.DotReader()
+	body, err := io.ReadAll(io.LimitReader(reader, maxlimit.MAX_BYTES_LIMIT+1))
+	if err != nil {
+		return false
+	}
+
+	defer s.reset()
+
+	if maxlimit.DataTooBig(body) {
+		_, err = io.Copy(io.Discard, reader)
+		if err != nil {
+			return false
+		}
+		s.replyEach(552, "5.3.4 Message size exceeds fixed limit")
+		return true
+	}
+
+	envelope, err := inbound.ReadEnvelope(body)
+	if err != nil {
+		s.replyEach(554, "5.6.0 Malformed message")
+		return true
+	}
+
+	recipients := make([]imap.Address, 0, len(s.rcpt))
+	for _, rcpt := range s.rcpt {
+		recipients = append(recipients, recipientAddress(rcpt, envelope))
+	}
+
+	err = process(recipients, envelope, body)
+	if err != nil {
+		fmt.Printf("邮件接收服务处理邮件出现错误: %s\n", err.Error())
+		s.replyEach(451, "4.3.0 Error processing message")
+		return true
+	}
+
+	s.replyEach(250, "2.0.0 OK")
+	return true
+}
+
+// replyEach LMTP 需要对每个收件人分别回复结果，SMTP 只回复一次
+func (s *session) replyEach(code int, msg string) {
+	if protocol != ProtocolLMTP {
+		s.reply(code, msg)
+		return
+	}
+
+	for range s.rcpt {
+		s.reply(code, msg)
+	}
+}
+
+func process(recipients []imap.Address, envelope *imap.Envelope, body []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	inbound.Process(recipients, envelope, body)
+	return nil
+}
+
+// parsePath 解析 MAIL FROM:<...> 和 RCPT TO:<...>，返回地址和其后的参数
+func parsePath(arg string, prefix string) (string, string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", "", false
+	}
+
+	end := strings.Index(arg, ">")
+	if end < 0 {
+		return "", "", false
+	}
+
+	path := arg[1:end]
+	if i := strings.LastIndex(path, ":"); strings.HasPrefix(path, "@") && i >= 0 {
+		path = path[i+1:] // 忽略源路由
+	}
+
+	return path, strings.TrimSpace(arg[end+1:]), true
+}
+
+// recipientAddress 收件地址以 RCPT TO 为准，名字取自邮件头中相同的地址
+func recipientAddress(rcpt string, envelope *imap.Envelope) imap.Address {
+	mailbox, host, _ := strings.Cut(rcpt, "@")
+	res := imap.Address{Mailbox: mailbox, Host: host}
+
+	for _, to := range envelope.To {
+		if strings.EqualFold(to.Addr(), rcpt) {
+			res.Name = to.Name
+			break
+		}
+	}
+
+	return res
+}
@@ -8,22 +8,39 @@ import (
 	"errors"
 	"fmt"
 	"github.com/SongZihuan/anonymous-message/src/database"
-	"github.com/SongZihuan/anonymous-message/src/emailserver/emailaddress"
 	"github.com/SongZihuan/anonymous-message/src/emailserver/tpl"
 	"github.com/SongZihuan/anonymous-message/src/flagparser"
 	"github.com/SongZihuan/anonymous-message/src/reqrate"
+	"github.com/SongZihuan/anonymous-message/src/site"
 	"github.com/SongZihuan/anonymous-message/src/utils"
 	"gopkg.in/gomail.v2"
+	"io"
+	"mime"
 	"net"
 	"net/mail"
 	"net/smtp"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
 var ErrRateLimit = fmt.Errorf("rate limit")
 
+// Thread 邮件的会话信息，用于邮件客户端将回复归为同一会话
+type Thread struct {
+	MessageID  string   // 本邮件的 Message-ID，为空时不设置
+	InReplyTo  string   // 所回复邮件的 Message-ID
+	References []string // 会话中此前所有邮件的 Message-ID
+}
+
+func replyThread(messageID string) Thread {
+	if messageID == "" {
+		return Thread{}
+	}
+	return Thread{InReplyTo: messageID, References: []string{messageID}}
+}
+
 var ready = false
 
 func InitSmtp() (err error) {
@@ -40,14 +57,29 @@ func InitSmtp() (err error) {
 	return nil
 }
 
-func SendToSelf(subject string, msg string, t time.Time) (string, error) {
+// Attachment 邮件的附件，Path 为磁盘上的文件；Data 不为空时直接使用 Data 作为附件内容
+type Attachment struct {
+	Name        string
+	Path        string
+	ContentType string // 为空时根据扩展名判断
+	Data        []byte
+}
+
+// SendToSelf 发送通知邮件，replyTo 为空时回复地址为站点地址，attachments 会附加到邮件中
+func SendToSelf(siteID string, subject string, msg string, replyTo *mail.Address, attachments []Attachment, t time.Time) (string, error) {
 	if !ready {
 		return "", fmt.Errorf("smtp not ready")
 	}
 
-	subject = fmt.Sprintf("【%s 消息提醒】 %s", flagparser.Name, subject)
+	st := site.Get(siteID)
+	subject = fmt.Sprintf("【%s 消息提醒】 %s", st.Name, subject)
 
-	smtpID, err := sendTo(subject, msg, emailaddress.DefaultRecipientAddress, emailaddress.DefaultRecipientAddress, emailaddress.DefaultRecipientAddress, emailaddress.NoticeAddressList, "", t)
+	myAddr := st.Address()
+	if replyTo == nil {
+		replyTo = myAddr
+	}
+
+	smtpID, err := sendTo(subject, msg, myAddr, myAddr, replyTo, st.NoticeAddressList, Thread{}, attachments, t)
 	if err != nil {
 		return "", err
 	}
@@ -56,32 +88,33 @@ func SendToSelf(subject string, msg string, t time.Time) (string, error) {
 }
 
 // SendThankMsg myAddr 代表我方（From） userAddr 代表对方（To） 由我方发往对方
-func SendThankMsg(subject string, messageID string, myAddr *mail.Address, userAddr *mail.Address) (string, error) {
+func SendThankMsg(siteID string, subject string, messageID string, myAddr *mail.Address, userAddr *mail.Address) (string, error) {
 	if !ready {
 		return "", fmt.Errorf("smtp not ready")
 	}
 
-	if !reqrate.CheckSMTPSendAddressRate(reqrate.SMTPSendTypeError, userAddr) {
+	if !reqrate.CheckSMTPSendAddressRate(siteID, reqrate.SMTPSendTypeError, userAddr) {
 		return "", ErrRateLimit
 	}
 
 	now := time.Now()
-	myAddr.Name = flagparser.Name
+	st := site.Get(siteID)
+	myAddr.Name = st.Name
 
 	data := &tpl.ImapThankEmailModel{
 		UserAddr:     userAddr.Address,
 		UserName:     utils.FormatEmailAddressToHumanStringJustName(userAddr),
 		MyNameAddr:   utils.FormatEmailAddressToHumanStringMustSafe(myAddr),
 		MyAddr:       myAddr.Address,
-		MyName:       flagparser.Name,
+		MyName:       st.Name,
 		Date:         now.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05"),
 		DateLocation: flagparser.TimeZone().String(),
 		DateUTC:      now.In(time.UTC).Format("2006-01-02 15:04:05"),
-		WebURL:       flagparser.WebURL,
+		WebURL:       st.WebURL,
 	}
 
 	var tplResult bytes.Buffer
-	err := tpl.ImapThankEmail.Execute(&tplResult, data)
+	err := tpl.ImapThankEmail(siteID).Execute(&tplResult, data)
 	if err != nil {
 		return "", err
 	}
@@ -91,7 +124,7 @@ func SendThankMsg(subject string, messageID string, myAddr *mail.Address, userAd
 		subject = "Re: " + subject
 	}
 
-	smtpID, err := sendTo(subject, msg, myAddr, myAddr, myAddr, []*mail.Address{userAddr}, messageID, now)
+	smtpID, err := sendTo(subject, msg, myAddr, myAddr, myAddr, []*mail.Address{userAddr}, replyThread(messageID), nil, now)
 	if err != nil {
 		return smtpID, err
 	}
@@ -100,7 +133,7 @@ func SendThankMsg(subject string, messageID string, myAddr *mail.Address, userAd
 }
 
 // SendErrorMsg myAddr 代表我方（From） userAddr 代表对方（To） 由我方发往对方
-func SendErrorMsg(subject string, messageID string, myAddr *mail.Address, userAddr *mail.Address, errorMsg string) (string, error) {
+func SendErrorMsg(siteID string, subject string, messageID string, myAddr *mail.Address, userAddr *mail.Address, errorMsg string) (string, error) {
 	if !ready {
 		return "", fmt.Errorf("smtp not ready")
 	}
@@ -109,28 +142,29 @@ func SendErrorMsg(subject string, messageID string, myAddr *mail.Address, userAd
 		return "", fmt.Errorf("error msg is empty")
 	}
 
-	if !reqrate.CheckSMTPSendAddressRate(reqrate.SMTPSendTypeError, userAddr) {
+	if !reqrate.CheckSMTPSendAddressRate(siteID, reqrate.SMTPSendTypeError, userAddr) {
 		return "", ErrRateLimit
 	}
 
 	now := time.Now()
-	myAddr.Name = flagparser.Name
+	st := site.Get(siteID)
+	myAddr.Name = st.Name
 
 	data := &tpl.ImapErrorEmailModel{
 		UserAddr:     userAddr.Address,
 		UserName:     utils.FormatEmailAddressToHumanStringJustName(userAddr),
 		MyNameAddr:   utils.FormatEmailAddressToHumanStringMustSafe(myAddr),
 		MyAddr:       myAddr.Address,
-		MyName:       flagparser.Name,
+		MyName:       st.Name,
 		ErrorMsg:     errorMsg,
 		Date:         now.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05"),
 		DateLocation: flagparser.TimeZone().String(),
 		DateUTC:      now.In(time.UTC).Format("2006-01-02 15:04:05"),
-		WebURL:       flagparser.WebURL,
+		WebURL:       st.WebURL,
 	}
 
 	var tplResult bytes.Buffer
-	err := tpl.ImapErrorEmail.Execute(&tplResult, data)
+	err := tpl.ImapErrorEmail(siteID).Execute(&tplResult, data)
 	if err != nil {
 		return "", err
 	}
@@ -140,7 +174,30 @@ func SendErrorMsg(subject string, messageID string, myAddr *mail.Address, userAd
 		subject = "Re: " + subject
 	}
 
-	smtpID, err := sendTo(subject, msg, myAddr, myAddr, myAddr, []*mail.Address{userAddr}, messageID, now)
+	smtpID, err := sendTo(subject, msg, myAddr, myAddr, myAddr, []*mail.Address{userAddr}, replyThread(messageID), nil, now)
+	if err != nil {
+		return smtpID, err
+	}
+
+	return smtpID, nil
+}
+
+// SendReply 管理员回复留言，由站点地址发往 userAddr，thread 为回复所在的会话，replyTo 为空时回复地址为站点地址
+func SendReply(siteID string, subject string, msg string, thread Thread, replyTo *mail.Address, userAddr *mail.Address) (string, error) {
+	if !ready {
+		return "", fmt.Errorf("smtp not ready")
+	}
+
+	if !reqrate.CheckSMTPSendAddressRate(siteID, reqrate.SMTPSendTypeReply, userAddr) {
+		return "", ErrRateLimit
+	}
+
+	myAddr := site.Get(siteID).Address()
+	if replyTo == nil {
+		replyTo = myAddr
+	}
+
+	smtpID, err := sendTo(subject, msg, myAddr, myAddr, replyTo, []*mail.Address{userAddr}, thread, nil, time.Now())
 	if err != nil {
 		return smtpID, err
 	}
@@ -148,9 +205,60 @@ func SendErrorMsg(subject string, messageID string, myAddr *mail.Address, userAd
 	return smtpID, nil
 }
 
+// NewMessageID 根据 id 生成本系统发出邮件的 Message-ID
+func NewMessageID(id string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(flagparser.SMTPUser, "@"); ok && d != "" {
+		domain = d
+	}
+
+	if len(id) > 32 {
+		id = id[:32]
+	}
+
+	return fmt.Sprintf("<%s@%s>", id, domain)
+}
+
+// attachmentName 去掉附件名称中的控制字符、引号和反斜杠，附件名称来自发件人，不能直接写入邮件头
+func attachmentName(name string) string {
+	name, _ = utils.ChangeDisplaySafeUTF8(name)
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case r == '"' || r == '\\':
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		name = "attachment"
+	}
+
+	return name
+}
+
+// attachmentHeader 附件的 Content-Type 和 Content-Disposition，gomail 不会编码附件名称，非 ASCII 的名称使用 Q 编码
+func attachmentHeader(name string, contentType string) map[string][]string {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	encoded := mime.QEncoding.Encode("utf-8", name)
+	return map[string][]string{
+		"Content-Type":        {fmt.Sprintf("%s; name=\"%s\"", contentType, encoded)},
+		"Content-Disposition": {fmt.Sprintf("attachment; filename=\"%s\"", encoded)},
+	}
+}
+
 var notSMTPUser = fmt.Errorf("not smtp user")
 
-func sendTo(subject string, msg string, senderAddr *mail.Address, fromAddr *mail.Address, replyToAddr *mail.Address, toAddr []*mail.Address, messageID string, t time.Time) (smtpID string, err error) {
+func sendTo(subject string, msg string, senderAddr *mail.Address, fromAddr *mail.Address, replyToAddr *mail.Address, toAddr []*mail.Address, thread Thread, attachments []Attachment, t time.Time) (smtpID string, err error) {
 	if flagparser.SMTPAddress == "" || flagparser.SMTPUser == "" {
 		return smtpID, notSMTPUser
 	}
@@ -173,7 +281,7 @@ func sendTo(subject string, msg string, senderAddr *mail.Address, fromAddr *mail
 	}()
 
 	if senderAddr == nil {
-		senderAddr = emailaddress.DefaultRecipientAddress
+		senderAddr = site.Default().Address()
 	}
 
 	if fromAddr == nil {
@@ -190,9 +298,9 @@ func sendTo(subject string, msg string, senderAddr *mail.Address, fromAddr *mail
 		}
 	}
 
-	smtpID = getSMTPMailID(subject, msg, senderAddr, fromAddr, replyToAddr, toAddr, messageID, t)
+	smtpID = getSMTPMailID(subject, msg, senderAddr, fromAddr, replyToAddr, toAddr, thread, t)
 
-	err = database.SaveSMTPRecord(smtpID, subject, msg, senderAddr, fromAddr, toAddr, messageID, t)
+	err = database.SaveSMTPRecord(smtpID, subject, msg, senderAddr, fromAddr, toAddr, thread.InReplyTo, t)
 	if err != nil {
 		return "", err
 	}
@@ -308,11 +416,30 @@ func sendTo(subject string, msg string, senderAddr *mail.Address, fromAddr *mail
 	gomsg.SetHeader("Reply-To", replyToAddr.String())
 	gomsg.SetHeader("Subject", subject)
 	gomsg.SetDateHeader("Date", t)
-	if messageID != "" {
-		gomsg.SetHeader("In-Reply-To", messageID)
-		gomsg.SetHeader("References", messageID)
+	if thread.MessageID != "" {
+		gomsg.SetHeader("Message-ID", thread.MessageID)
+	}
+	if thread.InReplyTo != "" {
+		gomsg.SetHeader("In-Reply-To", thread.InReplyTo)
+	}
+	if len(thread.References) != 0 {
+		gomsg.SetHeader("References", strings.Join(thread.References, " "))
 	}
 	gomsg.SetBody("text/plain", msg)
+	for _, a := range attachments {
+		name := attachmentName(a.Name)
+		settings := []gomail.FileSetting{gomail.Rename(name), gomail.SetHeader(attachmentHeader(name, a.ContentType))}
+
+		if a.Data != nil {
+			data := a.Data
+			settings = append(settings, gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}))
+		}
+
+		gomsg.Attach(a.Path, settings...)
+	}
 
 	w, err := smtpClient.Data()
 	if err != nil {
@@ -331,13 +458,16 @@ func sendTo(subject string, msg string, senderAddr *mail.Address, fromAddr *mail
 	return smtpID, nil
 }
 
-func getSMTPMailID(subject string, msg string, senderAddr *mail.Address, fromAddr *mail.Address, replyToAddr *mail.Address, toAddr []*mail.Address, messageID string, t time.Time) string {
+func getSMTPMailID(subject string, msg string, senderAddr *mail.Address, fromAddr *mail.Address, replyToAddr *mail.Address, toAddr []*mail.Address, thread Thread, t time.Time) string {
 	rec := make([]string, 0, len(toAddr))
 	for _, to := range toAddr {
 		rec = append(rec, to.String())
 	}
 
-	text := fmt.Sprintf("SMTP-%s\n%s\n%s\n%s\n%s\n%s\n%s\n%d", subject, msg, senderAddr.String(), fromAddr.String(), replyToAddr.String(), strings.Join(rec, ";"), messageID, t.Unix())
+	text := fmt.Sprintf("SMTP-%s\n%s\n%s\n%s\n%s\n%s\n%s\n%d", subject, msg, senderAddr.String(), fromAddr.String(), replyToAddr.String(), strings.Join(rec, ";"), thread.InReplyTo, t.Unix())
+	if thread.MessageID != "" {
+		text += "\n" + thread.MessageID // 同一秒内发出的相同邮件
+	}
 	hasher := sha256.New()
 	hasher.Write([]byte(text))
 	return hex.EncodeToString(hasher.Sum(nil))
@@ -2,6 +2,10 @@ package tpl
 
 import (
 	_ "embed"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"os"
+	"sync/atomic"
 	"text/template"
 )
 
@@ -11,8 +15,39 @@ var imapErrorEmail string
 //go:embed imap_thank_email.txtmpl
 var imapThankEmail string
 
-var ImapErrorEmail *template.Template
-var ImapThankEmail *template.Template
+// siteTemplate 一个站点使用的模板
+type siteTemplate struct {
+	ImapErrorEmail *template.Template
+	ImapThankEmail *template.Template
+}
+
+var builtinTemplate *siteTemplate
+
+// templates 每个站点使用的模板，键为站点ID
+var templates atomic.Pointer[map[string]*siteTemplate]
+
+func getTemplate(siteID string) *siteTemplate {
+	res := templates.Load()
+	if res == nil {
+		return builtinTemplate
+	}
+
+	if t, ok := (*res)[siteID]; ok {
+		return t
+	} else if t, ok := (*res)[flagparser.DefaultSiteID]; ok {
+		return t
+	}
+
+	return builtinTemplate
+}
+
+func ImapErrorEmail(siteID string) *template.Template {
+	return getTemplate(siteID).ImapErrorEmail
+}
+
+func ImapThankEmail(siteID string) *template.Template {
+	return getTemplate(siteID).ImapThankEmail
+}
 
 type ImapErrorEmailModel struct {
 	UserAddr     string
@@ -40,15 +75,66 @@ type ImapThankEmailModel struct {
 }
 
 func init() {
-	var err error
-
-	ImapErrorEmail, err = template.New("ImapErrorEmail").Parse(imapErrorEmail)
+	errorEmail, err := template.New("ImapErrorEmail").Parse(imapErrorEmail)
 	if err != nil {
 		panic(err)
 	}
 
-	ImapThankEmail, err = template.New("ImapThankEmail").Parse(imapThankEmail)
+	thankEmail, err := template.New("ImapThankEmail").Parse(imapThankEmail)
 	if err != nil {
 		panic(err)
 	}
+
+	builtinTemplate = &siteTemplate{
+		ImapErrorEmail: errorEmail,
+		ImapThankEmail: thankEmail,
+	}
+}
+
+// InitTemplate 根据每个站点的 --thank-email-template 和 --error-email-template 加载模板，未设置时使用内置模板
+func InitTemplate() error {
+	options, err := flagparser.AllSiteOptions()
+	if err != nil {
+		return err
+	}
+
+	res := make(map[string]*siteTemplate, len(options))
+
+	for _, opt := range options {
+		errorEmail, err := loadTemplate("ImapErrorEmail", opt.ErrorEmailTemplate, builtinTemplate.ImapErrorEmail)
+		if err != nil {
+			return err
+		}
+
+		thankEmail, err := loadTemplate("ImapThankEmail", opt.ThankEmailTemplate, builtinTemplate.ImapThankEmail)
+		if err != nil {
+			return err
+		}
+
+		res[opt.ID] = &siteTemplate{
+			ImapErrorEmail: errorEmail,
+			ImapThankEmail: thankEmail,
+		}
+	}
+
+	templates.Store(&res)
+	return nil
+}
+
+func loadTemplate(name string, path string, builtin *template.Template) (*template.Template, error) {
+	if path == "" {
+		return builtin, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read template %s failed: %s", path, err.Error())
+	}
+
+	res, err := template.New(name).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse template %s failed: %s", path, err.Error())
+	}
+
+	return res, nil
 }
@@ -0,0 +1,424 @@
+package flagparser
+
+import (
+	"flag"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 环境变量前缀，例如 --smtp-password 对应 AM_SMTP_PASSWORD，其文件形式为 AM_SMTP_PASSWORD_FILE
+const EnvPrefix = "AM_"
+
+// Site 配置文件中的站点配置，Options 的键为命令行参数名（不含--）
+type Site struct {
+	ID      string
+	Options map[string]string
+}
+
+func (s *Site) Get(key string) (string, bool) {
+	res, ok := s.Options[key]
+	return res, ok
+}
+
+// secretOptions 打印时需要隐藏的参数
+var secretOptions = map[string]bool{
+	"webhook":             true,
+	"telegram-token":      true,
+	"slack-webhook":       true,
+	"slack-token":         true,
+	"dingtalk-webhook":    true,
+	"dingtalk-secret":     true,
+	"feishu-webhook":      true,
+	"feishu-secret":       true,
+	"json-webhook-url":    true,
+	"json-webhook-secret": true,
+	"smtp-password":       true,
+	"imap-password":       true,
+	"pop3-password":       true,
+}
+
+// explicitFlags 命令行中显式设置的参数，重新加载配置时不会被覆盖
+var explicitFlags map[uintptr]bool
+
+// loadConfig 合并配置，优先级：默认值 < 配置文件 < 环境变量 < 命令行参数
+func loadConfig() error {
+	explicitFlags = make(map[uintptr]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicitFlags[flagPointer(f)] = true
+	})
+
+	if !explicitFlags[flagPointer(flag.Lookup("config"))] {
+		if path, ok := os.LookupEnv(EnvPrefix + "CONFIG"); ok {
+			ConfigFile = path
+		}
+	}
+
+	values, sites, accounts, err := resolveConfig()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if explicitFlags[flagPointer(f)] {
+			continue
+		}
+
+		err := f.Value.Set(values[name])
+		if err != nil {
+			return fmt.Errorf("bad value of option %s: %s", name, err.Error())
+		}
+	}
+
+	Sites = sites
+	IMAPAccounts = accounts
+	return nil
+}
+
+// resolveConfig 读取配置文件和环境变量，返回其中设置的参数（环境变量优先）、站点和IMAP账号
+func resolveConfig() (map[string]string, []Site, []IMAPAccount, error) {
+	values := make(map[string]string)
+	var sites []Site
+	var accounts []IMAPAccount
+
+	if ConfigFile != "" {
+		config, err := readConfigFile(ConfigFile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for key, value := range config {
+			if key == "sites" || key == "imap-accounts" {
+				continue
+			}
+
+			name, isFile := splitFileKey(key)
+			if !isConfigOption(name) {
+				return nil, nil, nil, fmt.Errorf("unknown option in config file %s: %s", ConfigFile, key)
+			} else if _, ok := values[name]; ok {
+				return nil, nil, nil, fmt.Errorf("option %s is set more than once in config file %s", name, ConfigFile)
+			}
+
+			res, err := optionString(value, isFile)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("bad option %s in config file %s: %s", key, ConfigFile, err.Error())
+			}
+
+			values[name] = res
+		}
+
+		sites, err = parseSites(config["sites"])
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("bad sites in config file %s: %s", ConfigFile, err.Error())
+		}
+
+		accounts, err = parseIMAPAccounts(config["imap-accounts"])
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("bad imap accounts in config file %s: %s", ConfigFile, err.Error())
+		}
+	}
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || !isConfigOption(f.Name) {
+			return
+		}
+
+		env := EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(env)
+		path, fileOK := os.LookupEnv(env + "_FILE")
+
+		if ok && fileOK {
+			err = fmt.Errorf("environment variables %s and %s_FILE can not be set at the same time", env, env)
+			return
+		} else if fileOK {
+			value, err = readSecretFile(path)
+			if err != nil {
+				err = fmt.Errorf("bad environment variable %s_FILE: %s", env, err.Error())
+				return
+			}
+		} else if !ok {
+			return
+		}
+
+		values[f.Name] = value
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return values, sites, accounts, nil
+}
+
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file %s failed: %s", path, err.Error())
+	}
+
+	res := make(map[string]any)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &res)
+	case ".yaml", ".yml", "":
+		err = yaml.Unmarshal(data, &res)
+	default:
+		return nil, fmt.Errorf("unknown config file type: %s, only .yaml, .yml and .toml are supported", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s failed: %s", path, err.Error())
+	}
+
+	return res, nil
+}
+
+// tableList 配置文件中的列表，也可以是以ID为键的表
+func tableList(value any, name string) ([]map[string]any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	var list []any
+	switch v := value.(type) {
+	case []any:
+		list = v
+	case []map[string]any: // toml 的 [[sites]]
+		for _, m := range v {
+			list = append(list, m)
+		}
+	case map[string]any: // 以ID为键
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			m, ok := v[key].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s %s must be a table", name, key)
+			}
+
+			item := make(map[string]any, len(m)+1)
+			for k, val := range m {
+				item[k] = val
+			}
+			item["id"] = key
+			list = append(list, item)
+		}
+	default:
+		return nil, fmt.Errorf("%ss must be a list or a table", name)
+	}
+
+	res := make([]map[string]any, 0, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s #%d must be a table", name, i+1)
+		}
+		res = append(res, m)
+	}
+
+	return res, nil
+}
+
+func parseSites(value any) ([]Site, error) {
+	list, err := tableList(value, "site")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Site, 0, len(list))
+	ids := make(map[string]bool, len(list))
+
+	for i, m := range list {
+		site := Site{
+			Options: make(map[string]string, len(m)),
+		}
+
+		for key, val := range m {
+			if key == "id" {
+				site.ID = strings.TrimSpace(fmt.Sprint(val))
+				continue
+			}
+
+			name, isFile := splitFileKey(key)
+			if !isSiteOption(name) {
+				return nil, fmt.Errorf("option %s can not be set in site #%d", key, i+1)
+			} else if _, ok := site.Options[name]; ok {
+				return nil, fmt.Errorf("option %s is set more than once in site #%d", name, i+1)
+			}
+
+			res, err := optionString(val, isFile)
+			if err != nil {
+				return nil, fmt.Errorf("bad option %s in site #%d: %s", key, i+1, err.Error())
+			}
+
+			site.Options[name] = res
+		}
+
+		if site.ID == "" {
+			return nil, fmt.Errorf("site #%d has no id", i+1)
+		} else if ids[site.ID] {
+			return nil, fmt.Errorf("site id %s is duplicated", site.ID)
+		} else if _, err := NewSiteOptions(&site); err != nil {
+			return nil, err
+		}
+
+		ids[site.ID] = true
+		res = append(res, site)
+	}
+
+	return res, nil
+}
+
+func parseIMAPAccounts(value any) ([]IMAPAccount, error) {
+	list, err := tableList(value, "imap account")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]IMAPAccount, 0, len(list))
+	ids := make(map[string]bool, len(list))
+
+	for i, m := range list {
+		var account IMAPAccount
+		seen := make(map[string]bool, len(m))
+
+		for key, val := range m {
+			if key == "id" {
+				account.ID = strings.TrimSpace(fmt.Sprint(val))
+				continue
+			}
+
+			name, isFile := splitFileKey(key)
+			if !isIMAPAccountOption(name) {
+				return nil, fmt.Errorf("option %s can not be set in imap account #%d", key, i+1)
+			} else if seen[name] {
+				return nil, fmt.Errorf("option %s is set more than once in imap account #%d", name, i+1)
+			}
+
+			res, err := optionString(val, isFile)
+			if err != nil {
+				return nil, fmt.Errorf("bad option %s in imap account #%d: %s", key, i+1, err.Error())
+			}
+
+			_ = account.set(name, res)
+			seen[name] = true
+		}
+
+		if account.ID == "" {
+			return nil, fmt.Errorf("imap account #%d has no id", i+1)
+		} else if ids[account.ID] {
+			return nil, fmt.Errorf("imap account id %s is duplicated", account.ID)
+		} else if account.User == "" {
+			return nil, fmt.Errorf("imap account %s has no imap-user", account.ID)
+		}
+
+		ids[account.ID] = true
+		res = append(res, account)
+	}
+
+	return res, nil
+}
+
+// splitFileKey 去掉 -file 或 _file 后缀，带有该后缀的键的值为保存真实值的文件路径
+func splitFileKey(key string) (string, bool) {
+	for _, suffix := range []string{"-file", "_file"} {
+		if strings.HasSuffix(key, suffix) {
+			return strings.TrimSuffix(key, suffix), true
+		}
+	}
+	return key, false
+}
+
+func optionString(value any, isFile bool) (string, error) {
+	var res string
+
+	switch v := value.(type) {
+	case string:
+		res = v
+	case bool:
+		res = strconv.FormatBool(v)
+	case int:
+		res = strconv.Itoa(v)
+	case int64:
+		res = strconv.FormatInt(v, 10)
+	case float64:
+		res = strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := optionString(item, false)
+			if err != nil {
+				return "", err
+			}
+			list = append(list, s)
+		}
+		res = strings.Join(list, ",")
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+
+	if isFile {
+		return readSecretFile(res)
+	}
+
+	return res, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// isConfigOption 可以通过配置文件和环境变量设置的参数（不含简写和只在命令行中有意义的参数）
+func isConfigOption(name string) bool {
+	if len(name) <= 1 {
+		return false
+	}
+
+	switch name {
+	case "config", "dry-run", "version", "license", "report", "show-option",
+		"resend", "resend-failed", "list-failed", "resend-notifier", "since",
+		"create-api-token", "revoke-api-token", "list-api-token",
+		"mail-id", "set-status", "assign", "note", "list-unhandled",
+		"reply", "reply-file", "reply-subject":
+		return false
+	}
+
+	return flag.Lookup(name) != nil
+}
+
+func flagPointer(f *flag.Flag) uintptr {
+	if f == nil {
+		return 0
+	}
+	return reflect.ValueOf(f.Value).Pointer()
+}
+
+// maskOption 隐藏密钥类参数的值
+func maskOption(name string, value string) string {
+	if !secretOptions[name] || value == "" {
+		return value
+	}
+	return "******"
+}
@@ -1,9 +1,16 @@
 package flagparser
 
-import resource "github.com/SongZihuan/anonymous-message"
+import (
+	resource "github.com/SongZihuan/anonymous-message"
+	"time"
+)
 
 var Debug bool = false
 
+var ConfigFile string = ""
+var Sites []Site
+var IMAPAccounts []IMAPAccount
+
 var Origin string = ""
 var HttpAddress string = ":3352"
 var WebURL string = "（暂无）"
@@ -18,12 +25,83 @@ var IMAPPassword string = ""
 var RecipientList string = ""
 var NoticeList string = ""
 var MailBox string = "电子信箱"
+var IMAPTLS string = "auto"
+var IMAPAcceptedFolder string = ""
+var IMAPRejectedFolder string = ""
+var IMAPLoopFolder string = ""
+var POP3Address string = ""
+var POP3User string = ""
+var POP3Password string = ""
+var POP3DeleteAfter int = 0
+var POP3TLS string = "auto"
+var ReplyAlias string = ""
+var ReceiverAddress string = ""
+var ReceiverProtocol string = "smtp"
+var AttachmentPath string = ""
+var AttachmentMaxSize int = 5120
+var RawMail bool = false
+var RawMailAttach bool = false
+var AuthPolicy string = "no-reply"
+var AuthServID string = ""
+var AuthDNS string = ""
+
+var IPRate string = "36/1h"
+var EmailRate string = "18/1h"
+var SMTPRate string = "3/12h"
+
+var ThankEmailTemplate string = ""
+var ErrorEmailTemplate string = ""
 
 var SQLitePath = ""
 var SQLiteActiveClose = false
 
 var Webhook string = ""
 
+var Notifier string = "wxrobot,email"
+
+var TelegramAPIURL string = "https://api.telegram.org"
+var TelegramToken string = ""
+var TelegramChatID string = ""
+
+var SlackWebhook string = ""
+var SlackAPIURL string = "https://slack.com/api"
+var SlackToken string = ""
+var SlackChannel string = ""
+
+var DingTalkWebhook string = ""
+var DingTalkSecret string = ""
+var DingTalkMsgType string = "text"
+
+var FeishuWebhook string = ""
+var FeishuSecret string = ""
+var FeishuMsgType string = "text"
+
+var JSONWebhookURL string = ""
+var JSONWebhookSecret string = ""
+var JSONWebhookRetry int = 3
+
+var OutboxMaxAttempts int = 8
+var OutboxBackoff time.Duration = 30 * time.Second
+
+var Resend string = ""
+var ResendFailed bool = false
+var ListFailed bool = false
+var ResendNotifier string = ""
+var Since time.Duration = 24 * time.Hour
+
+var CreateAPIToken string = ""
+var RevokeAPIToken string = ""
+var ListAPIToken bool = false
+
+var MailID string = ""
+var SetStatus string = ""
+var Assign string = ""
+var Note string = ""
+var ListUnhandled bool = false
+var Reply string = ""
+var ReplyFile string = ""
+var ReplySubject string = ""
+
 var _TimeZone string = "Local"
 
 var NotProxyProto bool = false
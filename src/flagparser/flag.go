@@ -19,6 +19,8 @@ func initFlag() (err error) {
 
 	flag.BoolVar(&Debug, "debug", Debug, "debug mode")
 
+	flag.StringVar(&ConfigFile, "config", ConfigFile, "yaml or toml config file, the keys are the long option names (environment variable: AM_CONFIG)")
+
 	flag.StringVar(&HttpAddress, "a", HttpAddress, "http server listen address")
 	flag.StringVar(&HttpAddress, "address", HttpAddress, "http server listen address")
 	flag.StringVar(&HttpAddress, "http-address", HttpAddress, "http server listen address")
@@ -33,6 +35,32 @@ func initFlag() (err error) {
 	flag.StringVar(&Webhook, "web-hook", Webhook, "wechat business robot webhook")
 	flag.StringVar(&Webhook, "webhook", Webhook, "wechat business robot webhook")
 
+	flag.StringVar(&Notifier, "notifier", Notifier, "enabled notification channels, comma separated, example: wxrobot,email,telegram,slack,dingtalk,feishu,jsonwebhook")
+
+	flag.StringVar(&TelegramAPIURL, "telegram-api-url", TelegramAPIURL, "telegram bot api base url")
+	flag.StringVar(&TelegramToken, "telegram-token", TelegramToken, "telegram bot token")
+	flag.StringVar(&TelegramChatID, "telegram-chat-id", TelegramChatID, "telegram chat id, comma separated")
+
+	flag.StringVar(&SlackWebhook, "slack-webhook", SlackWebhook, "slack or mattermost incoming webhook")
+	flag.StringVar(&SlackAPIURL, "slack-api-url", SlackAPIURL, "slack web api base url, used to upload long messages")
+	flag.StringVar(&SlackToken, "slack-token", SlackToken, "slack bot token, used to upload long messages as snippets")
+	flag.StringVar(&SlackChannel, "slack-channel", SlackChannel, "slack channel id, used to upload long messages as snippets")
+
+	flag.StringVar(&DingTalkWebhook, "dingtalk-webhook", DingTalkWebhook, "dingtalk robot webhook")
+	flag.StringVar(&DingTalkSecret, "dingtalk-secret", DingTalkSecret, "dingtalk robot sign secret")
+	flag.StringVar(&DingTalkMsgType, "dingtalk-msgtype", DingTalkMsgType, "dingtalk message type: text, markdown or actionCard")
+
+	flag.StringVar(&FeishuWebhook, "feishu-webhook", FeishuWebhook, "feishu/lark robot webhook")
+	flag.StringVar(&FeishuSecret, "feishu-secret", FeishuSecret, "feishu/lark robot sign secret")
+	flag.StringVar(&FeishuMsgType, "feishu-msgtype", FeishuMsgType, "feishu/lark message type: text, post or interactive")
+
+	flag.StringVar(&JSONWebhookURL, "json-webhook-url", JSONWebhookURL, "json webhook url, comma separated")
+	flag.StringVar(&JSONWebhookSecret, "json-webhook-secret", JSONWebhookSecret, "json webhook hmac-sha256 signing secret")
+	flag.IntVar(&JSONWebhookRetry, "json-webhook-retry", JSONWebhookRetry, "json webhook retry times")
+
+	flag.IntVar(&OutboxMaxAttempts, "outbox-max-attempts", OutboxMaxAttempts, "max delivery attempts before a notification goes to dead-letter (requires sqlite)")
+	flag.DurationVar(&OutboxBackoff, "outbox-backoff", OutboxBackoff, "base delay of the exponential backoff between delivery attempts")
+
 	flag.StringVar(&SMTPAddress, "smtp-address", SMTPAddress, "smtp service address, example: smtp.qiye.aliyun.com:465")
 	flag.StringVar(&SMTPUser, "smtp-user", SMTPUser, "smtp user name")
 	flag.StringVar(&SMTPPassword, "smtp-password", SMTPPassword, "smtp password")
@@ -41,7 +69,33 @@ func initFlag() (err error) {
 	flag.StringVar(&IMAPPassword, "imap-password", IMAPPassword, "imap password")
 	flag.StringVar(&NoticeList, "notice-list", NoticeList, "smtp notice email address, comma separated")
 	flag.StringVar(&RecipientList, "recipient-list", RecipientList, "recipients email address, comma separated")
-	flag.StringVar(&MailBox, "mailbox", MailBox, "imap mail box")
+	flag.StringVar(&MailBox, "mailbox", MailBox, "imap mail box, comma separated")
+	flag.StringVar(&IMAPTLS, "imap-tls", IMAPTLS, "imap tls mode: tls, starttls, insecure or auto (try them in order)")
+	flag.StringVar(&IMAPAcceptedFolder, "imap-accepted-folder", IMAPAcceptedFolder, "imap folder to move accepted mail into, empty means keep it in place")
+	flag.StringVar(&IMAPRejectedFolder, "imap-rejected-folder", IMAPRejectedFolder, "imap folder to move rejected mail into (rate limit, encoding, too big), empty means keep it in place")
+	flag.StringVar(&IMAPLoopFolder, "imap-loop-folder", IMAPLoopFolder, "imap folder to move loop-suppressed mail into (sent from our own addresses), empty means keep it in place")
+	flag.StringVar(&POP3Address, "pop3-address", POP3Address, "pop3 service address, example: pop.qiye.aliyun.com:995, empty means disabled (requires sqlite)")
+	flag.StringVar(&POP3User, "pop3-user", POP3User, "pop3 user name")
+	flag.StringVar(&POP3Password, "pop3-password", POP3Password, "pop3 password")
+	flag.StringVar(&POP3TLS, "pop3-tls", POP3TLS, "pop3 tls mode: tls, starttls (stls), insecure (plaintext login) or auto (try tls and starttls in order)")
+	flag.IntVar(&POP3DeleteAfter, "pop3-delete-after", POP3DeleteAfter, "delete the mail from the pop3 server this many days after it is fetched, 0 means never delete")
+	flag.StringVar(&ReplyAlias, "reply-alias", ReplyAlias, "per-message reply address of notice emails, {token} is replaced by a random token, example: reply+{token}@example.com (requires sqlite)")
+	flag.StringVar(&ReceiverAddress, "receiver-address", ReceiverAddress, "listen address of the built-in receiver that accepts mail for the recipients directly from the mta, example: 127.0.0.1:2525, empty means disabled")
+	flag.StringVar(&ReceiverProtocol, "receiver-protocol", ReceiverProtocol, "protocol of the built-in receiver: smtp or lmtp")
+	flag.StringVar(&AttachmentPath, "attachment-path", AttachmentPath, "directory to store the attachments of email messages, empty means attachments are dropped (requires sqlite)")
+	flag.IntVar(&AttachmentMaxSize, "attachment-max-size", AttachmentMaxSize, "max size of one attachment in KiB, larger attachments are only listed")
+	flag.BoolVar(&RawMail, "raw-mail", RawMail, "store the original email messages gzip-compressed, downloadable from the admin api (requires sqlite)")
+	flag.BoolVar(&RawMailAttach, "raw-mail-attach", RawMailAttach, "attach the original email message (.eml) to notice emails (requires --raw-mail)")
+	flag.StringVar(&AuthPolicy, "auth-policy", AuthPolicy, "auto-reply policy by the spf, dkim and dmarc results of inbound mail: none, no-reply (skip mail that fails) or strict (only mail that passes)")
+	flag.StringVar(&AuthServID, "auth-serv-id", AuthServID, "trusted authserv-id of the Authentication-Results header, comma separated, empty means only the topmost header is trusted, required when the receiver is enabled")
+	flag.StringVar(&AuthDNS, "auth-dns", AuthDNS, "dns server used to look up dkim keys, example: 1.1.1.1:53, empty means the system resolver")
+
+	flag.StringVar(&IPRate, "ip-rate", IPRate, "max messages per ip, format: <count>/<duration>")
+	flag.StringVar(&EmailRate, "email-rate", EmailRate, "max messages per sender email address, format: <count>/<duration>")
+	flag.StringVar(&SMTPRate, "smtp-rate", SMTPRate, "max thank-you, error or reply emails of each kind sent to one address, format: <count>/<duration>")
+
+	flag.StringVar(&ThankEmailTemplate, "thank-email-template", ThankEmailTemplate, "thank-you email template file (go text/template), default is the built-in template")
+	flag.StringVar(&ErrorEmailTemplate, "error-email-template", ErrorEmailTemplate, "error email template file (go text/template), default is the built-in template")
 
 	flag.StringVar(&SQLitePath, "sqlite-path", SQLitePath, "sqlite path")
 	flag.BoolVar(&SQLiteActiveClose, "sqlite-active-close", SQLiteActiveClose, "sqlite uses active shutdown. note: usually it does not need to be enabled.")
@@ -50,6 +104,25 @@ func initFlag() (err error) {
 
 	flag.BoolVar(&NotProxyProto, "not-proxy-proto", NotProxyProto, "not proxy proto")
 
+	flag.StringVar(&Resend, "resend", Resend, "re-deliver the notifications of the mail with this mail id, then exit (requires sqlite)")
+	flag.BoolVar(&ResendFailed, "resend-failed", ResendFailed, "re-deliver the failed and dead-letter notifications since --since, then exit (requires sqlite)")
+	flag.BoolVar(&ListFailed, "list-failed", ListFailed, "list the failed and dead-letter notifications since --since, then exit (requires sqlite)")
+	flag.StringVar(&ResendNotifier, "resend-notifier", ResendNotifier, "only re-deliver to these notification channels, comma separated, default is all enabled channels")
+	flag.DurationVar(&Since, "since", Since, "time range of --resend-failed and --list-failed, example: 24h")
+
+	flag.StringVar(&CreateAPIToken, "create-api-token", CreateAPIToken, "create an admin api token with this name and print it, then exit (requires sqlite)")
+	flag.StringVar(&RevokeAPIToken, "revoke-api-token", RevokeAPIToken, "revoke the admin api token with this name, then exit (requires sqlite)")
+	flag.BoolVar(&ListAPIToken, "list-api-token", ListAPIToken, "list the admin api tokens, then exit (requires sqlite)")
+
+	flag.StringVar(&MailID, "mail-id", MailID, "the mail to change with --set-status and --assign, or to show its status and audit trail, then exit (requires sqlite)")
+	flag.StringVar(&SetStatus, "set-status", SetStatus, "set the status of --mail-id: new, read, replied, archived or spam")
+	flag.StringVar(&Assign, "assign", Assign, "assign --mail-id to this person, use - to unassign")
+	flag.StringVar(&Note, "note", Note, "note recorded in the audit trail with --set-status and --assign")
+	flag.BoolVar(&ListUnhandled, "list-unhandled", ListUnhandled, "list the unhandled (new or read) messages, then exit (requires sqlite)")
+	flag.StringVar(&Reply, "reply", Reply, "reply to --mail-id by email with this content, then exit")
+	flag.StringVar(&ReplyFile, "reply-file", ReplyFile, "reply to --mail-id by email with the content of this file, use - for stdin, then exit")
+	flag.StringVar(&ReplySubject, "reply-subject", ReplySubject, "subject of --reply and --reply-file, default: reply to the original subject")
+
 	flag.BoolVar(&DryRun, "dry-run", DryRun, "only parser the options")
 
 	flag.BoolVar(&Version, "version", Version, "show the version")
@@ -66,6 +139,13 @@ func initFlag() (err error) {
 
 	flag.Parse()
 
+	err = loadConfig()
+	if err != nil {
+		return err
+	}
+
+	DefaultIMAPUser()
+
 	_ = TimeZone() // 先加载一次Location
 
 	return nil
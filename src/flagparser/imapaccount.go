@@ -0,0 +1,135 @@
+package flagparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultIMAPAccountID 默认IMAP账号（即全局的IMAP参数）的ID
+const DefaultIMAPAccountID = ""
+
+// IMAPAccount 一个IMAP账号，配置文件中没有设置的地址、邮箱、TLS模式和文件夹与全局参数相同
+type IMAPAccount struct {
+	ID       string
+	Address  string
+	User     string
+	Password string
+	MailBox  string // 以英文逗号分隔，可以同时监听多个邮箱
+	TLS      string // tls、starttls、insecure 或 auto
+	SiteID   string // 账号所属的站点，账号的用户名会作为该站点的收件地址
+
+	AcceptedFolder string // 处理后移动到的文件夹，为空时不移动
+	RejectedFolder string
+	LoopFolder     string
+}
+
+func (a *IMAPAccount) IsDefault() bool {
+	return a.ID == DefaultIMAPAccountID
+}
+
+// MailBoxList 账号需要监听的所有邮箱
+func (a *IMAPAccount) MailBoxList() []string {
+	res := make([]string, 0, 2)
+	for _, mailbox := range strings.Split(a.MailBox, ",") {
+		mailbox = strings.TrimSpace(mailbox)
+		if mailbox != "" {
+			res = append(res, mailbox)
+		}
+	}
+	return res
+}
+
+// fields 配置文件的IMAP账号中可以设置的参数
+func (a *IMAPAccount) fields() map[string]*string {
+	return map[string]*string{
+		"imap-address":  &a.Address,
+		"imap-user":     &a.User,
+		"imap-password": &a.Password,
+		"mailbox":       &a.MailBox,
+		"imap-tls":      &a.TLS,
+		"site":          &a.SiteID,
+
+		"imap-accepted-folder": &a.AcceptedFolder,
+		"imap-rejected-folder": &a.RejectedFolder,
+		"imap-loop-folder":     &a.LoopFolder,
+	}
+}
+
+func (a *IMAPAccount) set(key string, value string) error {
+	p, ok := a.fields()[key]
+	if !ok {
+		return fmt.Errorf("unknown option: %s", key)
+	}
+	*p = value
+	return nil
+}
+
+func isIMAPAccountOption(name string) bool {
+	_, ok := (&IMAPAccount{}).fields()[name]
+	return ok
+}
+
+// DefaultIMAPUser 没有设置 --imap-user 和 --imap-password 时使用SMTP的账号
+// 解析参数后立即调用，站点需要使用 IMAPUser 作为默认站点的收件地址
+func DefaultIMAPUser() {
+	if IMAPAddress == "" {
+		return
+	}
+
+	if IMAPUser == "" && IMAPPassword == "" {
+		IMAPUser = SMTPUser
+		IMAPPassword = SMTPPassword
+	} else if SMTPUser == IMAPUser {
+		IMAPPassword = SMTPPassword
+	}
+}
+
+// GlobalIMAPAccount 默认IMAP账号，--imap-address 为空时不启用
+func GlobalIMAPAccount() *IMAPAccount {
+	return &IMAPAccount{
+		ID:       DefaultIMAPAccountID,
+		Address:  IMAPAddress,
+		User:     IMAPUser,
+		Password: IMAPPassword,
+		MailBox:  MailBox,
+		TLS:      IMAPTLS,
+		SiteID:   DefaultSiteID,
+
+		AcceptedFolder: IMAPAcceptedFolder,
+		RejectedFolder: IMAPRejectedFolder,
+		LoopFolder:     IMAPLoopFolder,
+	}
+}
+
+// AllIMAPAccounts 返回默认IMAP账号（如果启用）和配置文件中所有的IMAP账号
+func AllIMAPAccounts() []*IMAPAccount {
+	res := make([]*IMAPAccount, 0, len(IMAPAccounts)+1)
+	if IMAPAddress != "" {
+		res = append(res, GlobalIMAPAccount())
+	}
+
+	for _, account := range IMAPAccounts {
+		account := account
+		if account.Address == "" {
+			account.Address = IMAPAddress
+		}
+		if account.MailBox == "" {
+			account.MailBox = MailBox
+		}
+		if account.TLS == "" {
+			account.TLS = IMAPTLS
+		}
+		if account.AcceptedFolder == "" {
+			account.AcceptedFolder = IMAPAcceptedFolder
+		}
+		if account.RejectedFolder == "" {
+			account.RejectedFolder = IMAPRejectedFolder
+		}
+		if account.LoopFolder == "" {
+			account.LoopFolder = IMAPLoopFolder
+		}
+		res = append(res, &account)
+	}
+
+	return res
+}
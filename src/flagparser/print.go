@@ -5,6 +5,7 @@ import (
 	"fmt"
 	resource "github.com/SongZihuan/anonymous-message"
 	"github.com/SongZihuan/anonymous-message/src/utils"
+	"sort"
 )
 
 func PrintLicense() (int, error) {
@@ -30,22 +31,110 @@ func PrintLF() (int, error) {
 
 func Print() {
 	fmt.Println("Debug:", Debug)
+	fmt.Println("Config File:", ConfigFile)
 	fmt.Println("Origin:", Origin)
 	fmt.Println("HttpAddress:", HttpAddress)
+	fmt.Println("Name:", Name)
 	fmt.Println("WebURL:", WebURL)
 	fmt.Println("Not Use Proxy Proto:", NotProxyProto)
-	fmt.Println("Webhook:", Webhook)
+	fmt.Println("Webhook:", maskOption("webhook", Webhook))
+	fmt.Println("Notifier:", Notifier)
+	fmt.Println("Telegram API URL:", TelegramAPIURL)
+	fmt.Println("Telegram Token:", maskOption("telegram-token", TelegramToken))
+	fmt.Println("Telegram Chat ID:", TelegramChatID)
+	fmt.Println("Slack Webhook:", maskOption("slack-webhook", SlackWebhook))
+	fmt.Println("Slack API URL:", SlackAPIURL)
+	fmt.Println("Slack Token:", maskOption("slack-token", SlackToken))
+	fmt.Println("Slack Channel:", SlackChannel)
+	fmt.Println("DingTalk Webhook:", maskOption("dingtalk-webhook", DingTalkWebhook))
+	fmt.Println("DingTalk Secret:", maskOption("dingtalk-secret", DingTalkSecret))
+	fmt.Println("DingTalk MsgType:", DingTalkMsgType)
+	fmt.Println("Feishu Webhook:", maskOption("feishu-webhook", FeishuWebhook))
+	fmt.Println("Feishu Secret:", maskOption("feishu-secret", FeishuSecret))
+	fmt.Println("Feishu MsgType:", FeishuMsgType)
+	fmt.Println("JSON Webhook URL:", maskOption("json-webhook-url", JSONWebhookURL))
+	fmt.Println("JSON Webhook Secret:", maskOption("json-webhook-secret", JSONWebhookSecret))
+	fmt.Println("JSON Webhook Retry:", JSONWebhookRetry)
+	fmt.Println("Outbox Max Attempts:", OutboxMaxAttempts)
+	fmt.Println("Outbox Backoff:", OutboxBackoff)
+	fmt.Println("Resend:", Resend)
+	fmt.Println("Resend Failed:", ResendFailed)
+	fmt.Println("List Failed:", ListFailed)
+	fmt.Println("Resend Notifier:", ResendNotifier)
+	fmt.Println("Since:", Since)
+	fmt.Println("Create API Token:", CreateAPIToken)
+	fmt.Println("Revoke API Token:", RevokeAPIToken)
+	fmt.Println("List API Token:", ListAPIToken)
+	fmt.Println("Mail ID:", MailID)
+	fmt.Println("Set Status:", SetStatus)
+	fmt.Println("Assign:", Assign)
+	fmt.Println("Note:", Note)
+	fmt.Println("List Unhandled:", ListUnhandled)
+	fmt.Println("Reply:", Reply)
+	fmt.Println("Reply File:", ReplyFile)
+	fmt.Println("Reply Subject:", ReplySubject)
 	fmt.Println("SMTP Address:", SMTPAddress)
 	fmt.Println("SMTP User Name:", SMTPUser)
-	fmt.Println("SMTP Password:", SMTPPassword)
+	fmt.Println("SMTP Password:", maskOption("smtp-password", SMTPPassword))
 	fmt.Println("IMAP Address:", IMAPAddress)
 	fmt.Println("IMAP User Name:", IMAPUser)
-	fmt.Println("IMAP Password:", IMAPPassword)
+	fmt.Println("IMAP Password:", maskOption("imap-password", IMAPPassword))
 	fmt.Println("SMTP Recipient:", NoticeList)
 	fmt.Println("IMAP Recipient:", RecipientList)
 	fmt.Println("IMAP MailBox:", MailBox)
+	fmt.Println("IMAP TLS:", IMAPTLS)
+	fmt.Println("IMAP Accepted Folder:", IMAPAcceptedFolder)
+	fmt.Println("IMAP Rejected Folder:", IMAPRejectedFolder)
+	fmt.Println("IMAP Loop Folder:", IMAPLoopFolder)
+	fmt.Println("POP3 Address:", POP3Address)
+	fmt.Println("POP3 User Name:", POP3User)
+	fmt.Println("POP3 Password:", maskOption("pop3-password", POP3Password))
+	fmt.Println("POP3 TLS:", POP3TLS)
+	fmt.Println("POP3 Delete After:", POP3DeleteAfter)
+	fmt.Println("Reply Alias:", ReplyAlias)
+	fmt.Println("Receiver Address:", ReceiverAddress)
+	fmt.Println("Receiver Protocol:", ReceiverProtocol)
+	fmt.Println("Attachment Path:", AttachmentPath)
+	fmt.Println("Attachment Max Size (KiB):", AttachmentMaxSize)
+	fmt.Println("Raw Mail:", RawMail)
+	fmt.Println("Raw Mail Attach:", RawMailAttach)
+	fmt.Println("Auth Policy:", AuthPolicy)
+	fmt.Println("Auth Serv ID:", AuthServID)
+	fmt.Println("Auth DNS:", AuthDNS)
+	fmt.Println("IP Rate:", IPRate)
+	fmt.Println("Email Rate:", EmailRate)
+	fmt.Println("SMTP Rate:", SMTPRate)
+	fmt.Println("Thank Email Template:", ThankEmailTemplate)
+	fmt.Println("Error Email Template:", ErrorEmailTemplate)
 	fmt.Println("SQLite Path:", SQLitePath)
 	fmt.Println("SQLite Active Close:", SQLiteActiveClose)
 	fmt.Println("Time Zone (use set) : ", _TimeZone)
 	fmt.Println("Time Zone: ", TimeZone())
+
+	for _, site := range Sites {
+		fmt.Printf("Site %s:\n", site.ID)
+
+		keys := make([]string, 0, len(site.Options))
+		for key := range site.Options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Printf("  %s: %s\n", key, maskOption(key, site.Options[key]))
+		}
+	}
+
+	for _, account := range IMAPAccounts {
+		fmt.Printf("IMAP Account %s:\n", account.ID)
+		fmt.Println("  Address:", account.Address)
+		fmt.Println("  User Name:", account.User)
+		fmt.Println("  Password:", maskOption("imap-password", account.Password))
+		fmt.Println("  MailBox:", account.MailBox)
+		fmt.Println("  TLS:", account.TLS)
+		fmt.Println("  Accepted Folder:", account.AcceptedFolder)
+		fmt.Println("  Rejected Folder:", account.RejectedFolder)
+		fmt.Println("  Loop Folder:", account.LoopFolder)
+		fmt.Println("  Site:", account.SiteID)
+	}
 }
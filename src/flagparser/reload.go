@@ -0,0 +1,223 @@
+package flagparser
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change 重新加载配置时发生变化的参数，密钥类参数的值已被隐藏
+type Change struct {
+	Name       string
+	Old        string
+	New        string
+	Reloadable bool // 为 false 时表示该参数需要重启才能生效，本次没有修改
+
+	oldValue string
+}
+
+func (c Change) String() string {
+	if !c.Reloadable {
+		return fmt.Sprintf("%s: %s -> %s（需要重启才能生效）", c.Name, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Name, c.Old, c.New)
+}
+
+// reloadableOptions 可以在运行时重新加载的参数
+var reloadableOptions = map[string]bool{
+	"origin":               true,
+	"notice-list":          true,
+	"recipient-list":       true,
+	"reply-alias":          true,
+	"notifier":             true,
+	"webhook":              true,
+	"telegram-api-url":     true,
+	"telegram-token":       true,
+	"telegram-chat-id":     true,
+	"slack-webhook":        true,
+	"slack-api-url":        true,
+	"slack-token":          true,
+	"slack-channel":        true,
+	"dingtalk-webhook":     true,
+	"dingtalk-secret":      true,
+	"dingtalk-msgtype":     true,
+	"feishu-webhook":       true,
+	"feishu-secret":        true,
+	"feishu-msgtype":       true,
+	"json-webhook-url":     true,
+	"json-webhook-secret":  true,
+	"json-webhook-retry":   true,
+	"ip-rate":              true,
+	"email-rate":           true,
+	"smtp-rate":            true,
+	"thank-email-template": true,
+	"error-email-template": true,
+}
+
+var lastSites []Site
+
+// Reload 重新读取配置文件和环境变量，只修改可以重新加载的参数，命令行中显式设置的参数保持不变
+// 不是并发安全的，调用方需要在修改完成后重新初始化使用这些参数的模块
+func Reload() ([]Change, error) {
+	values, sites, accounts, err := resolveConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一个变量可能有多个参数名（例如 webhook 和 web-hook），按变量处理
+	targets := make(map[uintptr]string, len(values))
+	for name, value := range values {
+		targets[flagPointer(flag.Lookup(name))] = value
+	}
+
+	reloadable := make(map[uintptr]string, len(reloadableOptions))
+	for name := range reloadableOptions {
+		if f := flag.Lookup(name); f != nil {
+			reloadable[flagPointer(f)] = name
+		}
+	}
+
+	var changes []Change
+	visited := make(map[uintptr]bool)
+
+	flag.VisitAll(func(f *flag.Flag) {
+		ptr := flagPointer(f)
+		if err != nil || !isConfigOption(f.Name) || explicitFlags[ptr] || visited[ptr] {
+			return
+		}
+		visited[ptr] = true
+
+		target, ok := targets[ptr]
+		if !ok {
+			target = f.DefValue // 配置中删除的参数恢复默认值
+		}
+
+		old := f.Value.String()
+		if old == target {
+			return
+		}
+
+		if e := f.Value.Set(target); e != nil {
+			if ok {
+				err = fmt.Errorf("bad value of option %s: %s", f.Name, e.Error())
+			}
+			return
+		}
+
+		now := f.Value.String()
+		if now == old {
+			return
+		}
+
+		name, ok := reloadable[ptr]
+		if !ok {
+			name = f.Name
+		}
+
+		change := Change{
+			Name:       name,
+			Old:        maskOption(name, old),
+			New:        maskOption(name, now),
+			Reloadable: ok,
+			oldValue:   old,
+		}
+
+		if !change.Reloadable {
+			_ = f.Value.Set(old)
+		}
+
+		changes = append(changes, change)
+	})
+	if err != nil {
+		RollbackReload(changes)
+		return nil, err
+	}
+
+	changes = append(changes, siteChanges(Sites, sites)...)
+
+	if !reflect.DeepEqual(IMAPAccounts, accounts) {
+		// IMAP连接不会重新建立，账号的修改需要重启才能生效
+		changes = append(changes, Change{Name: "imap-accounts", Old: "（原配置）", New: "（新配置）", Reloadable: false})
+	}
+
+	lastSites = Sites
+	Sites = sites
+
+	return changes, nil
+}
+
+// RollbackReload 撤销 Reload 的修改
+func RollbackReload(changes []Change) {
+	for _, change := range changes {
+		if !change.Reloadable {
+			continue
+		}
+
+		if f := flag.Lookup(change.Name); f != nil {
+			_ = f.Value.Set(change.oldValue)
+		}
+	}
+
+	if lastSites != nil {
+		Sites = lastSites
+		lastSites = nil
+	}
+}
+
+func siteChanges(old []Site, now []Site) []Change {
+	var res []Change
+
+	oldMap := make(map[string]Site, len(old))
+	for _, site := range old {
+		oldMap[site.ID] = site
+	}
+
+	nowMap := make(map[string]Site, len(now))
+	for _, site := range now {
+		nowMap[site.ID] = site
+
+		o, ok := oldMap[site.ID]
+		if !ok {
+			res = append(res, Change{Name: "sites." + site.ID, Old: "（无）", New: "（新增）", Reloadable: true})
+			continue
+		}
+
+		if reflect.DeepEqual(o.Options, site.Options) {
+			continue
+		}
+
+		keys := make(map[string]bool)
+		for key := range o.Options {
+			keys[key] = true
+		}
+		for key := range site.Options {
+			keys[key] = true
+		}
+
+		list := make([]string, 0, len(keys))
+		for key := range keys {
+			if o.Options[key] != site.Options[key] {
+				list = append(list, key)
+			}
+		}
+		sort.Strings(list)
+
+		for _, key := range list {
+			res = append(res, Change{
+				Name:       "sites." + site.ID + "." + key,
+				Old:        maskOption(key, o.Options[key]),
+				New:        maskOption(key, site.Options[key]),
+				Reloadable: true,
+			})
+		}
+	}
+
+	for _, site := range old {
+		if _, ok := nowMap[site.ID]; !ok {
+			res = append(res, Change{Name: "sites." + site.ID, Old: "（已有）", New: "（删除）", Reloadable: true})
+		}
+	}
+
+	return res
+}
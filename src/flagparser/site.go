@@ -0,0 +1,185 @@
+package flagparser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// DefaultSiteID 默认站点（即全局参数）的ID
+const DefaultSiteID = ""
+
+// SiteOptions 一个站点的参数，站点中没有设置的参数与全局参数相同（用于匹配站点的参数除外）
+type SiteOptions struct {
+	ID string
+
+	Name          string
+	WebURL        string
+	Origin        string // 允许跨域的Origin，同时用于匹配站点
+	Host          string // 用于匹配站点的Host，只能在站点中设置
+	Refer         string // 用于匹配站点的refer，只能在站点中设置
+	NoticeList    string
+	RecipientList string // 用于匹配站点的收件地址
+
+	Notifier          string
+	Webhook           string
+	TelegramAPIURL    string
+	TelegramToken     string
+	TelegramChatID    string
+	SlackWebhook      string
+	SlackAPIURL       string
+	SlackToken        string
+	SlackChannel      string
+	DingTalkWebhook   string
+	DingTalkSecret    string
+	DingTalkMsgType   string
+	FeishuWebhook     string
+	FeishuSecret      string
+	FeishuMsgType     string
+	JSONWebhookURL    string
+	JSONWebhookSecret string
+	JSONWebhookRetry  int
+
+	IPRate             string
+	EmailRate          string
+	SMTPRate           string
+	ThankEmailTemplate string
+	ErrorEmailTemplate string
+}
+
+func (o *SiteOptions) IsDefault() bool {
+	return o.ID == DefaultSiteID
+}
+
+// fields 站点中可以设置的参数
+func (o *SiteOptions) fields() map[string]any {
+	return map[string]any{
+		"name":                 &o.Name,
+		"web-url":              &o.WebURL,
+		"origin":               &o.Origin,
+		"host":                 &o.Host,
+		"refer":                &o.Refer,
+		"notice-list":          &o.NoticeList,
+		"recipient-list":       &o.RecipientList,
+		"notifier":             &o.Notifier,
+		"webhook":              &o.Webhook,
+		"telegram-api-url":     &o.TelegramAPIURL,
+		"telegram-token":       &o.TelegramToken,
+		"telegram-chat-id":     &o.TelegramChatID,
+		"slack-webhook":        &o.SlackWebhook,
+		"slack-api-url":        &o.SlackAPIURL,
+		"slack-token":          &o.SlackToken,
+		"slack-channel":        &o.SlackChannel,
+		"dingtalk-webhook":     &o.DingTalkWebhook,
+		"dingtalk-secret":      &o.DingTalkSecret,
+		"dingtalk-msgtype":     &o.DingTalkMsgType,
+		"feishu-webhook":       &o.FeishuWebhook,
+		"feishu-secret":        &o.FeishuSecret,
+		"feishu-msgtype":       &o.FeishuMsgType,
+		"json-webhook-url":     &o.JSONWebhookURL,
+		"json-webhook-secret":  &o.JSONWebhookSecret,
+		"json-webhook-retry":   &o.JSONWebhookRetry,
+		"ip-rate":              &o.IPRate,
+		"email-rate":           &o.EmailRate,
+		"smtp-rate":            &o.SMTPRate,
+		"thank-email-template": &o.ThankEmailTemplate,
+		"error-email-template": &o.ErrorEmailTemplate,
+	}
+}
+
+func (o *SiteOptions) set(key string, value string) error {
+	switch p := o.fields()[key].(type) {
+	case *string:
+		*p = value
+	case *int:
+		res, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s is not a number", value)
+		}
+		*p = res
+	default:
+		return fmt.Errorf("unknown option: %s", key)
+	}
+	return nil
+}
+
+func isSiteOption(name string) bool {
+	_, ok := (&SiteOptions{}).fields()[name]
+	return ok
+}
+
+// GlobalSiteOptions 默认站点的参数
+func GlobalSiteOptions() *SiteOptions {
+	return &SiteOptions{
+		ID:                 DefaultSiteID,
+		Name:               Name,
+		WebURL:             WebURL,
+		Origin:             Origin,
+		NoticeList:         NoticeList,
+		RecipientList:      RecipientList,
+		Notifier:           Notifier,
+		Webhook:            Webhook,
+		TelegramAPIURL:     TelegramAPIURL,
+		TelegramToken:      TelegramToken,
+		TelegramChatID:     TelegramChatID,
+		SlackWebhook:       SlackWebhook,
+		SlackAPIURL:        SlackAPIURL,
+		SlackToken:         SlackToken,
+		SlackChannel:       SlackChannel,
+		DingTalkWebhook:    DingTalkWebhook,
+		DingTalkSecret:     DingTalkSecret,
+		DingTalkMsgType:    DingTalkMsgType,
+		FeishuWebhook:      FeishuWebhook,
+		FeishuSecret:       FeishuSecret,
+		FeishuMsgType:      FeishuMsgType,
+		JSONWebhookURL:     JSONWebhookURL,
+		JSONWebhookSecret:  JSONWebhookSecret,
+		JSONWebhookRetry:   JSONWebhookRetry,
+		IPRate:             IPRate,
+		EmailRate:          EmailRate,
+		SMTPRate:           SMTPRate,
+		ThankEmailTemplate: ThankEmailTemplate,
+		ErrorEmailTemplate: ErrorEmailTemplate,
+	}
+}
+
+// NewSiteOptions 在全局参数的基础上覆盖站点中设置的参数
+func NewSiteOptions(site *Site) (*SiteOptions, error) {
+	res := GlobalSiteOptions()
+	res.ID = site.ID
+
+	// 用于匹配站点的参数不继承全局参数
+	res.Origin = ""
+	res.RecipientList = ""
+
+	keys := make([]string, 0, len(site.Options))
+	for key := range site.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		err := res.set(key, site.Options[key])
+		if err != nil {
+			return nil, fmt.Errorf("bad option %s of site %s: %s", key, site.ID, err.Error())
+		}
+	}
+
+	return res, nil
+}
+
+// AllSiteOptions 返回默认站点和配置文件中所有站点的参数，默认站点排在第一位
+func AllSiteOptions() ([]*SiteOptions, error) {
+	res := make([]*SiteOptions, 0, len(Sites)+1)
+	res = append(res, GlobalSiteOptions())
+
+	for i := range Sites {
+		opt, err := NewSiteOptions(&Sites[i])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, opt)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"github.com/SongZihuan/anonymous-message/src/emailserver/imapserver"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+type AccountItem struct {
+	ID            string     `json:"id"`
+	Address       string     `json:"address"`
+	User          string     `json:"user"`
+	MailBox       []string   `json:"mailbox"`
+	SiteID        string     `json:"site_id"`
+	Status        string     `json:"status"`
+	IDLE          bool       `json:"idle"`
+	ConnectedAt   *time.Time `json:"connected_at,omitempty"`
+	LastCheck     *time.Time `json:"last_check,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+	ErrorCount    int        `json:"error_count"`
+	Reconnect     int        `json:"reconnect"`
+	Processed     int        `json:"processed"`
+}
+
+// HandlerListAccount GET /admin/api/accounts 所有IMAP账号的运行状态，默认账号的ID为空
+func HandlerListAccount(c *gin.Context) {
+	list := imapserver.AccountHealth()
+	res := make([]AccountItem, 0, len(list))
+
+	for _, h := range list {
+		res = append(res, AccountItem{
+			ID:            h.ID,
+			Address:       h.Address,
+			User:          h.User,
+			MailBox:       h.MailBox,
+			SiteID:        h.SiteID,
+			Status:        h.Status,
+			IDLE:          h.IDLE,
+			ConnectedAt:   zeroTime(h.ConnectedAt),
+			LastCheck:     zeroTime(h.LastCheck),
+			LastError:     h.LastError,
+			LastErrorTime: zeroTime(h.LastErrorTime),
+			ErrorCount:    h.ErrorCount,
+			Reconnect:     h.Reconnect,
+			Processed:     h.Processed,
+		})
+	}
+
+	success(c, res)
+}
+
+func zeroTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
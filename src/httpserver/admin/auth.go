@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/apitoken"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+const tokenKey = "admin-api-token"
+
+type ReturnData struct {
+	Code    int    `json:"code"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func abort(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, &ReturnData{
+		Code:    -status,
+		Success: false,
+		Message: msg,
+	})
+}
+
+func success(c *gin.Context, data any) {
+	c.JSON(http.StatusOK, &ReturnData{
+		Code:    0,
+		Success: true,
+		Data:    data,
+	})
+}
+
+// HandlerAuth 校验请求头 Authorization: Bearer <令牌> 或 X-API-Token: <令牌>
+func HandlerAuth(c *gin.Context) {
+	if !database.Ready() {
+		abort(c, http.StatusServiceUnavailable, "未启用SQLite")
+		return
+	}
+
+	token := strings.TrimSpace(c.GetHeader("X-API-Token"))
+	if token == "" {
+		auth := strings.TrimSpace(c.GetHeader("Authorization"))
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			token = strings.TrimSpace(auth[7:])
+		}
+	}
+
+	if token == "" {
+		c.Header("WWW-Authenticate", "Bearer")
+		abort(c, http.StatusUnauthorized, "缺少API令牌")
+		return
+	}
+
+	record, err := apitoken.Check(token)
+	if err != nil && errors.Is(err, apitoken.ErrInvalidToken) {
+		c.Header("WWW-Authenticate", "Bearer")
+		abort(c, http.StatusUnauthorized, "API令牌无效")
+		return
+	} else if err != nil {
+		fmt.Printf("校验API令牌出现错误: %s\n", err.Error())
+		abort(c, http.StatusInternalServerError, "校验API令牌出现错误")
+		return
+	}
+
+	c.Set(tokenKey, record.Name)
+	c.Next()
+}
+
+// TokenName 当前请求使用的API令牌名称
+func TokenName(c *gin.Context) string {
+	return c.GetString(tokenKey)
+}
@@ -0,0 +1,545 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/workflow"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type MessageItem struct {
+	MailID   string    `json:"mail_id"`
+	Type     string    `json:"type"`
+	SiteID   string    `json:"site_id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	IP       string    `json:"ip"`
+	Subject  string    `json:"subject"`
+	Time     time.Time `json:"time"`
+	Status   string    `json:"status"`
+	Assignee string    `json:"assignee"`
+	Read     bool      `json:"read"`     // 状态不是 new
+	Archived bool      `json:"archived"` // 状态是 archived
+}
+
+type MessageList struct {
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	Size     int           `json:"size"`
+	Messages []MessageItem `json:"messages"`
+}
+
+type MessageDetail struct {
+	MessageItem
+	Content string `json:"content"`
+
+	// 网页留言
+	Refer  string `json:"refer,omitempty"`
+	Origin string `json:"origin,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Ticket bool   `json:"ticket,omitempty"` // 留言人是否有查询令牌
+
+	// 邮箱留言
+	MessageID string     `json:"message_id,omitempty"`
+	Sender    string     `json:"sender,omitempty"`
+	From      string     `json:"from,omitempty"`
+	To        string     `json:"to,omitempty"`
+	ReplyTo   string     `json:"reply_to,omitempty"`
+	SendTime  *time.Time `json:"send_time,omitempty"`
+	SPF       string     `json:"spf,omitempty"` // 发件人认证结果
+	DKIM      string     `json:"dkim,omitempty"`
+	DMARC     string     `json:"dmarc,omitempty"`
+
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+	RepliedAt  *time.Time `json:"replied_at,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	SpamAt     *time.Time `json:"spam_at,omitempty"`
+	AssignedAt *time.Time `json:"assigned_at,omitempty"`
+	Audit      []Audit    `json:"audit"`
+	Replies    []Reply    `json:"replies"`
+
+	Deliveries []Delivery       `json:"deliveries"`
+	Outbox     []OutboxDelivery `json:"outbox"`
+}
+
+// Audit 处理状态和指派的变更记录
+type Audit struct {
+	Action   string    `json:"action"` // status 或 assign
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Operator string    `json:"operator"`
+	Note     string    `json:"note,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Reply 管理员发出的回复
+type Reply struct {
+	ReplyID   string    `json:"reply_id"`
+	MessageID string    `json:"message_id"`
+	To        string    `json:"to"`
+	Subject   string    `json:"subject"`
+	Content   string    `json:"content"`
+	Operator  string    `json:"operator"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Delivery 企业微信或电子邮件的发送记录
+type Delivery struct {
+	Kind       string     `json:"kind"` // wxrobot、email 或 thank_email
+	ID         string     `json:"id"`
+	Found      bool       `json:"found"`
+	Success    bool       `json:"success"`
+	Error      string     `json:"error,omitempty"`
+	Recipients []string   `json:"recipients,omitempty"`
+	Time       *time.Time `json:"time,omitempty"`
+}
+
+// OutboxDelivery 投递队列中的记录
+type OutboxDelivery struct {
+	Notifier string    `json:"notifier"`
+	Status   string    `json:"status"`
+	Attempts int       `json:"attempts"`
+	NextTime time.Time `json:"next_time"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+func typeCode(t database.MsgType) string {
+	switch t {
+	case database.MsgTypeWebsite:
+		return "website"
+	case database.MsgTypeEmail:
+		return "email"
+	case database.MsgTypeSystem:
+		return "system"
+	default:
+		return "unknown"
+	}
+}
+
+func parseType(s string) (database.MsgType, bool) {
+	switch strings.ToLower(s) {
+	case "":
+		return "", true
+	case "website":
+		return database.MsgTypeWebsite, true
+	case "email":
+		return database.MsgTypeEmail, true
+	case "system":
+		return database.MsgTypeSystem, true
+	default:
+		return "", false
+	}
+}
+
+// parseTime 支持 RFC3339 和 2006-01-02，只有日期时 end 为 true 表示取当天结束
+func parseTime(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, flagparser.TimeZone())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad time: %s", s)
+	}
+
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func parseBool(c *gin.Context, key string) (*bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	res, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s 只能是 true 或 false", key)
+	}
+	return &res, nil
+}
+
+// parseStatus 根据 status、read 和 archived 计算要查询的状态，返回 nil 表示不限制，返回空列表表示没有符合的状态
+func parseStatus(c *gin.Context) ([]database.MailStatus, error) {
+	status, err := workflow.ParseStatusList(c.Query("status"))
+	if err != nil {
+		return nil, fmt.Errorf("status 只能是 new、read、replied、archived 或 spam")
+	}
+
+	read, err := parseBool(c, "read")
+	if err != nil {
+		return nil, err
+	}
+
+	archived, err := parseBool(c, "archived")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(status) == 0 && read == nil && archived == nil {
+		return nil, nil
+	} else if len(status) == 0 {
+		status = workflow.AllStatus
+	}
+
+	res := make([]database.MailStatus, 0, len(status))
+	for _, st := range status {
+		if read != nil && *read == (st == database.MailStatusNew) {
+			continue
+		} else if archived != nil && *archived != (st == database.MailStatusArchived) {
+			continue
+		}
+		res = append(res, st)
+	}
+
+	return res, nil
+}
+
+func parseFilter(c *gin.Context) (filter database.MessageFilter, page int, size int, err error) {
+	var ok bool
+
+	filter.Type, ok = parseType(c.Query("type"))
+	if !ok {
+		return filter, 0, 0, fmt.Errorf("type 只能是 website、email 或 system")
+	}
+
+	if siteID, ok := c.GetQuery("site"); ok {
+		filter.SiteID = &siteID
+	}
+
+	if since := c.Query("since"); since != "" {
+		filter.Since, err = parseTime(since, false)
+		if err != nil {
+			return filter, 0, 0, fmt.Errorf("since 格式错误")
+		}
+	}
+
+	if until := c.Query("until"); until != "" {
+		filter.Until, err = parseTime(until, true)
+		if err != nil {
+			return filter, 0, 0, fmt.Errorf("until 格式错误")
+		}
+	}
+
+	filter.Email = strings.TrimSpace(c.Query("email"))
+	filter.IP = strings.TrimSpace(c.Query("ip"))
+	filter.Keyword = strings.TrimSpace(c.Query("q"))
+
+	filter.Status, err = parseStatus(c)
+	if err != nil {
+		return filter, 0, 0, err
+	}
+
+	if assignee, ok := c.GetQuery("assignee"); ok {
+		filter.Assignee = &assignee
+	}
+
+	page = 1
+	if p := c.Query("page"); p != "" {
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			return filter, 0, 0, fmt.Errorf("page 格式错误")
+		}
+	}
+
+	size = DefaultPageSize
+	if s := c.Query("size"); s != "" {
+		size, err = strconv.Atoi(s)
+		if err != nil || size < 1 || size > MaxPageSize {
+			return filter, 0, 0, fmt.Errorf("size 必须在 1 到 %d 之间", MaxPageSize)
+		}
+	}
+
+	filter.Offset = (page - 1) * size
+	filter.Limit = size
+	return filter, page, size, nil
+}
+
+// HandlerListMessage GET /admin/api/messages
+func HandlerListMessage(c *gin.Context) {
+	filter, page, size, err := parseFilter(c)
+	if err != nil {
+		abort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if filter.Status != nil && len(filter.Status) == 0 {
+		success(c, &MessageList{Page: page, Size: size, Messages: []MessageItem{}})
+		return
+	}
+
+	list, total, err := database.FindMessages(filter)
+	if err != nil {
+		fmt.Printf("查询信件出现错误: %s\n", err.Error())
+		abort(c, http.StatusInternalServerError, "查询信件出现错误")
+		return
+	}
+
+	res := &MessageList{
+		Total:    total,
+		Page:     page,
+		Size:     size,
+		Messages: make([]MessageItem, 0, len(list)),
+	}
+
+	for _, m := range list {
+		res.Messages = append(res.Messages, MessageItem{
+			MailID:   m.MailID,
+			Type:     typeCode(m.MailType),
+			SiteID:   m.SiteID,
+			Name:     m.Name,
+			Email:    m.Email,
+			IP:       m.IP,
+			Subject:  m.Subject,
+			Time:     m.Time,
+			Status:   string(m.Status),
+			Assignee: m.Assignee,
+			Read:     m.Status != database.MailStatusNew,
+			Archived: m.Status == database.MailStatusArchived,
+		})
+	}
+
+	success(c, res)
+}
+
+// HandlerGetMessage GET /admin/api/messages/:id
+func HandlerGetMessage(c *gin.Context) {
+	res, err := LoadMessage(c.Param("id"))
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		abort(c, http.StatusNotFound, "信件不存在")
+		return
+	} else if err != nil {
+		fmt.Printf("查询信件出现错误: %s\n", err.Error())
+		abort(c, http.StatusInternalServerError, "查询信件出现错误")
+		return
+	}
+
+	success(c, res)
+}
+
+// LoadMessage 读取信件及其投递记录
+func LoadMessage(mailID string) (*MessageDetail, error) {
+	record, err := database.FindMailRecord(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MessageDetail{
+		MessageItem: MessageItem{
+			MailID: mailID,
+			Type:   typeCode(record.MailType),
+		},
+	}
+
+	var wxrobotID, emailID, thankEmailID *string
+
+	switch record.MailType {
+	case database.MsgTypeWebsite:
+		mail, err := database.FindAMMail(mailID)
+		if err != nil {
+			return nil, err
+		}
+
+		res.SiteID = mail.SiteID
+		res.Name = mail.Name
+		res.Email = mail.Email
+		res.IP = mail.IP
+		res.Time = mail.Time
+		res.Content = mail.Content
+		res.Refer = mail.Refer
+		res.Origin = mail.Origin
+		res.Host = mail.Host
+
+		res.Ticket, err = database.HasTicket(mailID)
+		if err != nil {
+			return nil, err
+		}
+
+		wxrobotID, emailID, thankEmailID = nullString(mail.WxRobotID), nullString(mail.EmailID), nullString(mail.ThankEmailID)
+	case database.MsgTypeEmail:
+		mail, err := database.FindIMAPMail(mailID)
+		if err != nil {
+			return nil, err
+		}
+
+		res.SiteID = mail.SiteID
+		res.Name = mail.From
+		res.Email = mail.ReplyTo
+		res.Subject = mail.Subject
+		res.Time = mail.Time
+		res.Content = mail.Content
+		res.MessageID = mail.MessageID
+		res.Sender = mail.Sender
+		res.From = mail.From
+		res.To = mail.To
+		res.ReplyTo = mail.ReplyTo
+		res.SendTime = &mail.SendTime
+		res.SPF = mail.SPF
+		res.DKIM = mail.DKIM
+		res.DMARC = mail.DMARC
+
+		wxrobotID, emailID, thankEmailID = nullString(mail.WxRobotID), nullString(mail.EmailID), nullString(mail.ThankEmailID)
+	case database.MsgTypeSystem:
+		mail, err := database.FindSNMail(mailID)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Subject = mail.Subject
+		res.Time = mail.Time
+		res.Content = mail.Content
+
+		wxrobotID, emailID = nullString(mail.WxRobotID), nullString(mail.EmailID)
+	default:
+		return nil, database.ErrNotFound
+	}
+
+	res.Status = string(database.MailStatusNew)
+
+	state, err := database.FindMailState(mailID)
+	if err == nil {
+		res.Status = string(state.Status)
+		res.Assignee = state.Assignee
+		res.ReadAt = nullTime(state.ReadAt)
+		res.RepliedAt = nullTime(state.RepliedAt)
+		res.ArchivedAt = nullTime(state.ArchivedAt)
+		res.SpamAt = nullTime(state.SpamAt)
+		res.AssignedAt = nullTime(state.AssignedAt)
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+
+	res.Read = res.Status != string(database.MailStatusNew)
+	res.Archived = res.Status == string(database.MailStatusArchived)
+
+	audit, err := database.FindMailAudit(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Audit = make([]Audit, 0, len(audit))
+	for _, a := range audit {
+		res.Audit = append(res.Audit, Audit{
+			Action:   string(a.Action),
+			From:     a.From,
+			To:       a.To,
+			Operator: a.Operator,
+			Note:     a.Note,
+			Time:     a.Time,
+		})
+	}
+
+	replies, err := database.FindMailReply(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Replies = make([]Reply, 0, len(replies))
+	for _, r := range replies {
+		res.Replies = append(res.Replies, Reply{
+			ReplyID:   r.ReplyID,
+			MessageID: r.MessageID,
+			To:        r.To,
+			Subject:   r.Subject,
+			Content:   r.Content,
+			Operator:  r.Operator,
+			Success:   r.Success,
+			Error:     r.ErrMsg.String,
+			Time:      r.Time,
+		})
+	}
+
+	res.Deliveries = make([]Delivery, 0, 3)
+
+	if wxrobotID != nil {
+		d := Delivery{Kind: "wxrobot", ID: *wxrobotID}
+		if r, err := database.FindWxRobotRecord(*wxrobotID); err == nil {
+			d.Found = true
+			d.Success = r.Success && (!r.FileSuccess.Valid || r.FileSuccess.Bool)
+			d.Time = &r.Time
+			if r.ErrMsg.Valid {
+				d.Error = r.ErrMsg.String
+			} else if r.FileErrMsg.Valid {
+				d.Error = r.FileErrMsg.String
+			}
+		} else if !errors.Is(err, database.ErrNotFound) {
+			return nil, err
+		}
+		res.Deliveries = append(res.Deliveries, d)
+	}
+
+	for _, smtp := range []struct {
+		kind string
+		id   *string
+	}{{"email", emailID}, {"thank_email", thankEmailID}} {
+		if smtp.id == nil {
+			continue
+		}
+
+		d := Delivery{Kind: smtp.kind, ID: *smtp.id}
+		if r, recipients, err := database.FindSMTPRecord(*smtp.id); err == nil {
+			d.Found = true
+			d.Success = r.Success
+			d.Time = &r.Time
+			if r.ErrMsg.Valid {
+				d.Error = r.ErrMsg.String
+			}
+			for _, rec := range recipients {
+				d.Recipients = append(d.Recipients, rec.Recipient)
+			}
+		} else if !errors.Is(err, database.ErrNotFound) {
+			return nil, err
+		}
+		res.Deliveries = append(res.Deliveries, d)
+	}
+
+	outbox, err := database.FindMailOutbox(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Outbox = make([]OutboxDelivery, 0, len(outbox))
+	for _, o := range outbox {
+		res.Outbox = append(res.Outbox, OutboxDelivery{
+			Notifier: o.Notifier,
+			Status:   string(o.Status),
+			Attempts: o.Attempts,
+			NextTime: o.NextTime,
+			Error:    o.ErrMsg.String,
+			Time:     o.Time,
+		})
+	}
+
+	return res, nil
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid || s.String == "" {
+		return nil
+	}
+	return &s.String
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
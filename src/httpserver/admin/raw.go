@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/attachment"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// HandlerRawMessage GET /admin/api/messages/:id/raw 下载邮箱留言的原始邮件（.eml），需要启用 --raw-mail
+func HandlerRawMessage(c *gin.Context) {
+	mailID := c.Param("id")
+
+	raw, err := attachment.Original(mailID)
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		abort(c, http.StatusNotFound, "原始邮件不存在")
+		return
+	} else if err != nil {
+		fmt.Printf("读取原始邮件出现错误: %s\n", err.Error())
+		abort(c, http.StatusInternalServerError, "读取原始邮件出现错误")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.eml"`, mailID))
+	c.Data(http.StatusOK, "message/rfc822", raw)
+}
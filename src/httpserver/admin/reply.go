@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/reply"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type ReplyData struct {
+	Subject string `json:"subject"`
+	Content string `json:"content"`
+}
+
+// HandlerReplyMessage POST /admin/api/messages/:id/reply 通过邮件回复信件，请求体 {"subject":"...","content":"..."}
+func HandlerReplyMessage(c *gin.Context) {
+	mailID := c.Param("id")
+
+	var data ReplyData
+	err := c.ShouldBindJSON(&data)
+	if err != nil {
+		abort(c, http.StatusBadRequest, "请求体错误")
+		return
+	}
+
+	_, err = reply.Send(mailID, data.Subject, data.Content, "api:"+TokenName(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			abort(c, http.StatusNotFound, "信件不存在")
+		case errors.Is(err, reply.ErrEmptyContent):
+			abort(c, http.StatusBadRequest, "回复内容不能为空")
+		case errors.Is(err, reply.ErrContentTooLong):
+			abort(c, http.StatusBadRequest, fmt.Sprintf("回复内容不能超过 %d 字节", reply.MaxContentLength))
+		case errors.Is(err, reply.ErrNotReplyable):
+			abort(c, http.StatusBadRequest, "系统留言不能回复")
+		case errors.Is(err, reply.ErrNoAddress):
+			abort(c, http.StatusBadRequest, "信件没有有效的邮箱地址")
+		case errors.Is(err, reply.ErrRateLimit):
+			abort(c, http.StatusTooManyRequests, "回复过于频繁，请稍后再试")
+		default:
+			fmt.Printf("发送回复出现错误: %s\n", err.Error())
+			abort(c, http.StatusBadGateway, "发送回复失败："+err.Error())
+		}
+		return
+	}
+
+	res, err := LoadMessage(mailID)
+	if err != nil {
+		fmt.Printf("查询信件出现错误: %s\n", err.Error())
+		abort(c, http.StatusInternalServerError, "查询信件出现错误")
+		return
+	}
+
+	success(c, res)
+}
@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"github.com/SongZihuan/anonymous-message/src/site"
+	"github.com/gin-gonic/gin"
+)
+
+type SiteItem struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	WebURL string `json:"web_url"`
+}
+
+// HandlerListSite GET /admin/api/sites 所有站点，默认站点的ID为空
+func HandlerListSite(c *gin.Context) {
+	list := site.List()
+	res := make([]SiteItem, 0, len(list))
+
+	for _, s := range list {
+		res = append(res, SiteItem{
+			ID:     s.ID,
+			Name:   s.Name,
+			WebURL: s.WebURL,
+		})
+	}
+
+	success(c, res)
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/workflow"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type ActionData struct {
+	Status   string `json:"status"`
+	Assignee string `json:"assignee"`
+	Note     string `json:"note"`
+}
+
+// HandlerMessageAction POST /admin/api/messages/:id/:action
+// action 为 status（请求体 {"status":"replied","note":"..."}）、assign（请求体 {"assignee":"...","note":"..."}），
+// 或者快捷操作 read（只把新信件标记为已读）、unread、archive、unarchive、spam
+func HandlerMessageAction(c *gin.Context) {
+	mailID := c.Param("id")
+	operator := "api:" + TokenName(c)
+
+	var data ActionData
+	if c.Param("action") == "status" || c.Param("action") == "assign" {
+		err := c.ShouldBindJSON(&data)
+		if err != nil {
+			abort(c, http.StatusBadRequest, "请求体错误")
+			return
+		}
+	}
+
+	var err error
+
+	switch c.Param("action") {
+	case "status":
+		var status database.MailStatus
+		status, err = workflow.ParseStatus(data.Status)
+		if err != nil {
+			abort(c, http.StatusBadRequest, "status 只能是 new、read、replied、archived 或 spam")
+			return
+		}
+		_, err = workflow.SetStatus(mailID, status, operator, data.Note)
+	case "assign":
+		if len(data.Assignee) > 40 {
+			abort(c, http.StatusBadRequest, "处理人名称太长")
+			return
+		}
+		_, err = workflow.Assign(mailID, data.Assignee, operator, data.Note)
+	case "read":
+		_, err = workflow.MarkRead(mailID, operator)
+	case "unread":
+		_, err = workflow.SetStatus(mailID, database.MailStatusNew, operator, "")
+	case "archive":
+		_, err = workflow.SetStatus(mailID, database.MailStatusArchived, operator, "")
+	case "unarchive":
+		_, err = workflow.SetStatus(mailID, database.MailStatusRead, operator, "")
+	case "spam":
+		_, err = workflow.SetStatus(mailID, database.MailStatusSpam, operator, "")
+	default:
+		abort(c, http.StatusNotFound, "未知的操作")
+		return
+	}
+
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		abort(c, http.StatusNotFound, "信件不存在")
+		return
+	} else if err != nil {
+		fmt.Printf("更新信件状态出现错误: %s\n", err.Error())
+		abort(c, http.StatusInternalServerError, "更新信件状态出现错误")
+		return
+	}
+
+	res, err := LoadMessage(mailID)
+	if err != nil {
+		fmt.Printf("查询信件出现错误: %s\n", err.Error())
+		abort(c, http.StatusInternalServerError, "查询信件出现错误")
+		return
+	}
+
+	success(c, res)
+}
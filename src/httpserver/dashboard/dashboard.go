@@ -0,0 +1,43 @@
+package dashboard
+
+import (
+	"embed"
+	"github.com/gin-gonic/gin"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+var assets http.FileSystem
+
+func init() {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	assets = http.FS(sub)
+}
+
+// contentSecurityPolicy 页面只加载自身的脚本和样式，留言内容即使包含HTML也不会被执行
+const contentSecurityPolicy = "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'"
+
+func HandlerRedirect(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, "/admin/")
+}
+
+// HandlerIndex GET /admin/ 管理页面，令牌由页面保存在浏览器中并用于请求管理API
+func HandlerIndex(c *gin.Context) {
+	c.Header("Content-Security-Policy", contentSecurityPolicy)
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Cache-Control", "no-cache")
+	c.FileFromFS("/", assets) // 目录会返回 index.html
+}
+
+// HandlerAssets GET /admin/assets/*filepath 页面使用的脚本和样式
+func HandlerAssets(c *gin.Context) {
+	c.Header("Content-Security-Policy", contentSecurityPolicy)
+	c.Header("Cache-Control", "no-cache")
+	c.FileFromFS(c.Param("filepath"), assets)
+}
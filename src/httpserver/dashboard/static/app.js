@@ -0,0 +1,441 @@
+// 留言管理页面：通过 /admin/api 读取信件，所有留言内容只以文本方式显示
+(function () {
+  "use strict";
+
+  var TOKEN_KEY = "am-admin-token";
+  var PAGE_SIZE = 30;
+
+  var TYPE_NAME = { website: "网页留言", email: "邮箱留言", system: "系统留言" };
+  var KIND_NAME = { wxrobot: "企业微信", email: "通知邮件", thank_email: "感谢信" };
+  var OUTBOX_NAME = { pending: "等待重试", success: "成功", dead: "死信", resent: "已重新投递" };
+  var STATUS_NAME = { "new": "新信件", read: "已读", replied: "已回复", archived: "已归档", spam: "垃圾信件" };
+  var FOLDER_STATUS = { inbox: "new,read,replied", unhandled: "new,read", replied: "replied", archived: "archived", spam: "spam" };
+
+  var state = {
+    token: sessionStorage.getItem(TOKEN_KEY) || localStorage.getItem(TOKEN_KEY) || "",
+    folder: "inbox",
+    page: 1,
+    total: 0,
+    selected: "",
+    sites: {}
+  };
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (key) {
+      if (key === "text") {
+        node.textContent = attrs[key];
+      } else if (key === "class") {
+        node.className = attrs[key];
+      } else {
+        node.setAttribute(key, attrs[key]);
+      }
+    });
+    (children || []).forEach(function (child) {
+      if (child) {
+        node.appendChild(child);
+      }
+    });
+    return node;
+  }
+
+  function formatTime(value) {
+    if (!value) {
+      return "";
+    }
+    var d = new Date(value);
+    if (isNaN(d.getTime())) {
+      return value;
+    }
+    function pad(n) {
+      return n < 10 ? "0" + n : "" + n;
+    }
+    return d.getFullYear() + "-" + pad(d.getMonth() + 1) + "-" + pad(d.getDate()) + " " + pad(d.getHours()) + ":" + pad(d.getMinutes());
+  }
+
+  function siteName(id) {
+    if (id in state.sites) {
+      return state.sites[id];
+    }
+    return id;
+  }
+
+  function api(method, path, params, body) {
+    var url = "api" + path;
+    if (params) {
+      var query = Object.keys(params).filter(function (key) {
+        return params[key] !== undefined && params[key] !== null && params[key] !== "*" && (params[key] !== "" || key === "site");
+      }).map(function (key) {
+        return encodeURIComponent(key) + "=" + encodeURIComponent(params[key]);
+      }).join("&");
+      if (query) {
+        url += "?" + query;
+      }
+    }
+
+    var headers = { "Authorization": "Bearer " + state.token };
+    if (body) {
+      headers["Content-Type"] = "application/json";
+    }
+
+    return fetch(url, {
+      method: method,
+      headers: headers,
+      body: body ? JSON.stringify(body) : undefined,
+      credentials: "omit",
+      cache: "no-store"
+    }).then(function (res) {
+      return res.json().catch(function () {
+        return { success: false, message: "服务器返回错误（" + res.status + "）" };
+      }).then(function (body) {
+        if (res.status === 401) {
+          logout("令牌无效或已被吊销，请重新登录。");
+          throw new Error(body.message || "未登录");
+        }
+        if (!body.success) {
+          throw new Error(body.message || "请求失败");
+        }
+        return body.data;
+      });
+    });
+  }
+
+  function showError(err) {
+    var detail = $("detail");
+    detail.textContent = "";
+    detail.appendChild(el("p", { class: "error", text: err.message || String(err) }));
+  }
+
+  function login(token, remember) {
+    state.token = token;
+    return api("GET", "/sites").then(function (sites) {
+      if (remember) {
+        localStorage.setItem(TOKEN_KEY, token);
+      } else {
+        sessionStorage.setItem(TOKEN_KEY, token);
+      }
+
+      var select = $("search-site");
+      while (select.options.length > 1) {
+        select.remove(1);
+      }
+      state.sites = {};
+      sites.forEach(function (s) {
+        state.sites[s.id] = s.id === "" ? s.name + "（默认）" : s.name + "（" + s.id + "）";
+        select.appendChild(el("option", { value: s.id, text: state.sites[s.id] }));
+      });
+
+      $("login").hidden = true;
+      $("app").hidden = false;
+      loadList();
+    });
+  }
+
+  function logout(msg) {
+    state.token = "";
+    sessionStorage.removeItem(TOKEN_KEY);
+    localStorage.removeItem(TOKEN_KEY);
+    $("app").hidden = true;
+    $("login").hidden = false;
+    $("login-error").textContent = msg || "";
+  }
+
+  function listParams() {
+    var params = {
+      page: state.page,
+      size: PAGE_SIZE,
+      q: $("search-q").value.trim(),
+      type: $("search-type").value,
+      site: $("search-site").value,
+      assignee: $("search-assignee").value.trim(),
+      since: $("search-since").value,
+      until: $("search-until").value
+    };
+
+    if (state.folder in FOLDER_STATUS) {
+      params.status = FOLDER_STATUS[state.folder];
+    }
+
+    return params;
+  }
+
+  function loadList() {
+    return api("GET", "/messages", listParams()).then(function (data) {
+      state.total = data.total;
+      renderList(data.messages);
+    }).catch(showError);
+  }
+
+  function renderList(messages) {
+    var ul = $("messages");
+    ul.textContent = "";
+
+    if (messages.length === 0) {
+      ul.appendChild(el("li", { class: "empty", text: "没有信件" }));
+    }
+
+    messages.forEach(function (m) {
+      var title = m.subject || m.name || m.email || "（无标题）";
+      var li = el("li", { class: (m.status === "new" ? "unread" : "") + (m.mail_id === state.selected ? " selected" : "") }, [
+        el("div", { class: "meta" }, [
+          el("span", {}, [
+            el("span", { class: "tag", text: TYPE_NAME[m.type] || m.type }),
+            m.site_id ? el("span", { class: "tag", text: siteName(m.site_id) }) : null,
+            m.status !== "new" && m.status !== "read" ? el("span", { class: "tag", text: STATUS_NAME[m.status] || m.status }) : null,
+            m.assignee ? el("span", { class: "tag", text: "处理人：" + m.assignee }) : null
+          ]),
+          el("span", { text: formatTime(m.time) })
+        ]),
+        el("div", { class: "title", text: title }),
+        el("div", { class: "preview", text: [m.name, m.email, m.ip].filter(Boolean).join(" · ") })
+      ]);
+      li.addEventListener("click", function () {
+        openMessage(m.mail_id);
+      });
+      ul.appendChild(li);
+    });
+
+    var pages = Math.max(1, Math.ceil(state.total / PAGE_SIZE));
+    $("page-info").textContent = "第 " + state.page + " / " + pages + " 页，共 " + state.total + " 封";
+    $("prev").disabled = state.page <= 1;
+    $("next").disabled = state.page >= pages;
+  }
+
+  function openMessage(mailID) {
+    state.selected = mailID;
+    return api("GET", "/messages/" + encodeURIComponent(mailID)).then(function (m) {
+      if (m.status === "new") {
+        return api("POST", "/messages/" + encodeURIComponent(mailID) + "/read").then(function (res) {
+          renderDetail(res);
+          loadList();
+        });
+      }
+      renderDetail(m);
+      loadList();
+    }).catch(showError);
+  }
+
+  function action(mailID, name, body) {
+    return api("POST", "/messages/" + encodeURIComponent(mailID) + "/" + name, null, body).then(function (m) {
+      renderDetail(m);
+      loadList();
+    }).catch(showError);
+  }
+
+  function row(name, value) {
+    if (!value) {
+      return null;
+    }
+    return el("tr", {}, [el("th", { text: name }), el("td", { text: value })]);
+  }
+
+  function statusText(ok, wait) {
+    if (wait) {
+      return el("span", { class: "status-wait", text: "进行中" });
+    }
+    return el("span", { class: ok ? "status-ok" : "status-fail", text: ok ? "成功" : "失败" });
+  }
+
+  function renderDetail(m) {
+    var detail = $("detail");
+    detail.textContent = "";
+
+    var note = el("input", { type: "text", placeholder: "备注（可选）" });
+    var actions = el("div", { class: "actions" });
+    [
+      ["new", "标记为未读"],
+      ["replied", "标记为已回复"],
+      ["archived", "归档"],
+      ["spam", "标记为垃圾信件"],
+      ["read", "移回收件箱"]
+    ].forEach(function (item) {
+      if (item[0] === m.status || (item[0] === "read" && m.status !== "archived" && m.status !== "spam")) {
+        return;
+      }
+      var button = el("button", { class: item[0] === "archived" ? "primary" : "", text: item[1] });
+      button.addEventListener("click", function () {
+        action(m.mail_id, "status", { status: item[0], note: note.value.trim() });
+      });
+      actions.appendChild(button);
+    });
+
+    var assignee = el("input", { type: "text", placeholder: "处理人", maxlength: "40", value: m.assignee || "" });
+    var assignButton = el("button", { text: "指派" });
+    assignButton.addEventListener("click", function () {
+      action(m.mail_id, "assign", { assignee: assignee.value.trim(), note: note.value.trim() });
+    });
+
+    detail.appendChild(el("h2", { text: m.subject || m.name || TYPE_NAME[m.type] || m.type }));
+    detail.appendChild(actions);
+    detail.appendChild(el("div", { class: "actions" }, [assignee, assignButton, note]));
+
+    detail.appendChild(el("table", {}, [
+      row("类型", TYPE_NAME[m.type] || m.type),
+      row("站点", m.type === "system" ? "" : siteName(m.site_id)),
+      row("时间", formatTime(m.time)),
+      row("名字", m.name),
+      row("邮箱", m.email),
+      row("IP地址", m.ip),
+      row("refer", m.refer),
+      row("Origin", m.origin),
+      row("Host", m.host),
+      row("发送人", m.sender),
+      row("宣称发送人", m.from),
+      row("回复地址", m.reply_to),
+      row("收件人", m.to),
+      row("邮件日期", formatTime(m.send_time)),
+      row("邮件 MessageID", m.message_id),
+      row("状态", STATUS_NAME[m.status] || m.status),
+      row("处理人", m.assignee),
+      row("已读时间", formatTime(m.read_at)),
+      row("回复时间", formatTime(m.replied_at)),
+      row("归档时间", formatTime(m.archived_at)),
+      row("标记垃圾时间", formatTime(m.spam_at)),
+      row("指派时间", formatTime(m.assigned_at)),
+      row("信件ID", m.mail_id)
+    ]));
+
+    detail.appendChild(el("pre", { text: m.content }));
+
+    if (m.type !== "system") {
+      renderReply(detail, m);
+    }
+
+    detail.appendChild(el("h3", { text: "通知发送记录" }));
+    if (m.deliveries.length === 0 && m.outbox.length === 0) {
+      detail.appendChild(el("p", { class: "empty", text: "没有发送记录" }));
+    }
+
+    if (m.deliveries.length > 0) {
+      detail.appendChild(el("table", {}, m.deliveries.map(function (d) {
+        var info = d.found ? [formatTime(d.time), d.error, (d.recipients || []).join("，")].filter(Boolean).join("  ") : "没有找到发送记录";
+        return el("tr", {}, [
+          el("th", { text: KIND_NAME[d.kind] || d.kind }),
+          el("td", {}, [d.found ? statusText(d.success) : statusText(false), el("span", { text: "  " + info })])
+        ]);
+      })));
+    }
+
+    if (m.outbox.length > 0) {
+      detail.appendChild(el("h3", { text: "投递队列" }));
+      detail.appendChild(el("table", {}, m.outbox.map(function (o) {
+        var cls = o.status === "success" || o.status === "resent" ? "status-ok" : (o.status === "dead" ? "status-fail" : "status-wait");
+        var info = "尝试 " + o.attempts + " 次" + (o.status === "pending" ? "，下次 " + formatTime(o.next_time) : "") + (o.error ? "  " + o.error : "");
+        return el("tr", {}, [
+          el("th", { text: o.notifier }),
+          el("td", {}, [el("span", { class: cls, text: OUTBOX_NAME[o.status] || o.status }), el("span", { text: "  " + info })])
+        ]);
+      })));
+    }
+
+    if (m.audit.length > 0) {
+      detail.appendChild(el("h3", { text: "处理记录" }));
+      detail.appendChild(el("table", {}, m.audit.map(function (a) {
+        var change = a.action === "assign" ?
+          "处理人：" + (a.from || "无") + " → " + (a.to || "无") :
+          "状态：" + (STATUS_NAME[a.from] || a.from || "无") + " → " + (STATUS_NAME[a.to] || a.to);
+        return el("tr", {}, [
+          el("th", { text: formatTime(a.time) }),
+          el("td", { text: [change, a.operator, a.note].filter(Boolean).join("  ") })
+        ]);
+      })));
+    }
+  }
+
+  function renderReply(detail, m) {
+    detail.appendChild(el("h3", { text: "回复" }));
+
+    if (m.replies.length > 0) {
+      detail.appendChild(el("table", {}, m.replies.map(function (r) {
+        return el("tr", {}, [
+          el("th", { text: formatTime(r.time) }),
+          el("td", {}, [
+            statusText(r.success),
+            el("span", { text: "  " + [r.to, r.operator, r.error].filter(Boolean).join("  ") }),
+            el("pre", { text: r.content })
+          ])
+        ]);
+      })));
+    }
+
+    var byTicket = !(m.email || m.reply_to);
+    if (byTicket && !m.ticket) {
+      detail.appendChild(el("p", { class: "empty", text: "留言人没有留下邮箱，无法回复" }));
+      return;
+    } else if (byTicket) {
+      detail.appendChild(el("p", { class: "empty", text: "留言人没有留下邮箱，回复将显示在留言人的查询页面" }));
+    }
+
+    var subject = el("input", { type: "text", placeholder: "主题（可选）" });
+    var content = el("textarea", { rows: "6", placeholder: byTicket ? "回复内容，留言人可以通过查询令牌查看" : "回复内容，将通过邮件发送给留言人" });
+    var send = el("button", { class: "primary", text: "发送回复" });
+    var error = el("p", { class: "error" });
+    send.addEventListener("click", function () {
+      error.textContent = "";
+      send.disabled = true;
+      api("POST", "/messages/" + encodeURIComponent(m.mail_id) + "/reply", null, {
+        subject: subject.value.trim(),
+        content: content.value
+      }).then(function (res) {
+        renderDetail(res);
+        loadList();
+      }).catch(function (err) {
+        send.disabled = false;
+        error.textContent = err.message;
+      });
+    });
+
+    detail.appendChild(el("div", { class: "reply" }, [subject, content, el("div", { class: "actions" }, [send]), error]));
+  }
+
+  $("login-form").addEventListener("submit", function (e) {
+    e.preventDefault();
+    $("login-error").textContent = "";
+    login($("login-token").value.trim(), $("login-remember").checked).catch(function (err) {
+      $("login-error").textContent = err.message;
+    });
+  });
+
+  $("logout").addEventListener("click", function () {
+    logout("");
+  });
+
+  Array.prototype.forEach.call(document.querySelectorAll("#folders button"), function (button) {
+    button.addEventListener("click", function () {
+      Array.prototype.forEach.call(document.querySelectorAll("#folders button"), function (b) {
+        b.classList.toggle("active", b === button);
+      });
+      state.folder = button.getAttribute("data-folder");
+      state.page = 1;
+      loadList();
+    });
+  });
+
+  $("search").addEventListener("submit", function (e) {
+    e.preventDefault();
+    state.page = 1;
+    loadList();
+  });
+
+  $("prev").addEventListener("click", function () {
+    state.page--;
+    loadList();
+  });
+
+  $("next").addEventListener("click", function () {
+    state.page++;
+    loadList();
+  });
+
+  if (state.token) {
+    login(state.token, !!localStorage.getItem(TOKEN_KEY)).catch(function () {
+      logout("");
+    });
+  } else {
+    logout("");
+  }
+})();
@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"github.com/SongZihuan/anonymous-message/src/httpserver/admin"
+	"github.com/SongZihuan/anonymous-message/src/httpserver/dashboard"
 	handler2 "github.com/SongZihuan/anonymous-message/src/httpserver/handler"
 	"github.com/gin-gonic/gin"
 )
@@ -18,10 +20,27 @@ func InitEngine() error {
 	Engine.POST("/message/", handler2.HandlerMessage)
 	Engine.GET("/hello", handler2.HandlerHelloWorld)
 	Engine.GET("/hello/", handler2.HandlerHelloWorld)
+	Engine.GET("/ticket", handler2.HandlerTicket)
+	Engine.GET("/ticket/", handler2.HandlerTicket)
 
 	Engine.OPTIONS("/", handler2.HandlerOptions)
 	Engine.OPTIONS("/message", handler2.HandlerOptions)
 	Engine.OPTIONS("/hello", handler2.HandlerOptions)
+	Engine.OPTIONS("/ticket", handler2.HandlerTicketOptions)
+	Engine.OPTIONS("/ticket/", handler2.HandlerTicketOptions)
+
+	adminAPI := Engine.Group("/admin/api", admin.HandlerAuth)
+	adminAPI.GET("/messages", admin.HandlerListMessage)
+	adminAPI.GET("/messages/:id", admin.HandlerGetMessage)
+	adminAPI.GET("/messages/:id/raw", admin.HandlerRawMessage)
+	adminAPI.POST("/messages/:id/reply", admin.HandlerReplyMessage)
+	adminAPI.POST("/messages/:id/:action", admin.HandlerMessageAction)
+	adminAPI.GET("/sites", admin.HandlerListSite)
+	adminAPI.GET("/accounts", admin.HandlerListAccount)
+
+	Engine.GET("/admin", dashboard.HandlerRedirect)
+	Engine.GET("/admin/", dashboard.HandlerIndex)
+	Engine.GET("/admin/assets/*filepath", dashboard.HandlerAssets)
 
 	Engine.NoRoute(handler2.HandlerMethodNotFound)
 	Engine.NoMethod(handler2.HandlerMethodNotAllowed)
@@ -3,13 +3,14 @@ package handler
 import (
 	"fmt"
 	"github.com/SongZihuan/anonymous-message/src/database"
-	"github.com/SongZihuan/anonymous-message/src/emailserver/emailaddress"
 	"github.com/SongZihuan/anonymous-message/src/emailserver/smtpserver"
 	"github.com/SongZihuan/anonymous-message/src/flagparser"
 	"github.com/SongZihuan/anonymous-message/src/maxlimit"
-	"github.com/SongZihuan/anonymous-message/src/messageutils"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
 	"github.com/SongZihuan/anonymous-message/src/reqrate"
 	"github.com/SongZihuan/anonymous-message/src/sender"
+	"github.com/SongZihuan/anonymous-message/src/site"
+	"github.com/SongZihuan/anonymous-message/src/ticket"
 	"github.com/SongZihuan/anonymous-message/src/utils"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -32,6 +33,7 @@ type ReturnData struct {
 	Success    bool   `json:"success"`
 	Message    string `json:"message"`
 	ErrMessage string `json:"error,omitempty"`
+	Ticket     string `json:"ticket,omitempty"` // 查询令牌，留言人凭此查看处理状态和回复
 }
 
 func HandlerMessage(c *gin.Context) {
@@ -80,6 +82,8 @@ func HandlerMessage(c *gin.Context) {
 		return
 	}
 
+	st := site.MatchRequest(origin, host, data.Refer)
+
 	data.Email = strings.ReplaceAll(data.Email, "\r\n", "\n")
 	data.Email = strings.TrimLeft(data.Email, "\n")
 	data.Email = strings.TrimRight(data.Email, "\n")
@@ -90,16 +94,7 @@ func HandlerMessage(c *gin.Context) {
 	if data.Email != "" {
 		_res, err := mail.ParseAddress(data.Email)
 		if err == nil && utils.IsValidEmail(_res.Address) {
-			isMyAddr := func() bool {
-				for _, rec := range emailaddress.RecipientAddress {
-					if _res.Address == rec.Address {
-						return true
-					}
-				}
-				return false
-			}()
-
-			if !isMyAddr {
+			if !site.IsRecipient(_res.Address) {
 				userAddr = _res
 			} else {
 				JSON(200, &ReturnData{
@@ -142,7 +137,7 @@ func HandlerMessage(c *gin.Context) {
 			msg := strings.TrimRight(obj.Message, "。")
 			msg = strings.TrimRight(msg, "！")
 
-			_, _ = smtpserver.SendErrorMsg("信件拒收通知", "", emailaddress.DefaultRecipientAddress, userAddr, msg)
+			_, _ = smtpserver.SendErrorMsg(st.ID, "信件拒收通知", "", st.Address(), userAddr, msg)
 		}
 	}
 
@@ -150,11 +145,11 @@ func HandlerMessage(c *gin.Context) {
 	var EmailRateLimit = false
 
 	clientIP := c.ClientIP()
-	if !reqrate.CheckHttpReqIP(clientIP) {
+	if !reqrate.CheckHttpReqIP(st.ID, clientIP) {
 		IPRateLimit = true
 	}
 
-	if userAddr != nil && !reqrate.CheckMailAddressRate(userAddr.Address) {
+	if userAddr != nil && !reqrate.CheckMailAddressRate(st.ID, userAddr.Address) {
 		EmailRateLimit = true
 	}
 
@@ -242,7 +237,7 @@ func HandlerMessage(c *gin.Context) {
 				msg := strings.TrimRight(obj.Message, "。")
 				msg = strings.TrimRight(obj.Message, "！")
 
-				_, _ = smtpserver.SendErrorMsg("信件拒收通知", "", emailaddress.DefaultRecipientAddress, userAddr, msg)
+				_, _ = smtpserver.SendErrorMsg(st.ID, "信件拒收通知", "", st.Address(), userAddr, msg)
 			}
 		}
 	}
@@ -322,169 +317,67 @@ func HandlerMessage(c *gin.Context) {
 
 		defer close(initchan)
 
-		err := sender.AMDataBase(mailID, safeName, data.Email, safeMsg, safeRefer, origin, host, clientIP, now)
+		err := sender.AMDataBase(st.ID, mailID, safeName, data.Email, safeMsg, safeRefer, origin, host, clientIP, now)
 		if err != nil {
 			fmt.Printf("数据库提交消息出现错误: %s\n", err.Error())
 		}
 	}()
 
 	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				if _err, ok := r.(error); ok {
-					fmt.Printf("企业微信发送消息出现致命错误: %s\n", _err.Error())
-					if err != nil {
-						err = _err
-						return
-					}
-				} else {
-					fmt.Printf("企业微信发送消息出现致命错误（非error）: %v\n", r)
-					if err != nil {
-						err = fmt.Errorf("%v", r)
-						return
-					}
-				}
-			}
-		}()
-
 		<-initchan
 
-		var headMsgBuilder strings.Builder
-		// 标准头部
-		messageutils.WriteMessageStdHeader(&headMsgBuilder, database.MsgTypeWebsite, mailID, now)
-
-		headMsgBuilder.WriteString(fmt.Sprintf("站点：%s\n", safeRefer))
-
-		headMsgBuilder.WriteString(fmt.Sprintf("Origin: %s\n", origin))
-		headMsgBuilder.WriteString(fmt.Sprintf("Host: %s\n", c.Request.Host))
-		headMsgBuilder.WriteString(fmt.Sprintf("IP地址：%s\n", clientIP))
-
-		headMsgBuilder.WriteString(fmt.Sprintf("名字：%s\n", safeName))
-		if !isSafeName {
-			headMsgBuilder.WriteString(fmt.Sprintf("注意：原名字可能包含不安全内容，已被删除（原名字长度：%d）\n", len(data.Name)))
-		}
-
-		if isAnonymous {
-			headMsgBuilder.WriteString(fmt.Sprintf("是否匿名：是\n"))
-		} else {
-			headMsgBuilder.WriteString(fmt.Sprintf("是否匿名：否\n"))
-		}
-
-		if userAddr != nil {
-			headMsgBuilder.WriteString(fmt.Sprintf("邮箱：%s\n", utils.FormatEmailAddressToHumanStringMustSafe(userAddr)))
-		} else {
-			headMsgBuilder.WriteString(fmt.Sprintf("邮箱：未预留\n"))
-		}
-
-		if !isSafeMsg {
-			headMsgBuilder.WriteString(fmt.Sprintf("注意：消息可能包含不安全内容，已被删除（消息原长度：%d）\n", len(data.Message)))
+		fields := []notifier.Field{
+			{Name: "站点", Value: safeRefer},
+			{Name: "Origin", Value: origin},
+			{Name: "Host", Value: host},
+			{Name: "IP地址", Value: clientIP},
+			{Name: "名字", Value: safeName},
 		}
 
-		headMsgBuilder.WriteString(fmt.Sprintf("消息长度：%d\n", len(safeMsg)))
-		headMsg := headMsgBuilder.String()
-
-		const start = "---消息开始---\n"
-		const stop = "\n---消息结束---"
-		const send_file = "以下消息以文件的形式发送"
-
-		var wxrobotID = ""
-
-		if len(headMsg)+len(start)+len(safeMsg)+len(stop) <= 2040 {
-			var msgBuilder strings.Builder
-
-			msgBuilder.WriteString(headMsg)
-			msgBuilder.WriteString(start)
-			msgBuilder.WriteString(safeMsg)
-			msgBuilder.WriteString(stop)
-
-			wxrobotID, err = sender.AMWechatRobot(msgBuilder.String())
-			if err != nil {
-				fmt.Printf("企业微信发送消息出现错误: %s\n", err.Error())
-			}
-		} else if len(headMsg)+len(send_file) <= 2040 {
-			var msgBuilder strings.Builder
-
-			msgBuilder.WriteString(headMsg)
-			msgBuilder.WriteString(send_file)
-
-			wxrobotID, _, err = sender.AMWechatRobotFile(msgBuilder.String(), safeMsg)
-			if err != nil {
-				fmt.Printf("企业微信发送消息出现错误: %s\n", err.Error())
-			}
-		} else {
-			wxrobotID, err = sender.AMWechatRobot(fmt.Sprintf("消息 [%s] 过长，无法在企业微信发送，请查看邮箱。", mailID))
-			if err != nil {
-				fmt.Printf("企业微信发送消息出现错误: %s\n", err.Error())
-			}
+		if !st.IsDefault() {
+			fields = append(fields, notifier.Field{Name: "所属站点", Value: st.Label()})
 		}
 
-		_ = database.UpdateAMWxRobotSendMsg(mailID, wxrobotID)
-	}()
-
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				if _err, ok := r.(error); ok {
-					fmt.Printf("电子邮件发送消息出现致命错误: %s\n", _err.Error())
-					if err != nil {
-						err = _err
-						return
-					}
-				} else {
-					fmt.Printf("电子邮件发送消息出现致命错误（非error）: %v\n", r)
-					if err != nil {
-						err = fmt.Errorf("%v", r)
-						return
-					}
-				}
-			}
-		}()
-
-		<-initchan
-
-		var msgBuilder strings.Builder
-		// 标准头部
-		messageutils.WriteMessageStdHeader(&msgBuilder, database.MsgTypeWebsite, mailID, now)
-
-		msgBuilder.WriteString(fmt.Sprintf("站点：%s\n", safeRefer))
-
-		msgBuilder.WriteString(fmt.Sprintf("Origin: %s\n", origin))
-		msgBuilder.WriteString(fmt.Sprintf("Host: %s\n", c.Request.Host))
-		msgBuilder.WriteString(fmt.Sprintf("Host: %s\n", c.Request.Host))
-		msgBuilder.WriteString(fmt.Sprintf("IP地址：%s\n", clientIP))
-
-		msgBuilder.WriteString(fmt.Sprintf("名字：%s\n", safeName))
 		if !isSafeName {
-			msgBuilder.WriteString(fmt.Sprintf("注意：原名字可能包含不安全内容，已被删除（原名字长度：%d）\n", len(data.Name)))
+			fields = append(fields, notifier.Field{Name: "注意", Value: fmt.Sprintf("原名字可能包含不安全内容，已被删除（原名字长度：%d）", len(data.Name))})
 		}
 
 		if isAnonymous {
-			msgBuilder.WriteString(fmt.Sprintf("是否匿名：是\n"))
+			fields = append(fields, notifier.Field{Name: "是否匿名", Value: "是"})
 		} else {
-			msgBuilder.WriteString(fmt.Sprintf("是否匿名：否\n"))
+			fields = append(fields, notifier.Field{Name: "是否匿名", Value: "否"})
 		}
 
 		if userAddr != nil {
-			msgBuilder.WriteString(fmt.Sprintf("邮箱：%s\n", utils.FormatEmailAddressToHumanStringJustNameMustSafe(userAddr)))
+			fields = append(fields, notifier.Field{Name: "邮箱", Value: utils.FormatEmailAddressToHumanStringMustSafe(userAddr)})
 		} else {
-			msgBuilder.WriteString(fmt.Sprintf("邮箱：未预留\n"))
+			fields = append(fields, notifier.Field{Name: "邮箱", Value: "未预留"})
 		}
 
 		if !isSafeMsg {
-			msgBuilder.WriteString(fmt.Sprintf("注意：消息可能包含不安全内容，已被删除（消息原长度：%d）\n", len(data.Message)))
+			fields = append(fields, notifier.Field{Name: "注意", Value: fmt.Sprintf("消息可能包含不安全内容，已被删除（消息原长度：%d）", len(data.Message))})
 		}
 
-		msgBuilder.WriteString(fmt.Sprintf("消息长度：%d\n", len(safeMsg)))
-		msgBuilder.WriteString(fmt.Sprintf("---消息开始---\n%s\n---消息结束---", safeMsg))
-
-		msg := msgBuilder.String()
-
-		smtpID, err := sender.AMEmail(msg, origin, safeRefer, now)
-		if err != nil {
-			fmt.Printf("电子邮件发送消息出现错误: %s\n", err.Error())
-		}
-
-		err = database.UpdateAMEmailSendMsg(mailID, smtpID)
+		notifier.SendAll(notifier.Notification{
+			SiteID:  st.ID,
+			Type:    database.MsgTypeWebsite,
+			MailID:  mailID,
+			Time:    now,
+			Subject: sender.AMSubject(origin, safeRefer),
+			Fields:  fields,
+			Content: safeMsg,
+			Meta: notifier.Meta{
+				Refer:       safeRefer,
+				Origin:      origin,
+				Host:        host,
+				IP:          clientIP,
+				Name:        safeName,
+				Email:       data.Email,
+				Anonymous:   isAnonymous,
+				NameSafe:    isSafeName,
+				ContentSafe: isSafeMsg,
+			},
+		})
 	}()
 
 	go func() {
@@ -509,17 +402,27 @@ func HandlerMessage(c *gin.Context) {
 		<-initchan
 
 		if userAddr != nil {
-			smtpID, _ := smtpserver.SendThankMsg("我们已经收到你的信件啦！", "", emailaddress.DefaultRecipientAddress, userAddr)
+			smtpID, _ := smtpserver.SendThankMsg(st.ID, "我们已经收到你的信件啦！", "", st.Address(), userAddr)
 			_ = database.UpdateAMThankEmailSendMsg(mailID, smtpID)
 		}
 	}()
 
+	var ticketToken string
+	if database.Ready() {
+		ticketToken, err = ticket.New(mailID)
+		if err != nil {
+			fmt.Printf("创建查询令牌出现错误: %s\n", err.Error())
+			ticketToken = ""
+		}
+	}
+
 	if isSafeMsg {
 		JSON(200, &ReturnData{
 			Code:       1,
 			Success:    true,
 			Message:    "留言成功！",
 			ErrMessage: "",
+			Ticket:     ticketToken,
 		})
 	} else {
 		JSON(200, &ReturnData{
@@ -527,6 +430,7 @@ func HandlerMessage(c *gin.Context) {
 			Success:    true,
 			Message:    "留言存在编码（例如非UTF-8编码或包含控制符合）或不安全问题，留言信息已被处理，留言成功！",
 			ErrMessage: "",
+			Ticket:     ticketToken,
 		})
 	}
 }
@@ -1,11 +1,10 @@
 package handler
 
 import (
-	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/site"
 	"github.com/SongZihuan/anonymous-message/src/utils"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"strings"
 )
 
 func HandlerOptions(c *gin.Context) {
@@ -19,20 +18,20 @@ func HandlerOptions(c *gin.Context) {
 	}
 }
 
+// HandlerTicketOptions 查询令牌接口的预检请求，查询页面可能不在站点内打开，允许任意 Origin
+func HandlerTicketOptions(c *gin.Context) {
+	ticketHeaderWriter(c)
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
 func handlerOptions(c *gin.Context) (string, bool) {
-	if flagparser.Origin != "" {
-		origin, ok := checkOrigin(c)
-		if ok {
-			allowHeaderWriter(c, origin)
-			return origin, true
-		} else {
-			c.Writer.Header().Del("Access-Control-Allow-Origin") // 确保没有此请求头
-			return origin, false
-		}
-	} else {
-		origin := utils.OriginClear(c.GetHeader("Origin"))
+	origin := utils.OriginClear(c.GetHeader("Origin"))
+	if site.AllowOrigin(origin) {
 		allowHeaderWriter(c, origin)
 		return origin, true
+	} else {
+		c.Writer.Header().Del("Access-Control-Allow-Origin") // 确保没有此请求头
+		return origin, false
 	}
 }
 
@@ -48,18 +47,10 @@ func allowHeaderWriter(c *gin.Context, origin string) {
 	c.Writer.Header().Set("Access-Control-Max-Age", "1728000") // 此处单位秒，20天
 }
 
-func checkOrigin(c *gin.Context) (string, bool) {
-	origin := utils.OriginClear(c.GetHeader("Origin"))
-	if origin == "" {
-		return "", false
-	}
-
-	for _, o := range strings.Split(flagparser.Origin, ",") {
-		if o == "*" {
-			return origin, true
-		} else if o = utils.OriginClear(o); o == origin { // origin肯定不会是""，因此此处不用判断o是否为""
-			return origin, true
-		}
-	}
-	return "", false
+// ticketHeaderWriter 允许任意 Origin 查询，只凭请求头中的令牌鉴权，不携带 Cookie 等凭据
+func ticketHeaderWriter(c *gin.Context) {
+	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+	c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	c.Writer.Header().Set("Access-Control-Allow-Headers", TicketHeader)
+	c.Writer.Header().Set("Access-Control-Max-Age", "1728000") // 此处单位秒，20天
 }
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/ticket"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// TicketHeader 查询令牌通过请求头传递，避免出现在URL和访问日志中
+const TicketHeader = "X-Ticket"
+
+type TicketReply struct {
+	Content string    `json:"content"`
+	Time    time.Time `json:"time"`
+}
+
+type TicketData struct {
+	Status     string        `json:"status"` // received、read 或 replied
+	StatusName string        `json:"status_name"`
+	Time       time.Time     `json:"time"`
+	Content    string        `json:"content"`
+	Replies    []TicketReply `json:"replies"`
+}
+
+type TicketReturnData struct {
+	Code       int         `json:"code"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	ErrMessage string      `json:"error,omitempty"`
+	Data       *TicketData `json:"data,omitempty"`
+}
+
+func HandlerTicket(c *gin.Context) {
+	ticketHeaderWriter(c) // 查询页面可能不在站点内打开，不要求 Origin
+
+	var JSON = func(code int, obj *TicketReturnData) {
+		if !flagparser.Debug {
+			obj.ErrMessage = ""
+		}
+		c.JSON(code, obj)
+	}
+
+	if !database.Ready() {
+		JSON(http.StatusNotFound, &TicketReturnData{
+			Code:    -1,
+			Success: false,
+			Message: "未启用留言查询。",
+		})
+		return
+	}
+
+	mailID, err := ticket.Find(c.GetHeader(TicketHeader))
+	if err != nil && errors.Is(err, ticket.ErrInvalidTicket) {
+		JSON(http.StatusNotFound, &TicketReturnData{
+			Code:    -1,
+			Success: false,
+			Message: "查询令牌无效。",
+		})
+		return
+	} else if err != nil {
+		fmt.Printf("查询令牌出现错误: %s\n", err.Error())
+		JSON(http.StatusInternalServerError, &TicketReturnData{
+			Code:       -1,
+			Success:    false,
+			Message:    "查询失败，请稍后再试。",
+			ErrMessage: err.Error(),
+		})
+		return
+	}
+
+	data, err := loadTicketData(mailID)
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		JSON(http.StatusNotFound, &TicketReturnData{
+			Code:    -1,
+			Success: false,
+			Message: "留言不存在。",
+		})
+		return
+	} else if err != nil {
+		fmt.Printf("查询留言出现错误: %s\n", err.Error())
+		JSON(http.StatusInternalServerError, &TicketReturnData{
+			Code:       -1,
+			Success:    false,
+			Message:    "查询失败，请稍后再试。",
+			ErrMessage: err.Error(),
+		})
+		return
+	}
+
+	JSON(http.StatusOK, &TicketReturnData{
+		Code:    1,
+		Success: true,
+		Message: "查询成功。",
+		Data:    data,
+	})
+}
+
+// loadTicketData 留言人可以看到的信息：处理状态、留言内容和已送达的回复，不包括处理人和备注等内部信息
+func loadTicketData(mailID string) (*TicketData, error) {
+	m, err := database.FindAMMail(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := database.FindMailReply(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &TicketData{
+		Status:     "received",
+		StatusName: "已收到",
+		Time:       m.Time,
+		Content:    m.Content,
+		Replies:    make([]TicketReply, 0, len(replies)),
+	}
+
+	for _, r := range replies {
+		if !r.Success {
+			continue // 发送失败的回复留言人看不到
+		}
+		res.Replies = append(res.Replies, TicketReply{
+			Content: r.Content,
+			Time:    r.Time,
+		})
+	}
+
+	state, err := database.FindMailState(mailID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+
+	if len(res.Replies) != 0 || (state != nil && state.Status == database.MailStatusReplied) {
+		res.Status = "replied"
+		res.StatusName = "已回复"
+	} else if state != nil && state.Status != database.MailStatusNew {
+		res.Status = "read" // 归档和垃圾信件也只显示为已读
+		res.StatusName = "已读"
+	}
+
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/apitoken"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+)
+
+// apiTokenMain 管理API令牌的创建、吊销与查看
+func apiTokenMain() (exitcode int) {
+	if !database.Ready() {
+		fmt.Printf("api token fail: sqlite is not enabled, please set --sqlite-path\n")
+		return 1
+	}
+
+	if flagparser.CreateAPIToken != "" {
+		token, err := apitoken.New(flagparser.CreateAPIToken)
+		if err != nil {
+			fmt.Printf("create api token fail: %s\n", err.Error())
+			return 1
+		}
+
+		fmt.Printf("API令牌 %s 创建成功，令牌只显示这一次，请妥善保存：\n%s\n", flagparser.CreateAPIToken, token)
+		return 0
+	}
+
+	if flagparser.RevokeAPIToken != "" {
+		err := database.DeleteAPIToken(flagparser.RevokeAPIToken)
+		if err != nil && errors.Is(err, database.ErrNotFound) {
+			fmt.Printf("revoke api token fail: token %s not found\n", flagparser.RevokeAPIToken)
+			return 1
+		} else if err != nil {
+			fmt.Printf("revoke api token fail: %s\n", err.Error())
+			return 1
+		}
+
+		fmt.Printf("API令牌 %s 已吊销\n", flagparser.RevokeAPIToken)
+		return 0
+	}
+
+	list, err := database.FindAllAPIToken()
+	if err != nil {
+		fmt.Printf("list api token fail: %s\n", err.Error())
+		return 1
+	}
+
+	for _, token := range list {
+		lastUsed := "从未使用"
+		if token.LastUsed.Valid {
+			lastUsed = token.LastUsed.Time.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-20s  创建于 %s  最后使用 %s\n", token.Name, token.Time.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05"), lastUsed)
+	}
+	fmt.Printf("共 %d 个API令牌\n", len(list))
+	return 0
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/tpl"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/relay"
+	"github.com/SongZihuan/anonymous-message/src/reqrate"
+	"github.com/SongZihuan/anonymous-message/src/site"
+)
+
+// reload 收到 SIGHUP 后重新加载配置，HTTP服务和IMAP连接保持运行，任一模块加载失败时恢复原配置
+func reload() {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("重新加载配置出现致命错误: %v\n", r)
+		}
+	}()
+
+	fmt.Printf("收到SIGHUP，重新加载配置\n")
+
+	changes, err := flagparser.Reload()
+	if err != nil {
+		fmt.Printf("重新加载配置失败，继续使用原配置: %s\n", err.Error())
+		return
+	}
+
+	err = applyReload()
+	if err != nil {
+		fmt.Printf("重新加载配置失败，继续使用原配置: %s\n", err.Error())
+		flagparser.RollbackReload(changes)
+		if err := applyReload(); err != nil {
+			fmt.Printf("恢复原配置失败: %s\n", err.Error())
+		}
+		return
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("配置没有变化\n")
+		return
+	}
+
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change.String())
+	}
+	fmt.Printf("重新加载配置完成\n")
+}
+
+func applyReload() error {
+	err := site.InitSite()
+	if err != nil {
+		return err
+	}
+
+	err = reqrate.InitRateLimit()
+	if err != nil {
+		return err
+	}
+
+	err = relay.InitRelay()
+	if err != nil {
+		return err
+	}
+
+	err = tpl.InitTemplate()
+	if err != nil {
+		return err
+	}
+
+	err = notifier.InitNotifier()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/reply"
+	"io"
+	"os"
+	"os/user"
+)
+
+// replyMain 在命令行中通过邮件回复信件
+func replyMain() (exitcode int) {
+	if !database.Ready() {
+		fmt.Printf("reply fail: sqlite is not enabled, please set --sqlite-path\n")
+		return 1
+	}
+
+	if flagparser.MailID == "" {
+		fmt.Printf("reply fail: --mail-id is required\n")
+		return 1
+	} else if flagparser.Reply != "" && flagparser.ReplyFile != "" {
+		fmt.Printf("reply fail: --reply and --reply-file can not be used together\n")
+		return 1
+	}
+
+	content := flagparser.Reply
+	if flagparser.ReplyFile == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("reply fail: read stdin: %s\n", err.Error())
+			return 1
+		}
+		content = string(data)
+	} else if flagparser.ReplyFile != "" {
+		data, err := os.ReadFile(flagparser.ReplyFile)
+		if err != nil {
+			fmt.Printf("reply fail: read %s: %s\n", flagparser.ReplyFile, err.Error())
+			return 1
+		}
+		content = string(data)
+	}
+
+	operator := "cli"
+	if u, err := user.Current(); err == nil {
+		operator = "cli:" + u.Username
+	}
+
+	replyID, err := reply.Send(flagparser.MailID, flagparser.ReplySubject, content, operator)
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		fmt.Printf("reply fail: mail %s not found\n", flagparser.MailID)
+		return 1
+	} else if err != nil && replyID != "" {
+		fmt.Printf("reply fail: send reply %s: %s\n", replyID, err.Error())
+		return 1
+	} else if err != nil {
+		fmt.Printf("reply fail: %s\n", err.Error())
+		return 1
+	}
+
+	fmt.Printf("已回复信件 %s（回复ID：%s）\n", flagparser.MailID, replyID)
+	return 0
+}
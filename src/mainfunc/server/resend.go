@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/resend"
+	"strings"
+	"time"
+)
+
+// resendMain 死信查看与手动重新投递
+func resendMain() (exitcode int) {
+	if !database.Ready() {
+		fmt.Printf("resend fail: sqlite is not enabled, please set --sqlite-path\n")
+		return 1
+	}
+
+	var names []string
+	for _, name := range strings.Split(flagparser.ResendNotifier, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	since := time.Now().Add(-flagparser.Since)
+
+	if flagparser.Resend != "" {
+		failed, err := resend.ResendMail(flagparser.Resend, names)
+		if err != nil {
+			fmt.Printf("resend fail: %s\n", err.Error())
+			return 1
+		} else if failed > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	if flagparser.ListFailed {
+		tasks, err := resend.FindFailed(since, names)
+		if err != nil {
+			fmt.Printf("list failed fail: %s\n", err.Error())
+			return 1
+		}
+
+		for _, task := range tasks {
+			fmt.Printf("%s  %s  %-12s  %s  %s\n", task.Time.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05"), task.MailID, task.Notifier, task.MailType, task.Reason)
+		}
+		fmt.Printf("共 %d 条投递失败的通知（%s 至今）\n", len(tasks), since.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05"))
+		return 0
+	}
+
+	total, failed, err := resend.ResendFailed(since, names)
+	if err != nil {
+		fmt.Printf("resend failed fail: %s\n", err.Error())
+		return 1
+	}
+
+	fmt.Printf("共重新投递 %d 条通知，成功 %d 条，失败 %d 条\n", total, total-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
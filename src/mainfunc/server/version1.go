@@ -6,8 +6,11 @@ import (
 	"github.com/SongZihuan/anonymous-message/src/emailserver"
 	"github.com/SongZihuan/anonymous-message/src/flagparser"
 	"github.com/SongZihuan/anonymous-message/src/httpserver"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/relay"
 	"github.com/SongZihuan/anonymous-message/src/reqrate"
 	"github.com/SongZihuan/anonymous-message/src/signalchan"
+	"github.com/SongZihuan/anonymous-message/src/site"
 	"time"
 )
 
@@ -66,6 +69,18 @@ func MainV1() (exitcode int) {
 		time.Sleep(1 * time.Second)
 	}()
 
+	err = site.InitSite()
+	if err != nil {
+		fmt.Printf("init site fail: %s\n", err.Error())
+		return 1
+	}
+
+	err = reqrate.InitRateLimit()
+	if err != nil {
+		fmt.Printf("init rate limit fail: %s\n", err.Error())
+		return 1
+	}
+
 	err = reqrate.RateClean()
 	if err != nil {
 		fmt.Printf("init rate clean fail: %s\n", err.Error())
@@ -79,15 +94,50 @@ func MainV1() (exitcode int) {
 	}
 	defer database.CloseSQLite()
 
+	err = relay.InitRelay()
+	if err != nil {
+		fmt.Printf("init reply alias fail: %s\n", err.Error())
+		return 1
+	}
+
 	err = emailserver.InitEmailSystem()
 	if err != nil {
 		fmt.Printf("init email system fail: %s\n", err.Error())
 		return 1
 	}
 
+	err = notifier.InitNotifier()
+	if err != nil {
+		fmt.Printf("init notifier fail: %s\n", err.Error())
+		return 1
+	}
+
+	if flagparser.Resend != "" || flagparser.ResendFailed || flagparser.ListFailed {
+		return resendMain()
+	}
+
+	if flagparser.CreateAPIToken != "" || flagparser.RevokeAPIToken != "" || flagparser.ListAPIToken {
+		return apiTokenMain()
+	}
+
+	if flagparser.Reply != "" || flagparser.ReplyFile != "" {
+		return replyMain()
+	}
+
+	if flagparser.MailID != "" || flagparser.SetStatus != "" || flagparser.Assign != "" || flagparser.ListUnhandled {
+		return workflowMain()
+	}
+
+	err = notifier.StartOutbox()
+	if err != nil {
+		fmt.Printf("init outbox fail: %s\n", err.Error())
+		return 1
+	}
+	defer notifier.StopOutbox()
+
 	imapchan, err := emailserver.StartEmailServer()
 	if err != nil {
-		fmt.Printf("init email server (imap) fail: %s\n", err.Error())
+		fmt.Printf("init email server fail: %s\n", err.Error())
 		return 1
 	}
 	defer func() {
@@ -110,16 +160,20 @@ func MainV1() (exitcode int) {
 	}
 	defer signalchan.CloseSignal()
 
-	select {
-	case <-signalchan.SignalChan:
-		fmt.Printf("Server safe closed by signal.\n")
-		return 0
-	case <-httpchan:
-		fmt.Printf("Server safe closed http server.\n")
-		return 0
-	case <-imapchan:
-		fmt.Printf("Server safe closed email server.\n")
-		return 0
+	for {
+		select {
+		case <-signalchan.ReloadChan:
+			reload()
+		case <-signalchan.SignalChan:
+			fmt.Printf("Server safe closed by signal.\n")
+			return 0
+		case <-httpchan:
+			fmt.Printf("Server safe closed http server.\n")
+			return 0
+		case <-imapchan:
+			fmt.Printf("Server safe closed email server.\n")
+			return 0
+		}
 	}
 	// 后续不可达
 }
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/workflow"
+	"os/user"
+)
+
+// workflowMain 在命令行中查看和修改信件的处理状态
+func workflowMain() (exitcode int) {
+	if !database.Ready() {
+		fmt.Printf("workflow fail: sqlite is not enabled, please set --sqlite-path\n")
+		return 1
+	}
+
+	if flagparser.ListUnhandled {
+		list, _, err := database.FindMessages(database.MessageFilter{
+			Status: database.UnhandledStatus,
+		})
+		if err != nil {
+			fmt.Printf("list unhandled fail: %s\n", err.Error())
+			return 1
+		}
+
+		count := 0
+		for _, m := range list {
+			if m.MailType == database.MsgTypeSystem {
+				continue // 系统留言不需要处理
+			}
+			count++
+
+			title := m.Subject
+			if title == "" {
+				title = m.Name
+			}
+			fmt.Printf("%s  %s  %-6s  %-10s  %s  %s\n", m.Time.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05"), m.MailID, workflow.StatusName(m.Status), m.Assignee, m.MailType, title)
+		}
+
+		fmt.Printf("共 %d 封未处理的信件\n", count)
+		return 0
+	}
+
+	if flagparser.MailID == "" {
+		fmt.Printf("workflow fail: --mail-id is required\n")
+		return 1
+	}
+
+	operator := "cli"
+	if u, err := user.Current(); err == nil {
+		operator = "cli:" + u.Username
+	}
+
+	if flagparser.SetStatus != "" {
+		status, err := workflow.ParseStatus(flagparser.SetStatus)
+		if err != nil {
+			fmt.Printf("set status fail: %s\n", err.Error())
+			return 1
+		}
+
+		changed, err := workflow.SetStatus(flagparser.MailID, status, operator, flagparser.Note)
+		if err != nil && errors.Is(err, database.ErrNotFound) {
+			fmt.Printf("set status fail: mail %s not found\n", flagparser.MailID)
+			return 1
+		} else if err != nil {
+			fmt.Printf("set status fail: %s\n", err.Error())
+			return 1
+		} else if changed {
+			fmt.Printf("信件 %s 的状态已修改为：%s\n", flagparser.MailID, workflow.StatusName(status))
+		} else {
+			fmt.Printf("信件 %s 的状态已经是：%s\n", flagparser.MailID, workflow.StatusName(status))
+		}
+	}
+
+	if flagparser.Assign != "" {
+		assignee := flagparser.Assign
+		if assignee == "-" {
+			assignee = ""
+		}
+
+		changed, err := workflow.Assign(flagparser.MailID, assignee, operator, flagparser.Note)
+		if err != nil && errors.Is(err, database.ErrNotFound) {
+			fmt.Printf("assign fail: mail %s not found\n", flagparser.MailID)
+			return 1
+		} else if err != nil {
+			fmt.Printf("assign fail: %s\n", err.Error())
+			return 1
+		} else if !changed {
+			fmt.Printf("信件 %s 的处理人没有变化\n", flagparser.MailID)
+		} else if assignee == "" {
+			fmt.Printf("信件 %s 已取消指派\n", flagparser.MailID)
+		} else {
+			fmt.Printf("信件 %s 已指派给：%s\n", flagparser.MailID, assignee)
+		}
+	}
+
+	if flagparser.SetStatus != "" || flagparser.Assign != "" {
+		return 0
+	}
+
+	record, err := database.FindMailRecord(flagparser.MailID)
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		fmt.Printf("show mail fail: mail %s not found\n", flagparser.MailID)
+		return 1
+	} else if err != nil {
+		fmt.Printf("show mail fail: %s\n", err.Error())
+		return 1
+	}
+
+	fmt.Printf("信件：%s（%s）\n", record.MailID, record.MailType)
+
+	state, err := database.FindMailState(flagparser.MailID)
+	if err == nil {
+		fmt.Printf("状态：%s\n", workflow.StatusName(state.Status))
+		fmt.Printf("处理人：%s\n", state.Assignee)
+	} else if errors.Is(err, database.ErrNotFound) {
+		fmt.Printf("状态：%s\n", workflow.StatusName(database.MailStatusNew))
+	} else {
+		fmt.Printf("show mail fail: %s\n", err.Error())
+		return 1
+	}
+
+	audit, err := database.FindMailAudit(flagparser.MailID)
+	if err != nil {
+		fmt.Printf("show mail fail: %s\n", err.Error())
+		return 1
+	}
+
+	for _, a := range audit {
+		from, to := a.From, a.To
+		if a.Action == database.MailAuditStatus {
+			from, to = workflow.StatusName(database.MailStatus(from)), workflow.StatusName(database.MailStatus(to))
+		}
+		fmt.Printf("%s  %-6s  %s -> %s  %s  %s\n", a.Time.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05"), a.Action, from, to, a.Operator, a.Note)
+	}
+
+	return 0
+}
@@ -0,0 +1,270 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/messageutils"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const DefaultSendTimeout = 5 * time.Minute
+
+const (
+	MessageStart = "---消息开始---\n"
+	MessageStop  = "\n---消息结束---"
+	SendFileTip  = "以下消息以文件的形式发送"
+	SegmentTip   = "以下消息分段发送"
+)
+
+// MaxSegments 分段发送时正文最多的段数，防止超长消息刷屏
+const MaxSegments = 5
+
+// ErrNotConfigured 由 Factory 返回，表示该渠道未配置，启动时跳过而不是报错
+var ErrNotConfigured = errors.New("notifier not configured")
+
+// Capability 描述通知渠道的能力
+type Capability struct {
+	MaxLength     int  // 单条消息的最大长度，0 表示不限制
+	LengthInRunes bool // MaxLength 以字符而不是字节计算
+	SupportFile   bool // 超长时是否支持以文件形式发送正文
+}
+
+func (c Capability) length(s string) int {
+	if c.LengthInRunes {
+		return utf8.RuneCountInString(s)
+	}
+	return len(s)
+}
+
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Meta 消息的原始信息，供需要结构化数据的渠道（例如 JSON Webhook）使用，字段按消息类型选填
+type Meta struct {
+	Refer       string    `json:"refer,omitempty"`
+	Origin      string    `json:"origin,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Anonymous   bool      `json:"anonymous,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	MessageID   string    `json:"message_id,omitempty"`
+	Sender      string    `json:"sender,omitempty"`
+	From        string    `json:"from,omitempty"`
+	ReplyTo     string    `json:"reply_to,omitempty"`
+	To          string    `json:"to,omitempty"`
+	SendTime    time.Time `json:"send_time,omitempty"`
+	SPF         string    `json:"spf,omitempty"`
+	DKIM        string    `json:"dkim,omitempty"`
+	DMARC       string    `json:"dmarc,omitempty"`
+	NameSafe    bool      `json:"name_safe"`
+	ContentSafe bool      `json:"content_safe"`
+}
+
+// Attachment 通知附带的文件，支持的渠道（邮件、企业微信）会一并发送；Path 为空表示附件太大没有保存，只在通知中列出
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Path        string `json:"path,omitempty"`
+}
+
+// Notification 一条待投递的通知，由各个渠道自行决定如何呈现
+type Notification struct {
+	Type    database.MsgType `json:"type"`
+	MailID  string           `json:"mail_id"`
+	SiteID  string           `json:"site_id"` // 信件所属的站点，决定使用哪些通知渠道
+	Time    time.Time        `json:"time"`
+	Subject string           `json:"subject"` // 邮件等需要标题的渠道使用
+	Fields  []Field          `json:"fields"`  // 标准头部之后的附加信息，例如站点、IP地址
+	Content string           `json:"content"`
+	Meta    Meta             `json:"meta"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	Done []string `json:"done,omitempty"` // 已经投递成功的部分（例如某个 chat、某个 URL 或某一段），投递队列重试时跳过
+}
+
+// IsDone 该部分是否已经在之前的投递中成功，渠道只能看到属于自己的部分
+func (n Notification) IsDone(part string) bool {
+	for _, d := range n.Done {
+		if d == part {
+			return true
+		}
+	}
+	return false
+}
+
+// donePart 保存时在部分前加上渠道名称，避免不同渠道使用相同的名称（例如钉钉和飞书的 segment:0）
+func donePart(name string, part string) string {
+	return name + ":" + part
+}
+
+// forNotifier 只保留属于该渠道的已完成部分，并去掉渠道名称
+func (n Notification) forNotifier(name string) Notification {
+	prefix := donePart(name, "")
+
+	done := make([]string, 0, len(n.Done))
+	for _, d := range n.Done {
+		if strings.HasPrefix(d, prefix) {
+			done = append(done, strings.TrimPrefix(d, prefix))
+		}
+	}
+
+	n.Done = done
+	return n
+}
+
+// PartialError 只有部分投递成功，Done 为本次投递成功的部分，投递队列重试时只投递剩下的部分
+type PartialError struct {
+	Done []string
+	Err  error
+}
+
+func (e *PartialError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Partial 根据成功的部分和失败的错误生成返回值：全部成功返回 nil，没有任何部分成功时直接返回错误
+func Partial(done []string, err error) error {
+	if err == nil {
+		return nil
+	} else if len(done) == 0 {
+		return err
+	}
+	return &PartialError{Done: done, Err: err}
+}
+
+type Notifier interface {
+	Name() string
+	Capability() Capability
+	Send(ctx context.Context, n Notification) error
+}
+
+// Header 标准头部、附加信息与消息长度
+func (n Notification) Header() string {
+	var headMsgBuilder strings.Builder
+
+	// 标准头部
+	if n.Type == database.MsgTypeSystem {
+		messageutils.WriteSNMessageStdHeader(&headMsgBuilder, n.Type, n.MailID, n.Time)
+	} else {
+		messageutils.WriteMessageStdHeader(&headMsgBuilder, n.Type, n.MailID, n.Time)
+	}
+
+	for _, f := range n.Fields {
+		headMsgBuilder.WriteString(fmt.Sprintf("%s：%s\n", f.Name, f.Value))
+	}
+
+	headMsgBuilder.WriteString(fmt.Sprintf("消息长度：%d\n", len(n.Content)))
+	return headMsgBuilder.String()
+}
+
+// StdFields 标准头部中的信息，供需要结构化展示的渠道使用
+func (n Notification) StdFields() []Field {
+	return []Field{
+		{Name: "类型", Value: string(n.Type)},
+		{Name: "信件ID", Value: n.MailID},
+		{Name: "接收时间", Value: fmt.Sprintf("%s %s", n.Time.Format("2006-01-02 15:04:05"), n.Time.Location().String())},
+	}
+}
+
+// Text 完整的纯文本消息
+func (n Notification) Text() string {
+	var msgBuilder strings.Builder
+
+	msgBuilder.WriteString(n.Header())
+	msgBuilder.WriteString(MessageStart)
+	msgBuilder.WriteString(n.Content)
+	msgBuilder.WriteString(MessageStop)
+
+	return msgBuilder.String()
+}
+
+// Fit 根据渠道能力生成消息文本。
+// 若完整消息超出 MaxLength 且渠道支持文件，则返回头部和需要以文件发送的正文（file 不为空）；
+// 若连头部都放不下，则返回一条简短的提示。
+func (n Notification) Fit(c Capability) (text string, file string) {
+	text = n.Text()
+	if c.MaxLength <= 0 || c.length(text) <= c.MaxLength {
+		return text, ""
+	}
+
+	headMsg := n.Header()
+	if c.SupportFile && c.length(headMsg)+c.length(SendFileTip) <= c.MaxLength {
+		return headMsg + SendFileTip, n.Content
+	}
+
+	return fmt.Sprintf("消息 [%s] 过长，无法在此发送，请查看邮箱。", n.MailID), ""
+}
+
+// Segments 将超长消息拆分为多条发送，供不支持文件的渠道使用。
+// 第一条为头部，随后为至多 MaxSegments 段正文，超出部分提示查看邮箱。
+func (n Notification) Segments(c Capability) []string {
+	text := n.Text()
+	if c.MaxLength <= 0 || c.length(text) <= c.MaxLength {
+		return []string{text}
+	}
+
+	res := make([]string, 0, MaxSegments+2)
+
+	headMsg := n.Header()
+	if c.length(headMsg)+c.length(SegmentTip) <= c.MaxLength {
+		res = append(res, headMsg+SegmentTip)
+	} else {
+		res = append(res, fmt.Sprintf("消息 [%s] 头部过长，仅发送正文。", n.MailID))
+	}
+
+	// 预留分段前缀的长度
+	budget := c.MaxLength - c.length(segmentPrefix(n.MailID, MaxSegments, MaxSegments))
+	if budget <= 0 {
+		return []string{fmt.Sprintf("消息 [%s] 过长，无法在此发送，请查看邮箱。", n.MailID)}
+	}
+
+	chunks := make([]string, 0, MaxSegments)
+	rest := n.Content
+	for rest != "" && len(chunks) < MaxSegments {
+		var chunk strings.Builder
+		size := 0
+		for _, r := range rest {
+			l := c.length(string(r))
+			if size+l > budget {
+				break
+			}
+			chunk.WriteRune(r)
+			size += l
+		}
+
+		chunks = append(chunks, chunk.String())
+		rest = rest[chunk.Len():]
+	}
+
+	for i, chunk := range chunks {
+		res = append(res, segmentPrefix(n.MailID, i+1, len(chunks))+chunk)
+	}
+
+	if rest != "" {
+		res = append(res, fmt.Sprintf("消息 [%s] 过长，剩余 %d 字节未发送，请查看邮箱。", n.MailID, len(rest)))
+	}
+
+	return res
+}
+
+func segmentPrefix(mailID string, index int, total int) string {
+	return fmt.Sprintf("信件ID: %s（第 %d/%d 段）\n", mailID, index, total)
+}
@@ -0,0 +1,214 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package notifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"math/rand"
+	"time"
+)
+
+const DefaultOutboxCycleTime = 15 * time.Second
+const DefaultOutboxBatch = 50
+const DefaultOutboxMaxBackoff = 6 * time.Hour
+
+// 领取投递记录后的有效期，超过后视为投递协程已经退出（例如进程崩溃），其他协程可以重新领取
+const outboxLease = DefaultSendTimeout + time.Minute
+
+var outboxStopChannel chan bool
+
+// StartOutbox 启动后台投递协程，定期重试失败的投递（包括重启前未完成的投递）
+func StartOutbox() error {
+	if !database.Ready() {
+		return nil
+	} else if outboxStopChannel != nil {
+		return fmt.Errorf("outbox is running")
+	}
+
+	outboxStopChannel = make(chan bool)
+
+	go func(stopchan chan bool) {
+		ticker := time.NewTicker(DefaultOutboxCycleTime)
+		defer ticker.Stop()
+
+		for {
+			dispatchOutbox()
+
+			select {
+			case <-ticker.C:
+			case <-stopchan:
+				return
+			}
+		}
+	}(outboxStopChannel)
+
+	return nil
+}
+
+func StopOutbox() {
+	if outboxStopChannel == nil {
+		return
+	}
+
+	close(outboxStopChannel)
+	outboxStopChannel = nil
+}
+
+func dispatchOutbox() {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("投递队列出现致命错误: %v\n", r)
+		}
+	}()
+
+	now := time.Now()
+
+	records, err := database.FindDueOutbox(now, DefaultOutboxBatch)
+	if err != nil {
+		fmt.Printf("投递队列读取数据库出现错误: %s\n", err.Error())
+		return
+	}
+
+	for _, record := range records {
+		ok, err := database.ClaimOutbox(record.DeliveryID, now, now.Add(outboxLease))
+		if err != nil {
+			fmt.Printf("投递队列领取 %s 出现错误: %s\n", record.DeliveryID, err.Error())
+			continue
+		} else if !ok {
+			continue
+		}
+
+		var n Notification
+		err = json.Unmarshal([]byte(record.Payload), &n)
+		if err != nil {
+			_ = database.UpdateOutboxRecord(record.DeliveryID, fmt.Errorf("bad payload: %s", err.Error()), time.Time{}, true)
+			continue
+		}
+
+		nt := lookup(n.SiteID, record.Notifier)
+		if nt == nil {
+			_ = database.UpdateOutboxRecord(record.DeliveryID, fmt.Errorf("notifier %s is not enabled", record.Notifier), time.Time{}, true)
+			continue
+		}
+
+		go attempt(nt, record.DeliveryID, n, record.Attempts+1)
+	}
+}
+
+// Redeliver 同步地将通知重新投递到指定的渠道，投递失败时同样进入投递队列重试
+// 重新投递会发送全部内容，忽略之前投递中已经完成的部分
+func Redeliver(n Notification, name string) error {
+	nt := lookup(n.SiteID, name)
+	if nt == nil {
+		return fmt.Errorf("notifier %s is not enabled", name)
+	}
+
+	n.Done = nil
+	return deliver(nt, n)
+}
+
+// deliver 先持久化再投递，未启用数据库时直接投递
+func deliver(nt Notifier, n Notification) error {
+	if !database.Ready() {
+		return attempt(nt, "", n, 1)
+	}
+
+	now := time.Now()
+	deliveryID := getDeliveryID(n.MailID, nt.Name(), now)
+
+	payload, err := json.Marshal(n)
+	if err == nil {
+		err = database.SaveOutbox(deliveryID, n.MailID, nt.Name(), string(payload), now.Add(outboxLease), now)
+	}
+
+	if err != nil {
+		fmt.Printf("通知渠道 %s 保存投递记录出现错误（本次投递失败后将不会重试）: %s\n", nt.Name(), err.Error())
+		deliveryID = ""
+	}
+
+	return attempt(nt, deliveryID, n, 1)
+}
+
+func attempt(nt Notifier, deliveryID string, n Notification, attempts int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _err, ok := r.(error); ok {
+				fmt.Printf("通知渠道 %s 发送消息出现致命错误: %s\n", nt.Name(), _err.Error())
+				err = _err
+			} else {
+				fmt.Printf("通知渠道 %s 发送消息出现致命错误（非error）: %v\n", nt.Name(), r)
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultSendTimeout)
+	defer cancel()
+
+	err = nt.Send(ctx, n.forNotifier(nt.Name()))
+	if err != nil {
+		fmt.Printf("通知渠道 %s 发送消息出现错误（第 %d 次）: %s\n", nt.Name(), attempts, err.Error())
+	}
+
+	if deliveryID == "" {
+		return err
+	}
+
+	var partial *PartialError
+	if err != nil && errors.As(err, &partial) {
+		// 记录已经成功的部分，重试时不会重复发送
+		for _, part := range partial.Done {
+			n.Done = append(n.Done, donePart(nt.Name(), part))
+		}
+		payload, _err := json.Marshal(n)
+		if _err == nil {
+			_err = database.UpdateOutboxPayload(deliveryID, string(payload))
+		}
+		if _err != nil {
+			fmt.Printf("通知渠道 %s 保存已投递的部分出现错误: %s\n", nt.Name(), _err.Error())
+		}
+	}
+
+	dead := err != nil && attempts >= flagparser.OutboxMaxAttempts
+	if dead {
+		fmt.Printf("通知渠道 %s 投递 %s 失败次数过多，已转入死信\n", nt.Name(), deliveryID)
+	}
+
+	_ = database.UpdateOutboxRecord(deliveryID, err, time.Now().Add(backoff(attempts)), dead)
+	return err
+}
+
+// backoff 指数退避，并在 [0.5, 1.5) 倍之间随机抖动，避免大量记录同时重试
+func backoff(attempts int) time.Duration {
+	base := flagparser.OutboxBackoff
+	if base <= 0 {
+		base = time.Second
+	}
+
+	d := base
+	for i := 1; i < attempts && d < DefaultOutboxMaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > DefaultOutboxMaxBackoff {
+		d = DefaultOutboxMaxBackoff
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+func getDeliveryID(mailID string, notifier string, t time.Time) string {
+	text := fmt.Sprintf("OUTBOX-%s\n%s\n%d", mailID, notifier, t.UnixNano())
+	hasher := sha256.New()
+	hasher.Write([]byte(text))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
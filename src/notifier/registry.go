@@ -0,0 +1,155 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Factory 根据站点的参数创建通知渠道，未配置时返回 ErrNotConfigured
+type Factory func(opt *flagparser.SiteOptions) (Notifier, error)
+
+var factoriesLock sync.Mutex
+var factories = make(map[string]Factory, 10)
+
+// notifiers 每个站点启用的通知渠道，键为站点ID
+var notifiers atomic.Pointer[map[string][]Notifier]
+
+// Register 注册通知渠道，通常在实现渠道的包的 init 中调用
+func Register(name string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("notifier %s registered twice", name))
+	}
+
+	factories[name] = factory
+}
+
+// InitNotifier 为默认站点和每个站点创建启用的通知渠道，全部创建成功后才会替换正在使用的渠道
+func InitNotifier() error {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	options, err := flagparser.AllSiteOptions()
+	if err != nil {
+		return err
+	}
+
+	res := make(map[string][]Notifier, len(options))
+
+	for _, opt := range options {
+		list, err := newNotifiers(opt)
+		if err != nil {
+			return err
+		}
+		res[opt.ID] = list
+	}
+
+	notifiers.Store(&res)
+	return nil
+}
+
+func newNotifiers(opt *flagparser.SiteOptions) ([]Notifier, error) {
+	res := make([]Notifier, 0, len(factories))
+	exists := make(map[string]bool, len(factories))
+
+	for _, name := range strings.Split(opt.Notifier, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || exists[name] {
+			continue
+		}
+		exists[name] = true
+
+		factory, ok := factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown notifier: %s", name)
+		}
+
+		n, err := factory(opt)
+		if errors.Is(err, ErrNotConfigured) {
+			if opt.IsDefault() {
+				fmt.Printf("通知渠道 %s 未配置，已跳过\n", name)
+			} else {
+				fmt.Printf("站点 %s 的通知渠道 %s 未配置，已跳过\n", opt.ID, name)
+			}
+			continue
+		} else if err != nil {
+			if opt.IsDefault() {
+				return nil, fmt.Errorf("init notifier %s failed: %s", name, err.Error())
+			}
+			return nil, fmt.Errorf("init notifier %s of site %s failed: %s", name, opt.ID, err.Error())
+		}
+
+		res = append(res, n)
+	}
+
+	return res, nil
+}
+
+// Notifiers 默认站点启用的通知渠道
+func Notifiers() []Notifier {
+	return SiteNotifiers(flagparser.DefaultSiteID)
+}
+
+// SiteNotifiers 站点启用的通知渠道，站点不存在时返回默认站点的通知渠道
+func SiteNotifiers(siteID string) []Notifier {
+	res := notifiers.Load()
+	if res == nil {
+		return nil
+	}
+
+	list, ok := (*res)[siteID]
+	if !ok {
+		return (*res)[flagparser.DefaultSiteID]
+	}
+	return list
+}
+
+// AllNotifiers 所有站点启用的通知渠道名称
+func AllNotifiers() []string {
+	res := notifiers.Load()
+	if res == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(factories))
+	exists := make(map[string]bool, len(factories))
+
+	for _, list := range *res {
+		for _, n := range list {
+			if !exists[n.Name()] {
+				exists[n.Name()] = true
+				names = append(names, n.Name())
+			}
+		}
+	}
+
+	return names
+}
+
+func lookup(siteID string, name string) Notifier {
+	for _, n := range SiteNotifiers(siteID) {
+		if n.Name() == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// SendAll 异步地将通知投递到站点启用的所有渠道，失败的投递由投递队列重试
+func SendAll(n Notification) {
+	for _, nt := range SiteNotifiers(n.SiteID) {
+		go func(nt Notifier) {
+			_ = deliver(nt, n)
+		}(nt)
+	}
+}
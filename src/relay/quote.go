@@ -0,0 +1,54 @@
+package relay
+
+import (
+	"strings"
+)
+
+// StripQuote 去掉回复邮件中引用的原邮件，只保留新写的内容，避免把通知内容和管理员的地址转发给留言人
+func StripQuote(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+
+	end := -1
+	for i, line := range lines {
+		if isQuoteStart(line) {
+			end = i
+			break
+		}
+	}
+
+	if end < 0 {
+		return strings.TrimSpace(strings.Join(lines, "\n"))
+	}
+
+	// 引用前的说明行，例如 “On ..., xxx <xxx@example.com> wrote:” 或 “在 ...，xxx 写道：”
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	if end > 0 {
+		last := strings.TrimSpace(lines[end-1])
+		if strings.HasSuffix(last, ":") || strings.HasSuffix(last, "：") {
+			end--
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines[:end], "\n"))
+}
+
+func isQuoteStart(line string) bool {
+	line = strings.TrimSpace(line)
+
+	switch {
+	case strings.HasPrefix(line, ">"):
+		return true
+	case strings.HasPrefix(strings.ToLower(line), "-----original message-----"):
+		return true
+	case strings.HasPrefix(line, "________________"): // Outlook
+		return true
+	case strings.Contains(line, "原始邮件") && (strings.HasPrefix(line, "-") || strings.HasPrefix(line, "—")):
+		return true
+	case strings.HasPrefix(line, "发件人:") || strings.HasPrefix(line, "发件人："):
+		return true
+	}
+
+	return false
+}
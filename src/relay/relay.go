@@ -0,0 +1,146 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package relay
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/site"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"net/mail"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const tokenPlaceholder = "{token}"
+const tokenLength = 24
+
+var ErrDisabled = fmt.Errorf("reply alias is not enabled")
+
+// aliasTemplate 解析后的 --reply-alias，别名为 prefix + token + suffix
+type aliasTemplate struct {
+	prefix string
+	suffix string
+}
+
+var template atomic.Pointer[aliasTemplate]
+
+// InitRelay 检查 --reply-alias，为空时不启用回复别名
+func InitRelay() error {
+	alias := strings.ToLower(strings.TrimSpace(flagparser.ReplyAlias))
+	if alias == "" {
+		template.Store(nil)
+		return nil
+	}
+
+	if !database.Ready() {
+		return fmt.Errorf("reply alias requires sqlite")
+	} else if strings.Count(alias, tokenPlaceholder) != 1 {
+		return fmt.Errorf("reply alias must contain %s once", tokenPlaceholder)
+	}
+
+	prefix, suffix, _ := strings.Cut(alias, tokenPlaceholder)
+	if strings.Contains(prefix, "@") || !strings.Contains(suffix, "@") {
+		return fmt.Errorf("%s must be in the local part of reply alias", tokenPlaceholder)
+	}
+
+	if !utils.IsValidEmail(prefix + strings.Repeat("0", tokenLength) + suffix) {
+		return fmt.Errorf("reply alias %s is not a valid email address", flagparser.ReplyAlias)
+	}
+
+	template.Store(&aliasTemplate{
+		prefix: prefix,
+		suffix: suffix,
+	})
+	return nil
+}
+
+func Enabled() bool {
+	return template.Load() != nil
+}
+
+// Alias 信件的回复别名，第一次调用时生成
+func Alias(siteID string, mailID string) (*mail.Address, error) {
+	t := template.Load()
+	if t == nil {
+		return nil, ErrDisabled
+	}
+
+	alias, err := database.FindReplyAliasByMailID(mailID)
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+
+		err = database.SaveReplyAlias(token, mailID, siteID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		alias = &database.ReplyAlias{Token: token, MailID: mailID, SiteID: siteID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &mail.Address{
+		Name:    site.Get(alias.SiteID).Name,
+		Address: t.prefix + alias.Token + t.suffix,
+	}, nil
+}
+
+// Match 地址为回复别名时返回别名对应的信件
+func Match(address string) (*database.ReplyAlias, bool) {
+	token, ok := parseToken(address)
+	if !ok {
+		return nil, false
+	}
+
+	alias, err := database.FindReplyAliasByToken(token)
+	if err != nil {
+		return nil, false
+	}
+
+	return alias, true
+}
+
+// IsAlias 地址是否符合回复别名的格式，不检查别名是否存在
+func IsAlias(address string) bool {
+	_, ok := parseToken(address)
+	return ok
+}
+
+func parseToken(address string) (string, bool) {
+	t := template.Load()
+	if t == nil {
+		return "", false
+	}
+
+	address = strings.ToLower(strings.TrimSpace(address))
+	if len(address) != len(t.prefix)+tokenLength+len(t.suffix) || !strings.HasPrefix(address, t.prefix) || !strings.HasSuffix(address, t.suffix) {
+		return "", false
+	}
+
+	token := address[len(t.prefix) : len(t.prefix)+tokenLength]
+	if _, err := hex.DecodeString(token); err != nil {
+		return "", false
+	}
+
+	return token, true
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, tokenLength/2)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
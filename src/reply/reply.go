@@ -0,0 +1,224 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package reply
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/smtpserver"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/relay"
+	"github.com/SongZihuan/anonymous-message/src/site"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"github.com/SongZihuan/anonymous-message/src/workflow"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const MaxContentLength = 8192
+
+var (
+	ErrEmptyContent   = fmt.Errorf("reply content is empty")
+	ErrContentTooLong = fmt.Errorf("reply content is too long")
+	ErrNotReplyable   = fmt.Errorf("system message can not be replied")
+	ErrNoAddress      = fmt.Errorf("message has no valid email address")
+	ErrRateLimit      = smtpserver.ErrRateLimit
+)
+
+// original 被回复的信件
+type original struct {
+	siteID    string
+	messageID string // 原邮件的 Message-ID，网页留言为空
+	subject   string
+	content   string
+	time      time.Time
+	addr      *mail.Address // 为空表示只能通过查询令牌查看回复
+}
+
+// Send 通过邮件回复信件，subject 为空时使用默认主题；发送成功后信件状态改为已回复
+// 没有留下邮箱但有查询令牌的网页留言，回复不发送邮件，只显示在查询页面
+// 发送前先保存回复记录，发送失败时也会返回回复ID，可以通过 database.FindMailReply 查看发送结果
+func Send(mailID string, subject string, content string, operator string) (string, error) {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	if content == "" {
+		return "", ErrEmptyContent
+	} else if len(content) > MaxContentLength {
+		return "", ErrContentTooLong
+	}
+
+	orig, err := findOriginal(mailID)
+	if err != nil {
+		return "", err
+	}
+
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		subject = orig.subject
+	}
+
+	replies, err := database.FindMailReply(mailID)
+	if err != nil {
+		return "", err
+	}
+
+	thread := smtpserver.Thread{}
+	if orig.messageID != "" {
+		thread.References = append(thread.References, orig.messageID)
+	}
+	for _, r := range replies {
+		if r.Success {
+			thread.References = append(thread.References, r.MessageID)
+		}
+	}
+	if len(thread.References) != 0 {
+		thread.InReplyTo = thread.References[len(thread.References)-1]
+	}
+
+	to := ""
+	if orig.addr != nil {
+		to = orig.addr.String()
+	}
+
+	now := time.Now()
+	replyID := utils.GetReplyID(mailID, to, subject, content, now)
+	thread.MessageID = smtpserver.NewMessageID(replyID)
+
+	err = database.SaveMailReply(replyID, mailID, orig.siteID, thread.MessageID, thread.InReplyTo, thread.References, to, subject, content, operator, now)
+	if err != nil {
+		return "", err
+	}
+
+	if orig.addr == nil {
+		// 留言人没有留下邮箱，回复只显示在查询页面
+		err = database.UpdateMailReply(replyID, "", nil)
+		if err != nil {
+			return replyID, err
+		}
+
+		_, err = workflow.SetStatus(mailID, database.MailStatusReplied, operator, "回复（查询令牌）")
+		if err != nil {
+			fmt.Printf("更新信件状态出现错误: %s\n", err.Error())
+		}
+
+		return replyID, nil
+	}
+
+	var replyTo *mail.Address
+	if relay.Enabled() {
+		replyTo, err = relay.Alias(orig.siteID, mailID) // 留言人的回复经由别名转给我们
+		if err != nil {
+			fmt.Printf("生成回复别名出现错误: %s\n", err.Error())
+			replyTo = nil
+		}
+	}
+
+	smtpID, sendErr := smtpserver.SendReply(orig.siteID, subject, quote(content, orig), thread, replyTo, orig.addr)
+
+	err = database.UpdateMailReply(replyID, smtpID, sendErr)
+	if err != nil {
+		fmt.Printf("数据库更新回复记录出现错误: %s\n", err.Error())
+	}
+
+	if sendErr != nil {
+		return replyID, sendErr
+	}
+
+	_, err = workflow.SetStatus(mailID, database.MailStatusReplied, operator, "发送回复")
+	if err != nil {
+		fmt.Printf("更新信件状态出现错误: %s\n", err.Error())
+	}
+
+	return replyID, nil
+}
+
+func findOriginal(mailID string) (*original, error) {
+	record, err := database.FindMailRecord(mailID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch record.MailType {
+	case database.MsgTypeWebsite:
+		m, err := database.FindAMMail(mailID)
+		if err != nil {
+			return nil, err
+		}
+
+		addr, err := mail.ParseAddress(m.Email)
+		if err != nil || !utils.IsValidEmail(addr.Address) {
+			hasTicket, err := database.HasTicket(mailID)
+			if err != nil {
+				return nil, err
+			} else if !hasTicket {
+				return nil, ErrNoAddress
+			}
+			addr = nil
+		} else if addr.Name == "" {
+			addr.Name = m.Name
+		}
+
+		return &original{
+			siteID:  m.SiteID,
+			subject: fmt.Sprintf("回复：您在%s的留言", site.Get(m.SiteID).Name),
+			content: m.Content,
+			time:    m.Time,
+			addr:    addr,
+		}, nil
+	case database.MsgTypeEmail:
+		m, err := database.FindIMAPMail(mailID)
+		if err != nil {
+			return nil, err
+		}
+
+		addr, err := mail.ParseAddress(m.ReplyTo)
+		if err != nil || !utils.IsValidEmail(addr.Address) {
+			addr, err = mail.ParseAddress(m.From)
+			if err != nil || !utils.IsValidEmail(addr.Address) {
+				return nil, ErrNoAddress
+			}
+		}
+
+		subject := m.Subject
+		if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+			subject = "Re: " + subject
+		}
+
+		messageID := m.MessageID
+		if messageID != "" && !strings.HasPrefix(messageID, "<") {
+			messageID = "<" + messageID + ">"
+		}
+
+		return &original{
+			siteID:    m.SiteID,
+			messageID: messageID,
+			subject:   subject,
+			content:   m.Content,
+			time:      m.SendTime,
+			addr:      addr,
+		}, nil
+	case database.MsgTypeSystem:
+		return nil, ErrNotReplyable
+	default:
+		return nil, errors.New("unknown message type")
+	}
+}
+
+// quote 在回复后附上原信件内容
+func quote(content string, orig *original) string {
+	var res strings.Builder
+	res.WriteString(content)
+	res.WriteString("\n\n")
+	res.WriteString(fmt.Sprintf("—— 您于 %s 的留言 ——\n", orig.time.In(flagparser.TimeZone()).Format("2006-01-02 15:04:05")))
+
+	for _, line := range strings.Split(strings.TrimSpace(orig.content), "\n") {
+		res.WriteString("> ")
+		res.WriteString(line)
+		res.WriteString("\n")
+	}
+
+	return res.String()
+}
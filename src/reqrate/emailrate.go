@@ -11,6 +11,7 @@ import (
 
 // RateExp: 计算周期
 // RateMaxCount: 周期内最大发件数量
+// 默认值，可以通过参数修改
 const (
 	userEmailRateExp      time.Duration = 1 * time.Hour
 	userEmailRateMaxCount int           = 18
@@ -19,6 +20,7 @@ const (
 type UserEmail any
 
 type userEmailRate struct {
+	Site      string
 	UserEmail string
 	Rate      *rate.Limiter
 	Time      time.Time
@@ -27,12 +29,15 @@ type userEmailRate struct {
 
 var userEmailRaterMap sync.Map
 
-func getUserEmailRate(uesrMail UserEmail) (rater *userEmailRate) {
+func getUserEmailRate(siteID string, uesrMail UserEmail) (rater *userEmailRate) {
+	limit := getRateLimit(siteID).Email
+
 	rater = &userEmailRate{
-		UserEmail: _getUserEmailName(uesrMail),
-		Rate:      rate.NewLimiter(rate.Every(userEmailRateExp), userEmailRateMaxCount),
+		Site:      siteID,
+		UserEmail: _getUserEmail(uesrMail),
+		Rate:      limit.NewLimiter(),
 		Time:      time.Now(),
-		RateExp:   userEmailRateExp,
+		RateExp:   limit.Exp,
 	}
 
 	raterInterface, ok := userEmailRaterMap.LoadOrStore(rater.GetName(), rater)
@@ -45,11 +50,11 @@ func getUserEmailRate(uesrMail UserEmail) (rater *userEmailRate) {
 }
 
 func (a *userEmailRate) GetName() string {
-	return _getUserEmailName(a.UserEmail)
+	return _getUserEmailName(a.Site, a.UserEmail)
 }
 
-func _getUserEmailName(userEmail UserEmail) string {
-	return fmt.Sprintf("%s", _getUserEmail(userEmail))
+func _getUserEmailName(siteID string, userEmail UserEmail) string {
+	return fmt.Sprintf("%s::%s", siteID, _getUserEmail(userEmail))
 }
 
 func _getUserEmail(addr UserEmail) string {
@@ -101,16 +106,16 @@ func CleanUserEmailRate() {
 	}
 }
 
-func CheckIMAPRate(envelope *imap.Envelope) bool {
+func CheckIMAPRate(siteID string, envelope *imap.Envelope) bool {
 	addressList := make([]imap.Address, 0, len(envelope.Sender)+len(envelope.From)+len(envelope.ReplyTo))
 	addressList = append(addressList, envelope.Sender...)
 	addressList = append(addressList, envelope.From...)
 	addressList = append(addressList, envelope.ReplyTo...)
 
-	return checkMailAddressListRate(addressList)
+	return checkMailAddressListRate(siteID, addressList)
 }
 
-func checkMailAddressListRate(addressList []imap.Address) bool {
+func checkMailAddressListRate(siteID string, addressList []imap.Address) bool {
 	var addressMap = make(map[string]bool, len(addressList))
 
 	for _, address := range addressList {
@@ -118,7 +123,7 @@ func checkMailAddressListRate(addressList []imap.Address) bool {
 			continue
 		}
 
-		if !CheckMailAddressRate(address) {
+		if !CheckMailAddressRate(siteID, address) {
 			return false
 		}
 
@@ -128,7 +133,7 @@ func checkMailAddressListRate(addressList []imap.Address) bool {
 	return true
 }
 
-func CheckMailAddressListRate(addressList []*imap.Address) bool {
+func CheckMailAddressListRate(siteID string, addressList []*imap.Address) bool {
 	var addressMap = make(map[string]bool, len(addressList))
 
 	for _, address := range addressList {
@@ -136,7 +141,7 @@ func CheckMailAddressListRate(addressList []*imap.Address) bool {
 			continue
 		}
 
-		if !CheckMailAddressRate(address) {
+		if !CheckMailAddressRate(siteID, address) {
 			return false
 		}
 
@@ -146,8 +151,8 @@ func CheckMailAddressListRate(addressList []*imap.Address) bool {
 	return true
 }
 
-func CheckMailAddressRate(userEmail UserEmail) bool {
-	rater := getUserEmailRate(userEmail)
+func CheckMailAddressRate(siteID string, userEmail UserEmail) bool {
+	rater := getUserEmailRate(siteID, userEmail)
 	rater.Time = time.Now()
 	return rater.Rate.Allow()
 }
@@ -10,6 +10,7 @@ import (
 
 // RateExp: 计算周期
 // RateMaxCount: 周期内最大发件数量
+// 默认值，可以通过参数修改
 const (
 	ipRateExp      time.Duration = 1 * time.Hour
 	ipRateMaxCount int           = 36
@@ -18,6 +19,7 @@ const (
 type IP any
 
 type ipRate struct {
+	Site    string
 	IP      string
 	Rate    *rate.Limiter
 	Time    time.Time
@@ -26,12 +28,15 @@ type ipRate struct {
 
 var ipRaterMap sync.Map
 
-func getIPRate(ip IP) (rater *ipRate) {
+func getIPRate(siteID string, ip IP) (rater *ipRate) {
+	limit := getRateLimit(siteID).IP
+
 	rater = &ipRate{
+		Site:    siteID,
 		IP:      _getIP(ip),
-		Rate:    rate.NewLimiter(rate.Every(ipRateExp), ipRateMaxCount),
+		Rate:    limit.NewLimiter(),
 		Time:    time.Now(),
-		RateExp: ipRateExp,
+		RateExp: limit.Exp,
 	}
 
 	raterInterface, ok := ipRaterMap.LoadOrStore(rater.GetName(), rater)
@@ -44,11 +49,11 @@ func getIPRate(ip IP) (rater *ipRate) {
 }
 
 func (a *ipRate) GetName() string {
-	return _getIPName(a.IP)
+	return _getIPName(a.Site, a.IP)
 }
 
-func _getIPName(ip IP) string {
-	return fmt.Sprintf("%s", _getIP(ip))
+func _getIPName(siteID string, ip IP) string {
+	return fmt.Sprintf("%s::%s", siteID, _getIP(ip))
 }
 
 func _getIP(ip IP) string {
@@ -96,8 +101,8 @@ func CleanIPRate() {
 	}
 }
 
-func CheckHttpReqIP(ip IP) bool {
-	rater := getIPRate(ip)
+func CheckHttpReqIP(siteID string, ip IP) bool {
+	rater := getIPRate(siteID, ip)
 	rater.Time = time.Now()
 	return rater.Rate.Allow()
 }
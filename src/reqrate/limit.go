@@ -0,0 +1,159 @@
+package reqrate
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"golang.org/x/time/rate"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimit 限流参数，周期 Exp 内最多 MaxCount 次
+type rateLimit struct {
+	Exp      time.Duration
+	MaxCount int
+}
+
+func (r *rateLimit) NewLimiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Every(r.Exp), r.MaxCount)
+}
+
+func (r *rateLimit) String() string {
+	return fmt.Sprintf("%d/%s", r.MaxCount, r.Exp)
+}
+
+// siteRateLimit 一个站点的限流参数
+type siteRateLimit struct {
+	IP    *rateLimit
+	Email *rateLimit
+	SMTP  *rateLimit
+}
+
+var defaultRateLimit = &siteRateLimit{
+	IP:    &rateLimit{Exp: ipRateExp, MaxCount: ipRateMaxCount},
+	Email: &rateLimit{Exp: userEmailRateExp, MaxCount: userEmailRateMaxCount},
+	SMTP:  &rateLimit{Exp: smtpRateExp, MaxCount: smtpRateMaxCount},
+}
+
+// rateLimits 每个站点的限流参数，键为站点ID
+var rateLimits atomic.Pointer[map[string]*siteRateLimit]
+
+func getRateLimit(siteID string) *siteRateLimit {
+	limits := rateLimits.Load()
+	if limits == nil {
+		return defaultRateLimit
+	}
+
+	if res, ok := (*limits)[siteID]; ok {
+		return res
+	} else if res, ok := (*limits)[flagparser.DefaultSiteID]; ok {
+		return res
+	}
+
+	return defaultRateLimit
+}
+
+// InitRateLimit 根据每个站点的 --ip-rate、--email-rate 和 --smtp-rate 设置限流参数，已存在的限流器也会被更新（不会清空计数）
+func InitRateLimit() error {
+	options, err := flagparser.AllSiteOptions()
+	if err != nil {
+		return err
+	}
+
+	limits := make(map[string]*siteRateLimit, len(options))
+
+	for _, opt := range options {
+		limit, err := newSiteRateLimit(opt)
+		if err != nil {
+			if opt.IsDefault() {
+				return err
+			}
+			return fmt.Errorf("site %s: %s", opt.ID, err.Error())
+		}
+		limits[opt.ID] = limit
+	}
+
+	rateLimits.Store(&limits)
+
+	updateLimiter(&ipRaterMap, func(value any) (*rate.Limiter, *rateLimit) {
+		if rater, ok := value.(*ipRate); ok {
+			return rater.Rate, getRateLimit(rater.Site).IP
+		}
+		return nil, nil
+	})
+
+	updateLimiter(&userEmailRaterMap, func(value any) (*rate.Limiter, *rateLimit) {
+		if rater, ok := value.(*userEmailRate); ok {
+			return rater.Rate, getRateLimit(rater.Site).Email
+		}
+		return nil, nil
+	})
+
+	updateLimiter(&smtpRaterMap, func(value any) (*rate.Limiter, *rateLimit) {
+		if rater, ok := value.(*addressRate); ok {
+			return rater.Rate, getRateLimit(rater.Site).SMTP
+		}
+		return nil, nil
+	})
+
+	return nil
+}
+
+func newSiteRateLimit(opt *flagparser.SiteOptions) (*siteRateLimit, error) {
+	ipLimit, err := parseRateLimit(opt.IPRate)
+	if err != nil {
+		return nil, fmt.Errorf("bad ip rate: %s", err.Error())
+	}
+
+	emailLimit, err := parseRateLimit(opt.EmailRate)
+	if err != nil {
+		return nil, fmt.Errorf("bad email rate: %s", err.Error())
+	}
+
+	smtpLimit, err := parseRateLimit(opt.SMTPRate)
+	if err != nil {
+		return nil, fmt.Errorf("bad smtp rate: %s", err.Error())
+	}
+
+	return &siteRateLimit{
+		IP:    ipLimit,
+		Email: emailLimit,
+		SMTP:  smtpLimit,
+	}, nil
+}
+
+func updateLimiter(m *sync.Map, getLimiter func(value any) (*rate.Limiter, *rateLimit)) {
+	m.Range(func(key, value any) bool {
+		if limiter, limit := getLimiter(value); limiter != nil {
+			limiter.SetLimit(rate.Every(limit.Exp))
+			limiter.SetBurst(limit.MaxCount)
+		}
+		return true
+	})
+}
+
+// parseRateLimit 解析形如 36/1h 的限流参数
+func parseRateLimit(s string) (*rateLimit, error) {
+	count, exp, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the form of <count>/<duration>, example: 36/1h", s)
+	}
+
+	maxCount, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || maxCount <= 0 {
+		return nil, fmt.Errorf("bad count: %s", count)
+	}
+
+	duration, err := time.ParseDuration(strings.TrimSpace(exp))
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("bad duration: %s", exp)
+	}
+
+	return &rateLimit{
+		Exp:      duration,
+		MaxCount: maxCount,
+	}, nil
+}
@@ -11,6 +11,7 @@ import (
 
 // RateExp: 计算周期
 // RateMaxCount: 周期内最大发件数量
+// 默认值，可以通过参数修改
 const (
 	smtpRateExp      time.Duration = 12 * time.Hour
 	smtpRateMaxCount int           = 3
@@ -22,11 +23,13 @@ const (
 	SMTPSendTypeToSelf SMTPSendType = "ToSelf"
 	SMTPSendTypeError  SMTPSendType = "Error"
 	SMTPSendTypeThank  SMTPSendType = "Thank"
+	SMTPSendTypeReply  SMTPSendType = "Reply"
 )
 
 type Address any
 
 type addressRate struct {
+	Site    string
 	Type    SMTPSendType
 	Address string
 	Rate    *rate.Limiter
@@ -36,13 +39,16 @@ type addressRate struct {
 
 var smtpRaterMap sync.Map
 
-func getAddressRate(sendType SMTPSendType, address Address) (rater *addressRate) {
+func getAddressRate(siteID string, sendType SMTPSendType, address Address) (rater *addressRate) {
+	limit := getRateLimit(siteID).SMTP
+
 	rater = &addressRate{
+		Site:    siteID,
 		Type:    sendType,
 		Address: _getAddress(address),
-		Rate:    rate.NewLimiter(rate.Every(smtpRateExp), smtpRateMaxCount),
+		Rate:    limit.NewLimiter(),
 		Time:    time.Now(),
-		RateExp: smtpRateExp,
+		RateExp: limit.Exp,
 	}
 
 	raterInterface, ok := smtpRaterMap.LoadOrStore(rater.GetName(), rater)
@@ -55,11 +61,11 @@ func getAddressRate(sendType SMTPSendType, address Address) (rater *addressRate)
 }
 
 func (a *addressRate) GetName() string {
-	return _getSMTPAddressName(a.Type, a.Address)
+	return _getSMTPAddressName(a.Site, a.Type, a.Address)
 }
 
-func _getSMTPAddressName(sendType SMTPSendType, address Address) string {
-	return fmt.Sprintf("%s::%s", sendType, _getAddress(address))
+func _getSMTPAddressName(siteID string, sendType SMTPSendType, address Address) string {
+	return fmt.Sprintf("%s::%s::%s", siteID, sendType, _getAddress(address))
 }
 
 func _getAddress(addr Address) string {
@@ -111,8 +117,8 @@ func CleanAddressRate() {
 	}
 }
 
-func CheckSMTPSendAddressRate(sendType SMTPSendType, address Address) bool {
-	rater := getAddressRate(sendType, address)
+func CheckSMTPSendAddressRate(siteID string, sendType SMTPSendType, address Address) bool {
+	rater := getAddressRate(siteID, sendType, address)
 	rater.Time = time.Now()
 	return rater.Rate.Allow()
 }
@@ -0,0 +1,373 @@
+package resend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/attachment"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/sender"
+	"github.com/SongZihuan/anonymous-message/src/site"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	netmail "net/mail"
+	"strings"
+	"time"
+)
+
+// Task 一条待重新投递的通知
+type Task struct {
+	MailID   string
+	MailType database.MsgType
+	Notifier string
+	Reason   string
+	Time     time.Time
+}
+
+// ResendMail 将一封信件重新投递到指定的渠道，names 为空表示所有启用的渠道，返回失败的渠道数
+func ResendMail(mailID string, names []string) (int, error) {
+	if !database.Ready() {
+		return 0, fmt.Errorf("sqlite is not enabled")
+	}
+
+	n, err := LoadNotification(mailID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(names) == 0 {
+		for _, nt := range notifier.SiteNotifiers(n.SiteID) {
+			names = append(names, nt.Name())
+		}
+	}
+
+	failed := 0
+	for _, name := range names {
+		if !redeliver(n, name) {
+			failed++
+		}
+	}
+
+	return failed, nil
+}
+
+// FindFailed 查找 since 之后投递失败的通知：包括投递队列中的死信，以及企业微信、电子邮件没有成功送达的信件，只包括信件所属站点启用的渠道
+func FindFailed(since time.Time, names []string) ([]Task, error) {
+	if !database.Ready() {
+		return nil, fmt.Errorf("sqlite is not enabled")
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range notifier.AllNotifiers() {
+		if len(names) == 0 || contains(names, name) {
+			enabled[name] = true
+		}
+	}
+
+	var res []Task
+	seen := make(map[string]bool)
+
+	add := func(task Task) {
+		key := task.MailID + "\n" + task.Notifier
+		if seen[key] {
+			return
+		}
+
+		pending, err := database.HasPendingOutbox(task.MailID, task.Notifier)
+		if err != nil || pending {
+			return // 仍在投递队列中，交给投递队列处理
+		}
+
+		seen[key] = true
+		res = append(res, task)
+	}
+
+	deadList, err := database.FindDeadOutbox(since)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range deadList {
+		var n notifier.Notification
+		_ = json.Unmarshal([]byte(record.Payload), &n) // 只需要站点，解析失败时按默认站点处理
+
+		if !enabled[record.Notifier] || !siteEnabled(n.SiteID, record.Notifier) {
+			continue
+		}
+
+		var mailType database.MsgType
+		if mail, err := database.FindMailRecord(record.MailID); err == nil {
+			mailType = mail.MailType
+		}
+
+		add(Task{
+			MailID:   record.MailID,
+			MailType: mailType,
+			Notifier: record.Notifier,
+			Reason:   fmt.Sprintf("死信（尝试 %d 次）：%s", record.Attempts, record.ErrMsg.String),
+			Time:     record.Time,
+		})
+	}
+
+	mailList, err := database.FindUndeliveredMail(since)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mail := range mailList {
+		if mail.WxRobotFail && enabled[sender.NotifierWxRobot] && siteEnabled(mail.SiteID, sender.NotifierWxRobot) {
+			add(Task{
+				MailID:   mail.MailID,
+				MailType: mail.MailType,
+				Notifier: sender.NotifierWxRobot,
+				Reason:   failReason(mail.WxRobotError),
+				Time:     mail.Time,
+			})
+		}
+
+		if mail.EmailFail && enabled[sender.NotifierEmail] && siteEnabled(mail.SiteID, sender.NotifierEmail) {
+			add(Task{
+				MailID:   mail.MailID,
+				MailType: mail.MailType,
+				Notifier: sender.NotifierEmail,
+				Reason:   failReason(mail.EmailError),
+				Time:     mail.Time,
+			})
+		}
+	}
+
+	return res, nil
+}
+
+// ResendFailed 重新投递 FindFailed 找到的通知，返回任务总数和失败数
+func ResendFailed(since time.Time, names []string) (int, int, error) {
+	tasks, err := FindFailed(since, names)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	failed := 0
+	for _, task := range tasks {
+		n, err := LoadNotification(task.MailID)
+		if err != nil {
+			fmt.Printf("信件 %s 读取失败: %s\n", task.MailID, err.Error())
+			failed++
+			continue
+		}
+
+		if !redeliver(n, task.Notifier) {
+			failed++
+		}
+	}
+
+	return len(tasks), failed, nil
+}
+
+// LoadNotification 取回信件的通知内容，优先使用投递队列中保存的原始通知，否则根据数据库中的信件重新生成
+func LoadNotification(mailID string) (notifier.Notification, error) {
+	var n notifier.Notification
+
+	record, err := database.FindLastOutbox(mailID)
+	if err == nil {
+		err = json.Unmarshal([]byte(record.Payload), &n)
+		if err == nil {
+			n.Done = nil // 已完成的部分属于原来的投递，重新投递时需要发送全部内容
+			return n, nil
+		}
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return n, err
+	}
+
+	mail, err := database.FindMailRecord(mailID)
+	if err != nil {
+		return n, fmt.Errorf("mail %s not found: %s", mailID, err.Error())
+	}
+
+	switch mail.MailType {
+	case database.MsgTypeWebsite:
+		am, err := database.FindAMMail(mailID)
+		if err != nil {
+			return n, err
+		}
+		return amNotification(am), nil
+	case database.MsgTypeEmail:
+		imap, err := database.FindIMAPMail(mailID)
+		if err != nil {
+			return n, err
+		}
+		return imapNotification(imap), nil
+	case database.MsgTypeSystem:
+		sn, err := database.FindSNMail(mailID)
+		if err != nil {
+			return n, err
+		}
+		return snNotification(sn), nil
+	default:
+		return n, fmt.Errorf("unknown mail type: %s", mail.MailType)
+	}
+}
+
+func redeliver(n notifier.Notification, name string) bool {
+	err := notifier.Redeliver(n, name)
+	if err != nil {
+		fmt.Printf("信件 %s 重新投递到 %s 失败: %s\n", n.MailID, name, err.Error())
+		return false
+	}
+
+	_ = database.MarkOutboxResent(n.MailID, name)
+	fmt.Printf("信件 %s 重新投递到 %s 成功\n", n.MailID, name)
+	return true
+}
+
+func amNotification(mail *database.AMMail) notifier.Notification {
+	fields := []notifier.Field{
+		{Name: "站点", Value: mail.Refer},
+		{Name: "Origin", Value: mail.Origin},
+		{Name: "Host", Value: mail.Host},
+		{Name: "IP地址", Value: mail.IP},
+		{Name: "名字", Value: mail.Name},
+	}
+
+	if st := site.Get(mail.SiteID); !st.IsDefault() {
+		fields = append(fields, notifier.Field{Name: "所属站点", Value: st.Label()})
+	}
+
+	if mail.Email != "" {
+		fields = append(fields, notifier.Field{Name: "邮箱", Value: mail.Email})
+	} else {
+		fields = append(fields, notifier.Field{Name: "邮箱", Value: "未预留"})
+	}
+
+	return notifier.Notification{
+		SiteID:  mail.SiteID,
+		Type:    database.MsgTypeWebsite,
+		MailID:  mail.MailID,
+		Time:    mail.Time,
+		Subject: sender.AMSubject(mail.Origin, mail.Refer),
+		Fields:  fields,
+		Content: mail.Content,
+		Meta: notifier.Meta{
+			Refer:       mail.Refer,
+			Origin:      mail.Origin,
+			Host:        mail.Host,
+			IP:          mail.IP,
+			Name:        mail.Name,
+			Email:       mail.Email,
+			NameSafe:    true,
+			ContentSafe: true,
+		},
+	}
+}
+
+func imapNotification(mail *database.IMAPMail) notifier.Notification {
+	var name, email string
+	if addr, err := netmail.ParseAddress(mail.ReplyTo); err == nil {
+		email = addr.Address
+	}
+	if addr, err := netmail.ParseAddress(mail.From); err == nil {
+		name = utils.FormatEmailAddressToHumanStringJustNameMustSafe(addr)
+	}
+
+	fields := []notifier.Field{
+		{Name: "主题", Value: mail.Subject},
+		{Name: "邮件 MessageID", Value: mail.MessageID},
+		{Name: "发送人", Value: formatAddress(mail.Sender)},
+		{Name: "宣称发送人", Value: formatAddress(mail.From)},
+		{Name: "回复地址", Value: formatAddress(mail.ReplyTo)},
+		{Name: "收件人", Value: formatAddress(mail.To)},
+		{Name: "邮件日期", Value: fmt.Sprintf("%s %s", mail.SendTime.Format("2006-01-02 15:04:05"), mail.SendTime.Location().String())},
+	}
+
+	if st := site.Get(mail.SiteID); !st.IsDefault() {
+		fields = append(fields, notifier.Field{Name: "所属站点", Value: st.Label()})
+	}
+
+	attachments, _ := attachment.Find(mail.MailID)
+	if len(attachments) > 0 {
+		fields = append(fields, notifier.Field{Name: "附件", Value: attachment.Describe(attachments)})
+	}
+
+	if mail.SPF != "" || mail.DKIM != "" || mail.DMARC != "" {
+		fields = append(fields, notifier.Field{Name: "发件人认证", Value: fmt.Sprintf("SPF：%s，DKIM：%s，DMARC：%s", mail.SPF, mail.DKIM, mail.DMARC)})
+	}
+
+	return notifier.Notification{
+		SiteID:  mail.SiteID,
+		Type:    database.MsgTypeEmail,
+		MailID:  mail.MailID,
+		Time:    mail.Time,
+		Subject: sender.IMAPSubject(mail.Subject, name),
+		Fields:  fields,
+		Content: mail.Content,
+		Meta: notifier.Meta{
+			Name:        name,
+			Email:       email,
+			Subject:     mail.Subject,
+			MessageID:   mail.MessageID,
+			Sender:      mail.Sender,
+			From:        mail.From,
+			ReplyTo:     mail.ReplyTo,
+			To:          mail.To,
+			SendTime:    mail.SendTime,
+			SPF:         mail.SPF,
+			DKIM:        mail.DKIM,
+			DMARC:       mail.DMARC,
+			NameSafe:    true,
+			ContentSafe: true,
+		},
+		Attachments: attachments,
+	}
+}
+
+func snNotification(mail *database.SystemNotifyMail) notifier.Notification {
+	return notifier.Notification{
+		Type:    database.MsgTypeSystem,
+		MailID:  mail.MailID,
+		Time:    mail.Time,
+		Subject: sender.SNSubject(mail.Subject),
+		Fields: []notifier.Field{
+			{Name: "主题", Value: mail.Subject},
+			{Name: "日期", Value: fmt.Sprintf("%s %s", mail.Time.Format("2006-01-02 15:04:05"), mail.Time.Location().String())},
+		},
+		Content: mail.Content,
+		Meta: notifier.Meta{
+			Subject:     mail.Subject,
+			NameSafe:    true,
+			ContentSafe: true,
+		},
+	}
+}
+
+func formatAddress(address string) string {
+	addr, err := netmail.ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return utils.FormatEmailAddressToHumanStringMustSafe(addr)
+}
+
+func failReason(errMsg string) string {
+	if errMsg == "" {
+		return "没有投递记录"
+	}
+	return "发送失败：" + errMsg
+}
+
+// siteEnabled 信件所属的站点是否启用了该通知渠道，站点没有启用的渠道不算投递失败
+func siteEnabled(siteID string, name string) bool {
+	for _, nt := range notifier.SiteNotifiers(siteID) {
+		if nt.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if strings.TrimSpace(l) == s {
+			return true
+		}
+	}
+	return false
+}
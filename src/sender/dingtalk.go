@@ -0,0 +1,137 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/sender/internal"
+)
+
+const NotifierDingTalk = "dingtalk"
+
+type dingTalkNotifier struct {
+	webhook string
+	secret  string
+	msgType string
+	webURL  string
+}
+
+func init() {
+	notifier.Register(NotifierDingTalk, newDingTalkNotifier)
+}
+
+func newDingTalkNotifier(opt *flagparser.SiteOptions) (notifier.Notifier, error) {
+	if opt.DingTalkWebhook == "" {
+		return nil, notifier.ErrNotConfigured
+	}
+
+	switch opt.DingTalkMsgType {
+	case internal.DingTalkMsgTypeText, internal.DingTalkMsgTypeMarkdown, internal.DingTalkMsgTypeActionCard:
+	default:
+		return nil, fmt.Errorf("unknown dingtalk msgtype: %s", opt.DingTalkMsgType)
+	}
+
+	return &dingTalkNotifier{
+		webhook: opt.DingTalkWebhook,
+		secret:  opt.DingTalkSecret,
+		msgType: opt.DingTalkMsgType,
+		webURL:  opt.WebURL,
+	}, nil
+}
+
+func (*dingTalkNotifier) Name() string {
+	return NotifierDingTalk
+}
+
+func (*dingTalkNotifier) Capability() notifier.Capability {
+	return notifier.Capability{
+		MaxLength:   18000, // 钉钉限制 20000 字节，预留 json 编码的空间
+		SupportFile: false,
+	}
+}
+
+func (d *dingTalkNotifier) Send(ctx context.Context, n notifier.Notification) error {
+	atAll := n.Type == database.MsgTypeSystem
+
+	texts := n.Segments(d.Capability())
+	if len(texts) == 1 {
+		return internal.DingTalkSend(ctx, d.webhook, d.secret, d.render(n, texts[0], atAll))
+	}
+
+	// 分段时统一使用文本消息，重试时从失败的一段继续发送
+	var done []string
+	for i, text := range texts {
+		part := fmt.Sprintf("segment:%d", i)
+		if n.IsDone(part) {
+			continue
+		}
+
+		err := internal.DingTalkSend(ctx, d.webhook, d.secret, &internal.ReqDingTalkMsg{
+			MsgType: internal.DingTalkMsgTypeText,
+			Text: &internal.DingTalkText{
+				Content: text,
+			},
+			At: &internal.DingTalkAt{
+				IsAtAll: atAll && i == 0,
+			},
+		})
+		if err != nil {
+			return notifier.Partial(done, err)
+		}
+		done = append(done, part)
+	}
+
+	return nil
+}
+
+func (d *dingTalkNotifier) render(n notifier.Notification, text string, atAll bool) *internal.ReqDingTalkMsg {
+	title := notificationTitle(n)
+	at := &internal.DingTalkAt{
+		IsAtAll: atAll,
+	}
+
+	msgType := d.msgType
+	if msgType == internal.DingTalkMsgTypeActionCard && !isHttpURL(d.webURL) {
+		msgType = internal.DingTalkMsgTypeMarkdown // 卡片需要跳转链接
+	}
+
+	var markdown string
+	if msgType != internal.DingTalkMsgTypeText {
+		markdown = renderMarkdown(title, n)
+		if len(markdown) > d.Capability().MaxLength {
+			msgType = internal.DingTalkMsgTypeText
+		}
+	}
+
+	switch msgType {
+	case internal.DingTalkMsgTypeMarkdown:
+		return &internal.ReqDingTalkMsg{
+			MsgType: internal.DingTalkMsgTypeMarkdown,
+			Markdown: &internal.DingTalkMarkdown{
+				Title: title,
+				Text:  markdown,
+			},
+			At: at,
+		}
+	case internal.DingTalkMsgTypeActionCard:
+		return &internal.ReqDingTalkMsg{
+			MsgType: internal.DingTalkMsgTypeActionCard,
+			ActionCard: &internal.DingTalkActionCard{
+				Title:       title,
+				Text:        markdown,
+				SingleTitle: "打开留言板",
+				SingleURL:   d.webURL,
+			},
+		}
+	default:
+		return &internal.ReqDingTalkMsg{
+			MsgType: internal.DingTalkMsgTypeText,
+			Text: &internal.DingTalkText{
+				Content: text,
+			},
+			At: at,
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/attachment"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/emailserver/smtpserver"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/relay"
+	"github.com/SongZihuan/anonymous-message/src/sender/internal"
+	"net/mail"
+	"os"
+)
+
+const NotifierEmail = "email"
+
+type emailNotifier struct {
+	siteID string
+}
+
+func init() {
+	notifier.Register(NotifierEmail, newEmailNotifier)
+}
+
+func newEmailNotifier(opt *flagparser.SiteOptions) (notifier.Notifier, error) {
+	return &emailNotifier{
+		siteID: opt.ID,
+	}, nil
+}
+
+func (*emailNotifier) Name() string {
+	return NotifierEmail
+}
+
+func (*emailNotifier) Capability() notifier.Capability {
+	return notifier.Capability{
+		MaxLength:   0,
+		SupportFile: false,
+	}
+}
+
+func (e *emailNotifier) Send(ctx context.Context, n notifier.Notification) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	var replyTo *mail.Address
+	if relay.Enabled() && n.Type != database.MsgTypeSystem {
+		replyTo, err = relay.Alias(e.siteID, n.MailID)
+		if err != nil {
+			fmt.Printf("生成回复别名出现错误: %s\n", err.Error())
+			replyTo = nil
+		}
+	}
+
+	attachments := emailAttachments(n.Attachments)
+	if flagparser.RawMailAttach && n.Type == database.MsgTypeEmail {
+		attachments = append(attachments, originalAttachment(n.MailID)...)
+	}
+
+	smtpID, err := internal.EmailSendToSelf(e.siteID, n.Subject, n.Text(), replyTo, attachments, n.Time)
+
+	switch n.Type {
+	case database.MsgTypeWebsite:
+		_ = database.UpdateAMEmailSendMsg(n.MailID, smtpID)
+	case database.MsgTypeEmail:
+		_ = database.UpdateIMAPEmailSendMsg(n.MailID, smtpID)
+	case database.MsgTypeSystem:
+		_ = database.UpdateSNEmailSendMsg(n.MailID, smtpID)
+	}
+
+	return err
+}
+
+// emailAttachments 已经保存的附件，文件已被删除的附件会被跳过，不影响通知邮件的发送
+func emailAttachments(list []notifier.Attachment) []smtpserver.Attachment {
+	res := make([]smtpserver.Attachment, 0, len(list))
+	for _, a := range list {
+		if a.Path == "" {
+			continue
+		} else if _, err := os.Stat(a.Path); err != nil {
+			fmt.Printf("附件 %s 不存在，已跳过: %s\n", a.Name, err.Error())
+			continue
+		}
+
+		res = append(res, smtpserver.Attachment{
+			Name: a.Name,
+			Path: a.Path,
+		})
+	}
+	return res
+}
+
+// originalAttachment 原始邮件作为 message/rfc822 附件，读取失败时不影响通知邮件的发送
+func originalAttachment(mailID string) []smtpserver.Attachment {
+	raw, err := attachment.Original(mailID)
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		fmt.Printf("读取原始邮件出现错误: %s\n", err.Error())
+		return nil
+	}
+
+	return []smtpserver.Attachment{{
+		Name:        mailID + ".eml",
+		ContentType: "message/rfc822", // gomail 总是使用 base64 编码附件，常见的邮件客户端都能正常打开
+		Data:        raw,
+	}}
+}
@@ -0,0 +1,176 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/sender/internal"
+	"strings"
+)
+
+const NotifierFeishu = "feishu"
+
+const feishuAtAll = `<at user_id="all">所有人</at> `
+
+type feishuNotifier struct {
+	webhook string
+	secret  string
+	msgType string
+}
+
+func init() {
+	notifier.Register(NotifierFeishu, newFeishuNotifier)
+}
+
+func newFeishuNotifier(opt *flagparser.SiteOptions) (notifier.Notifier, error) {
+	if opt.FeishuWebhook == "" {
+		return nil, notifier.ErrNotConfigured
+	}
+
+	switch opt.FeishuMsgType {
+	case internal.FeishuMsgTypeText, internal.FeishuMsgTypePost, internal.FeishuMsgTypeInteractive:
+	default:
+		return nil, fmt.Errorf("unknown feishu msg_type: %s", opt.FeishuMsgType)
+	}
+
+	return &feishuNotifier{
+		webhook: opt.FeishuWebhook,
+		secret:  opt.FeishuSecret,
+		msgType: opt.FeishuMsgType,
+	}, nil
+}
+
+func (*feishuNotifier) Name() string {
+	return NotifierFeishu
+}
+
+func (*feishuNotifier) Capability() notifier.Capability {
+	return notifier.Capability{
+		MaxLength:   18000, // 飞书限制请求体 20KB，预留 json 编码和卡片结构的空间
+		SupportFile: false,
+	}
+}
+
+func (f *feishuNotifier) Send(ctx context.Context, n notifier.Notification) error {
+	atAll := n.Type == database.MsgTypeSystem
+
+	texts := n.Segments(f.Capability())
+	if len(texts) == 1 {
+		return internal.FeishuSend(ctx, f.webhook, f.secret, f.render(n, texts[0], atAll))
+	}
+
+	// 分段时统一使用文本消息，重试时从失败的一段继续发送
+	var done []string
+	for i, text := range texts {
+		part := fmt.Sprintf("segment:%d", i)
+		if n.IsDone(part) {
+			continue
+		}
+
+		if atAll && i == 0 {
+			text = feishuAtAll + text
+		}
+
+		err := internal.FeishuSend(ctx, f.webhook, f.secret, &internal.ReqFeishuMsg{
+			MsgType: internal.FeishuMsgTypeText,
+			Content: &internal.FeishuText{
+				Text: text,
+			},
+		})
+		if err != nil {
+			return notifier.Partial(done, err)
+		}
+		done = append(done, part)
+	}
+
+	return nil
+}
+
+func (f *feishuNotifier) render(n notifier.Notification, text string, atAll bool) *internal.ReqFeishuMsg {
+	title := notificationTitle(n)
+
+	switch f.msgType {
+	case internal.FeishuMsgTypePost:
+		content := make([][]*internal.FeishuPostElement, 0, len(n.Fields)+6)
+		if atAll {
+			content = append(content, []*internal.FeishuPostElement{{Tag: "at", UserID: "all"}})
+		}
+
+		for _, field := range append(n.StdFields(), n.Fields...) {
+			content = append(content, []*internal.FeishuPostElement{{Tag: "text", Text: fmt.Sprintf("%s：%s", field.Name, field.Value)}})
+		}
+
+		content = append(content, []*internal.FeishuPostElement{{Tag: "text", Text: fmt.Sprintf("消息长度：%d", len(n.Content))}})
+		content = append(content, []*internal.FeishuPostElement{{Tag: "text", Text: notifier.MessageStart + n.Content + notifier.MessageStop}})
+
+		return &internal.ReqFeishuMsg{
+			MsgType: internal.FeishuMsgTypePost,
+			Content: &internal.FeishuPost{
+				Post: map[string]*internal.FeishuPostBody{
+					"zh_cn": {
+						Title:   title,
+						Content: content,
+					},
+				},
+			},
+		}
+	case internal.FeishuMsgTypeInteractive:
+		fields := make([]*internal.FeishuCardField, 0, len(n.Fields)+4)
+		for _, field := range append(n.StdFields(), n.Fields...) {
+			fields = append(fields, &internal.FeishuCardField{
+				IsShort: len([]rune(field.Value)) <= 40,
+				Text: &internal.FeishuCardText{
+					Tag:     "lark_md",
+					Content: fmt.Sprintf("**%s**\n%s", markdownEscape(field.Name), markdownEscape(field.Value)),
+				},
+			})
+		}
+
+		elements := make([]*internal.FeishuCardElement, 0, 4)
+		if atAll {
+			elements = append(elements, &internal.FeishuCardElement{
+				Tag:  "div",
+				Text: &internal.FeishuCardText{Tag: "lark_md", Content: strings.TrimSpace(feishuAtAll)},
+			})
+		}
+
+		elements = append(elements,
+			&internal.FeishuCardElement{Tag: "div", Fields: fields},
+			&internal.FeishuCardElement{Tag: "hr"},
+			&internal.FeishuCardElement{
+				Tag: "div",
+				// 正文使用 plain_text，避免用户内容中的标记生效
+				Text: &internal.FeishuCardText{Tag: "plain_text", Content: n.Content},
+			},
+		)
+
+		template := "blue"
+		if n.Type == database.MsgTypeSystem {
+			template = "red"
+		}
+
+		return &internal.ReqFeishuMsg{
+			MsgType: internal.FeishuMsgTypeInteractive,
+			Card: &internal.FeishuCard{
+				Header: &internal.FeishuCardHeader{
+					Title:    &internal.FeishuCardText{Tag: "plain_text", Content: title},
+					Template: template,
+				},
+				Elements: elements,
+			},
+		}
+	default:
+		if atAll {
+			text = feishuAtAll + text
+		}
+
+		return &internal.ReqFeishuMsg{
+			MsgType: internal.FeishuMsgTypeText,
+			Content: &internal.FeishuText{
+				Text: text,
+			},
+		}
+	}
+}
@@ -7,8 +7,8 @@ import (
 	"time"
 )
 
-func IMAPDataBase(mailID string, messageID string, sender string, from string, to string, replyTo string, subject string, content string, date time.Time, t time.Time) error {
-	err := database.SaveIMAPMail(mailID, messageID, sender, from, to, replyTo, subject, content, date, t)
+func IMAPDataBase(siteID string, mailID string, messageID string, sender string, from string, to string, replyTo string, subject string, content string, spf string, dkim string, dmarc string, date time.Time, t time.Time) error {
+	err := database.SaveIMAPMail(siteID, mailID, messageID, sender, from, to, replyTo, subject, content, spf, dkim, dmarc, date, t)
 	if err != nil {
 		return &internal.SendError{
 			Code:    -1,
@@ -20,31 +20,13 @@ func IMAPDataBase(mailID string, messageID string, sender string, from string, t
 	return nil
 }
 
-func IMAPWechatRobot(msg string) (string, error) {
-	internal.WeChatRobotLock.Lock()
-	defer internal.WeChatRobotLock.Unlock()
-
-	return internal.WechatRobotToSelf(msg)
-}
-
-func IMAPEmail(subject string, from string, msg string, t time.Time) (smtpID string, err error) {
+func IMAPSubject(subject string, from string) string {
 	if subject != "" && from != "" {
-		smtpID, err = internal.EmailSendToSelf(fmt.Sprintf("邮件：%s (%s)", subject, from), msg, t)
+		return fmt.Sprintf("邮件：%s (%s)", subject, from)
 	} else if subject != "" {
-		smtpID, err = internal.EmailSendToSelf(fmt.Sprintf("邮件：%s", subject), msg, t)
+		return fmt.Sprintf("邮件：%s", subject)
 	} else if from != "" {
-		smtpID, err = internal.EmailSendToSelf(fmt.Sprintf("来自 %s 的邮件", from), msg, t)
-	} else {
-		return smtpID, &internal.SendError{
-			Code:    -1,
-			Message: "subject 或 from 为空",
-			Err:     nil,
-		}
+		return fmt.Sprintf("来自 %s 的邮件", from)
 	}
-
-	if err != nil {
-		return smtpID, err
-	}
-
-	return smtpID, nil
+	return "邮件：无主题"
 }
@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	DingTalkMsgTypeText       = "text"
+	DingTalkMsgTypeMarkdown   = "markdown"
+	DingTalkMsgTypeActionCard = "actionCard"
+)
+
+type DingTalkText struct {
+	Content string `json:"content"`
+}
+
+type DingTalkMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type DingTalkActionCard struct {
+	Title       string `json:"title"`
+	Text        string `json:"text"`
+	SingleTitle string `json:"singleTitle"`
+	SingleURL   string `json:"singleURL"`
+}
+
+type DingTalkAt struct {
+	IsAtAll bool `json:"isAtAll"`
+}
+
+type ReqDingTalkMsg struct {
+	MsgType    string              `json:"msgtype"`
+	Text       *DingTalkText       `json:"text,omitempty"`
+	Markdown   *DingTalkMarkdown   `json:"markdown,omitempty"`
+	ActionCard *DingTalkActionCard `json:"actionCard,omitempty"`
+	At         *DingTalkAt         `json:"at,omitempty"`
+}
+
+type RespDingTalkMsg struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func DingTalkSend(ctx context.Context, webhook string, secret string, msg *ReqDingTalkMsg) error {
+	if webhook == "" || msg == nil {
+		return nil
+	}
+
+	target, err := dingTalkSignURL(webhook, secret, time.Now())
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "钉钉Webhook地址错误",
+			Err:     err,
+		}
+	}
+
+	webhookData, err := json.Marshal(msg)
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "编码请求结构体为json错误",
+			Err:     err,
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewBuffer(webhookData))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "读取Body错误",
+			Err:     err,
+		}
+	}
+
+	var respWebhook RespDingTalkMsg
+	err = json.Unmarshal(respData, &respWebhook)
+	if err != nil {
+		return &SendError{
+			Code:    -4,
+			Message: "将body解析成json错误",
+			Err:     err,
+		}
+	}
+
+	if respWebhook.ErrCode != 0 {
+		return &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("钉钉报告错误 (错误码: %d): %s", respWebhook.ErrCode, respWebhook.ErrMsg),
+			Err:     nil,
+		}
+	}
+
+	return nil
+}
+
+// dingTalkSignURL 加签：以毫秒时间戳和 secret 拼接后的字符串，用 secret 计算 HmacSHA256 并 Base64，附加在 URL 上
+func dingTalkSignURL(webhook string, secret string, t time.Time) (string, error) {
+	if secret == "" {
+		return webhook, nil
+	}
+
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := fmt.Sprintf("%d", t.UnixMilli())
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", sign)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
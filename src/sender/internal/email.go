@@ -2,12 +2,13 @@ package internal
 
 import (
 	"github.com/SongZihuan/anonymous-message/src/emailserver/smtpserver"
+	"net/mail"
 	"time"
 )
 
-func EmailSendToSelf(subject string, msg string, t time.Time) (smtpID string, err error) {
+func EmailSendToSelf(siteID string, subject string, msg string, replyTo *mail.Address, attachments []smtpserver.Attachment, t time.Time) (smtpID string, err error) {
 	if subject != "" || msg == "" {
-		smtpID, err = smtpserver.SendToSelf(subject, msg, t)
+		smtpID, err = smtpserver.SendToSelf(siteID, subject, msg, replyTo, attachments, t)
 	} else {
 		return smtpID, &SendError{
 			Code:    -1,
@@ -1,6 +1,10 @@
 package internal
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
 
 type SendError struct {
 	Code    int
@@ -15,3 +19,12 @@ func (s *SendError) Error() string {
 
 	return fmt.Sprintf("%s: %s", s.Message, s.Err.Error())
 }
+
+// redactURL 去掉错误中的 URL，Webhook 和 Telegram 的 URL 中包含密钥，错误会被打印并保存到投递记录中
+func redactURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	FeishuMsgTypeText        = "text"
+	FeishuMsgTypePost        = "post"
+	FeishuMsgTypeInteractive = "interactive"
+)
+
+type FeishuText struct {
+	Text string `json:"text"`
+}
+
+type FeishuPostElement struct {
+	Tag    string `json:"tag"`
+	Text   string `json:"text,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+type FeishuPostBody struct {
+	Title   string                 `json:"title"`
+	Content [][]*FeishuPostElement `json:"content"`
+}
+
+type FeishuPost struct {
+	Post map[string]*FeishuPostBody `json:"post"`
+}
+
+type FeishuCardText struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type FeishuCardField struct {
+	IsShort bool            `json:"is_short"`
+	Text    *FeishuCardText `json:"text"`
+}
+
+type FeishuCardElement struct {
+	Tag    string             `json:"tag"`
+	Text   *FeishuCardText    `json:"text,omitempty"`
+	Fields []*FeishuCardField `json:"fields,omitempty"`
+}
+
+type FeishuCardHeader struct {
+	Title    *FeishuCardText `json:"title"`
+	Template string          `json:"template,omitempty"`
+}
+
+type FeishuCard struct {
+	Header   *FeishuCardHeader    `json:"header"`
+	Elements []*FeishuCardElement `json:"elements"`
+}
+
+type ReqFeishuMsg struct {
+	Timestamp string      `json:"timestamp,omitempty"`
+	Sign      string      `json:"sign,omitempty"`
+	MsgType   string      `json:"msg_type"`
+	Content   any         `json:"content,omitempty"`
+	Card      *FeishuCard `json:"card,omitempty"`
+}
+
+type RespFeishuMsg struct {
+	Code          int    `json:"code"`
+	Msg           string `json:"msg"`
+	StatusCode    int    `json:"StatusCode"`
+	StatusMessage string `json:"StatusMessage"`
+}
+
+func FeishuSend(ctx context.Context, webhook string, secret string, msg *ReqFeishuMsg) error {
+	if webhook == "" || msg == nil {
+		return nil
+	}
+
+	if secret != "" {
+		msg.Timestamp, msg.Sign = feishuSign(secret, time.Now())
+	}
+
+	webhookData, err := json.Marshal(msg)
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "编码请求结构体为json错误",
+			Err:     err,
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewBuffer(webhookData))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "读取Body错误",
+			Err:     err,
+		}
+	}
+
+	var respWebhook RespFeishuMsg
+	err = json.Unmarshal(respData, &respWebhook)
+	if err != nil {
+		return &SendError{
+			Code:    -4,
+			Message: "将body解析成json错误",
+			Err:     err,
+		}
+	}
+
+	// 旧版接口使用 StatusCode，新版接口使用 code
+	if respWebhook.Code != 0 {
+		return &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("飞书报告错误 (错误码: %d): %s", respWebhook.Code, respWebhook.Msg),
+			Err:     nil,
+		}
+	} else if respWebhook.StatusCode != 0 {
+		return &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("飞书报告错误 (错误码: %d): %s", respWebhook.StatusCode, respWebhook.StatusMessage),
+			Err:     nil,
+		}
+	}
+
+	return nil
+}
+
+// feishuSign 签名校验：以秒级时间戳和 secret 拼接后的字符串作为密钥，对空数据计算 HmacSHA256 并 Base64
+func feishuSign(secret string, t time.Time) (timestamp string, sign string) {
+	timestamp = fmt.Sprintf("%d", t.Unix())
+
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	mac.Write([]byte{})
+	sign = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return timestamp, sign
+}
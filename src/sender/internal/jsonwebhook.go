@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	JSONWebhookHeaderSignature = "X-Signature"
+	JSONWebhookHeaderTimestamp = "X-Timestamp"
+	JSONWebhookHeaderMailID    = "X-Mail-ID"
+)
+
+// JSONWebhookPost 发送已签名的 JSON 文档，retry 表示错误是否值得重试（网络错误、429、5xx）
+func JSONWebhookPost(ctx context.Context, target string, secret string, mailID string, body []byte, t time.Time) (retry bool, err error) {
+	if target == "" {
+		return false, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+
+	timestamp := fmt.Sprintf("%d", t.Unix())
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(JSONWebhookHeaderTimestamp, timestamp)
+	req.Header.Set(JSONWebhookHeaderMailID, mailID)
+	if secret != "" {
+		req.Header.Set(JSONWebhookHeaderSignature, JSONWebhookSign(secret, timestamp, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respData, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("Webhook报告错误 (状态码: %d): %s", resp.StatusCode, string(respData)),
+			Err:     nil,
+		}
+	}
+
+	return false, nil
+}
+
+// JSONWebhookSign 签名为 sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))，
+// 接收方应同时校验时间戳与当前时间的差值以防止重放
+func JSONWebhookSign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
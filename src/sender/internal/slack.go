@@ -0,0 +1,253 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const DefaultSlackAPIURL = "https://slack.com/api"
+
+type SlackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+type SlackBlock struct {
+	Type     string       `json:"type"`
+	Text     *SlackText   `json:"text,omitempty"`
+	Fields   []*SlackText `json:"fields,omitempty"`
+	Elements []*SlackText `json:"elements,omitempty"`
+}
+
+type ReqSlackWebhook struct {
+	Text   string        `json:"text"` // 通知预览，以及不支持 Block Kit 的客户端（例如 Mattermost）显示的内容
+	Blocks []*SlackBlock `json:"blocks,omitempty"`
+}
+
+type RespSlackAPI struct {
+	OK        bool   `json:"ok"`
+	Error     string `json:"error"`
+	UploadURL string `json:"upload_url"`
+	FileID    string `json:"file_id"`
+}
+
+type ReqSlackCompleteUpload struct {
+	Files     []ReqSlackCompleteUploadFile `json:"files"`
+	ChannelID string                       `json:"channel_id"`
+}
+
+type ReqSlackCompleteUploadFile struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+func SlackEscape(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	return s
+}
+
+func SlackPostWebhook(ctx context.Context, webhook string, msg *ReqSlackWebhook) error {
+	if webhook == "" || msg == nil {
+		return nil
+	}
+
+	webhookData, err := json.Marshal(msg)
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "编码请求结构体为json错误",
+			Err:     err,
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewBuffer(webhookData))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "读取Body错误",
+			Err:     err,
+		}
+	}
+
+	// incoming webhook 成功时返回 200 和纯文本 ok，失败时返回 4xx 和错误描述
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("Slack报告错误 (状态码: %d): %s", resp.StatusCode, string(respData)),
+			Err:     nil,
+		}
+	}
+
+	return nil
+}
+
+// SlackUploadSnippet 通过 files.getUploadURLExternal 和 files.completeUploadExternal 上传文本片段到指定频道
+func SlackUploadSnippet(ctx context.Context, apiURL string, token string, channelID string, fileName string, title string, msg string) error {
+	if token == "" || channelID == "" || msg == "" {
+		return nil
+	}
+
+	if apiURL == "" {
+		apiURL = DefaultSlackAPIURL
+	}
+	apiURL = strings.TrimRight(apiURL, "/")
+
+	form := url.Values{}
+	form.Set("filename", fileName)
+	form.Set("length", strconv.Itoa(len(msg)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/files.getUploadURLExternal", strings.NewReader(form.Encode()))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	upload, err := slackAPIDo(req)
+	if err != nil {
+		return err
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, upload.UploadURL, strings.NewReader(msg))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("Slack上传文件失败 (状态码: %d)", resp.StatusCode),
+			Err:     nil,
+		}
+	}
+
+	completeData, err := json.Marshal(ReqSlackCompleteUpload{
+		Files: []ReqSlackCompleteUploadFile{
+			{
+				ID:    upload.FileID,
+				Title: title,
+			},
+		},
+		ChannelID: channelID,
+	})
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "编码请求结构体为json错误",
+			Err:     err,
+		}
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/files.completeUploadExternal", bytes.NewBuffer(completeData))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	_, err = slackAPIDo(req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func slackAPIDo(req *http.Request) (*RespSlackAPI, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &SendError{
+			Code:    -3,
+			Message: "读取Body错误",
+			Err:     err,
+		}
+	}
+
+	var respAPI RespSlackAPI
+	err = json.Unmarshal(respData, &respAPI)
+	if err != nil {
+		return nil, &SendError{
+			Code:    -4,
+			Message: "将body解析成json错误",
+			Err:     err,
+		}
+	}
+
+	if !respAPI.OK {
+		return nil, &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("Slack报告错误: %s", respAPI.Error),
+			Err:     nil,
+		}
+	}
+
+	return &respAPI, nil
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+type ReqTelegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type RespTelegram struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func TelegramSendMessage(ctx context.Context, apiURL string, token string, chatID string, msg string) error {
+	if token == "" || chatID == "" || msg == "" {
+		return nil
+	}
+
+	reqData, err := json.Marshal(ReqTelegramMessage{
+		ChatID:                chatID,
+		Text:                  msg,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "编码请求结构体为json错误",
+			Err:     err,
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramMethodURL(apiURL, token, "sendMessage"), bytes.NewBuffer(reqData))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return telegramDo(req)
+}
+
+func TelegramSendDocument(ctx context.Context, apiURL string, token string, chatID string, fileName string, msg string) error {
+	if token == "" || chatID == "" || msg == "" {
+		return nil
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	err := writer.WriteField("chat_id", chatID)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "写入FormData字段错误",
+			Err:     err,
+		}
+	}
+
+	part, err := writer.CreateFormFile("document", fileName)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "创建FormData文件字段错误",
+			Err:     err,
+		}
+	}
+
+	_, err = part.Write([]byte(msg))
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "写入FormData文件数据错误",
+			Err:     err,
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "关闭FormData错误",
+			Err:     err,
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramMethodURL(apiURL, token, "sendDocument"), body)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "生成HTTP-POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return telegramDo(req)
+}
+
+func telegramDo(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "读取Body错误",
+			Err:     err,
+		}
+	}
+
+	var respTelegram RespTelegram
+	err = json.Unmarshal(respData, &respTelegram)
+	if err != nil {
+		return &SendError{
+			Code:    -4,
+			Message: "将body解析成json错误",
+			Err:     err,
+		}
+	}
+
+	if !respTelegram.OK {
+		return &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("Telegram报告错误 (错误码: %d): %s", respTelegram.ErrorCode, respTelegram.Description),
+			Err:     nil,
+		}
+	}
+
+	return nil
+}
+
+func telegramMethodURL(apiURL string, token string, method string) string {
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPIURL
+	}
+	return fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(apiURL, "/"), token, method)
+}
@@ -7,11 +7,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/SongZihuan/anonymous-message/src/database"
-	"github.com/SongZihuan/anonymous-message/src/flagparser"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
 	"sync"
 	"time"
 )
@@ -31,10 +31,13 @@ const (
 	upload_voice = "voice"
 )
 
-var WeChatRobotLock sync.Mutex
+// 企业微信上传的文件需要大于5字节，且不超过20MB
+const (
+	minUploadSize = 5
+	maxUploadSize = 20 * 1024 * 1024
+)
 
-var onceGetKey sync.Once
-var key string
+var WeChatRobotLock sync.Mutex
 
 type WebhookText struct {
 	Content             string   `json:"content"`
@@ -70,8 +73,8 @@ type RespWebhookFile struct {
 	CreatedAt string `json:"created_at"`
 }
 
-func WechatRobotToSelf(msg string) (wxrobotID string, err error) {
-	if flagparser.Webhook == "" || msg == "" {
+func WechatRobotToSelf(webhook string, msg string) (wxrobotID string, err error) {
+	if webhook == "" || msg == "" {
 		return "", nil
 	}
 
@@ -83,9 +86,9 @@ func WechatRobotToSelf(msg string) (wxrobotID string, err error) {
 		}
 	}()
 
-	wxrobotID = getWxRobotID(flagparser.Webhook, msg, now)
+	wxrobotID = getWxRobotID(webhook, msg, now)
 
-	err = database.SaveWxRobotRecord(wxrobotID, flagparser.Webhook, msg, now)
+	err = database.SaveWxRobotRecord(wxrobotID, webhook, msg, now)
 	if err != nil {
 		return "", err
 	}
@@ -112,12 +115,12 @@ func WechatRobotToSelf(msg string) (wxrobotID string, err error) {
 		}
 	}
 
-	resp, err := http.Post(flagparser.Webhook, "application/json", bytes.NewBuffer(webhookData))
+	resp, err := http.Post(webhook, "application/json", bytes.NewBuffer(webhookData))
 	if err != nil {
 		return wxrobotID, &SendError{
 			Code:    -2,
 			Message: "提交POST请求错误",
-			Err:     err,
+			Err:     redactURL(err),
 		}
 	}
 	defer func() {
@@ -154,8 +157,8 @@ func WechatRobotToSelf(msg string) (wxrobotID string, err error) {
 	return wxrobotID, nil
 }
 
-func WechatRobotSystemNotifyToSelf(msg string) (wxrobotID string, err error) {
-	if flagparser.Webhook == "" || msg == "" {
+func WechatRobotSystemNotifyToSelf(webhook string, msg string) (wxrobotID string, err error) {
+	if webhook == "" || msg == "" {
 		return "", nil
 	}
 
@@ -167,9 +170,9 @@ func WechatRobotSystemNotifyToSelf(msg string) (wxrobotID string, err error) {
 		}
 	}()
 
-	wxrobotID = getWxRobotID(flagparser.Webhook, msg, now)
+	wxrobotID = getWxRobotID(webhook, msg, now)
 
-	err = database.SaveWxRobotRecord(wxrobotID, flagparser.Webhook, msg, now)
+	err = database.SaveWxRobotRecord(wxrobotID, webhook, msg, now)
 	if err != nil {
 		return wxrobotID, err
 	}
@@ -197,12 +200,12 @@ func WechatRobotSystemNotifyToSelf(msg string) (wxrobotID string, err error) {
 		}
 	}
 
-	resp, err := http.Post(flagparser.Webhook, "application/json", bytes.NewBuffer(webhookData))
+	resp, err := http.Post(webhook, "application/json", bytes.NewBuffer(webhookData))
 	if err != nil {
 		return wxrobotID, &SendError{
 			Code:    -2,
 			Message: "提交POST请求错误",
-			Err:     err,
+			Err:     redactURL(err),
 		}
 	}
 	defer func() {
@@ -239,8 +242,8 @@ func WechatRobotSystemNotifyToSelf(msg string) (wxrobotID string, err error) {
 	return wxrobotID, nil
 }
 
-func WechatRobotMarkdownToSelf(msg string) (wxrobotID string, err error) {
-	if flagparser.Webhook == "" || msg == "" {
+func WechatRobotMarkdownToSelf(webhook string, msg string) (wxrobotID string, err error) {
+	if webhook == "" || msg == "" {
 		return "", nil
 	}
 
@@ -252,9 +255,9 @@ func WechatRobotMarkdownToSelf(msg string) (wxrobotID string, err error) {
 		}
 	}()
 
-	wxrobotID = getWxRobotID(flagparser.Webhook, msg, now)
+	wxrobotID = getWxRobotID(webhook, msg, now)
 
-	err = database.SaveWxRobotRecord(wxrobotID, flagparser.Webhook, msg, now)
+	err = database.SaveWxRobotRecord(wxrobotID, webhook, msg, now)
 	if err != nil {
 		return "", err
 	}
@@ -281,12 +284,12 @@ func WechatRobotMarkdownToSelf(msg string) (wxrobotID string, err error) {
 		}
 	}
 
-	resp, err := http.Post(flagparser.Webhook, "application/json", bytes.NewBuffer(webhookData))
+	resp, err := http.Post(webhook, "application/json", bytes.NewBuffer(webhookData))
 	if err != nil {
 		return wxrobotID, &SendError{
 			Code:    -2,
 			Message: "提交POST请求错误",
-			Err:     err,
+			Err:     redactURL(err),
 		}
 	}
 	defer func() {
@@ -323,8 +326,8 @@ func WechatRobotMarkdownToSelf(msg string) (wxrobotID string, err error) {
 	return wxrobotID, nil
 }
 
-func WechatRobotFileToSelf(msg string, wxrobotID string) (_ string, fileID string, err error) {
-	if flagparser.Webhook == "" || msg == "" {
+func WechatRobotFileToSelf(webhook string, msg string, wxrobotID string) (_ string, fileID string, err error) {
+	if webhook == "" || msg == "" {
 		return "", "", nil
 	}
 
@@ -339,7 +342,7 @@ func WechatRobotFileToSelf(msg string, wxrobotID string) (_ string, fileID strin
 		return "", "", err
 	}
 
-	fileID, err = uploadMsgFile(msg)
+	fileID, err = uploadMsgFile(webhook, msg)
 	if err != nil {
 		return wxrobotID, fileID, &SendError{
 			Code:    -1,
@@ -362,12 +365,12 @@ func WechatRobotFileToSelf(msg string, wxrobotID string) (_ string, fileID strin
 		}
 	}
 
-	resp, err := http.Post(flagparser.Webhook, "application/json", bytes.NewBuffer(webhookData))
+	resp, err := http.Post(webhook, "application/json", bytes.NewBuffer(webhookData))
 	if err != nil {
 		return wxrobotID, fileID, &SendError{
 			Code:    -2,
 			Message: "提交POST请求错误",
-			Err:     err,
+			Err:     redactURL(err),
 		}
 	}
 	defer func() {
@@ -404,12 +407,96 @@ func WechatRobotFileToSelf(msg string, wxrobotID string) (_ string, fileID strin
 	return wxrobotID, fileID, nil
 }
 
-func uploadMsgFile(msg string) (string, error) {
+// WechatRobotAttachmentToSelf 上传邮件的附件并以文件消息发送
+func WechatRobotAttachmentToSelf(webhook string, name string, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "读取附件错误",
+			Err:     err,
+		}
+	}
+
+	if len(data) <= minUploadSize || len(data) > maxUploadSize {
+		return &SendError{
+			Code:    -1,
+			Message: "附件大小超过企业微信限制",
+			Err:     nil,
+		}
+	}
+
+	fileID, err := uploadFile(webhook, name, data)
+	if err != nil {
+		return err
+	}
+
+	webhookData, err := json.Marshal(ReqWebhookMsg{
+		MsgType: msgtypefile,
+		File: &WebhookFile{
+			MediaID: fileID,
+		},
+	})
+	if err != nil {
+		return &SendError{
+			Code:    -1,
+			Message: "编码请求结构体为json错误",
+			Err:     err,
+		}
+	}
+
+	resp, err := http.Post(webhook, "application/json", bytes.NewBuffer(webhookData))
+	if err != nil {
+		return &SendError{
+			Code:    -2,
+			Message: "提交POST请求错误",
+			Err:     redactURL(err),
+		}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &SendError{
+			Code:    -3,
+			Message: "读取Body错误",
+			Err:     err,
+		}
+	}
+
+	var respWebhook RespWebhookMsg
+	err = json.Unmarshal(respData, &respWebhook)
+	if err != nil {
+		return &SendError{
+			Code:    -4,
+			Message: "将body解析成json错误",
+			Err:     err,
+		}
+	}
+
+	if respWebhook.ErrCode != 0 {
+		return &SendError{
+			Code:    -5,
+			Message: fmt.Sprintf("企业微信报告错误 (错误码: %d): %s", respWebhook.ErrCode, respWebhook.ErrMsg),
+			Err:     nil,
+		}
+	}
+
+	return nil
+}
+
+func uploadMsgFile(webhook string, msg string) (string, error) {
+	return uploadFile(webhook, "message.txt", []byte(msg))
+}
+
+func uploadFile(webhook string, name string, data []byte) (string, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
 	// Create the form file part.
-	part, err := writer.CreateFormFile("media", "message.txt")
+	part, err := writer.CreateFormFile("media", name)
 	if err != nil {
 		return "", &SendError{
 			Code:    -3,
@@ -419,7 +506,7 @@ func uploadMsgFile(msg string) (string, error) {
 	}
 
 	// Copy the file content to the multipart writer.
-	_, err = part.Write([]byte(msg))
+	_, err = part.Write(data)
 	if err != nil {
 		return "", &SendError{
 			Code:    -3,
@@ -439,12 +526,12 @@ func uploadMsgFile(msg string) (string, error) {
 	}
 
 	// Prepare the request.
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s?key=%s&type=%s", upload_media, GetWebHookKey(), upload_file), body)
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s?key=%s&type=%s", upload_media, getWebHookKey(webhook), upload_file), body)
 	if err != nil {
 		return "", &SendError{
 			Code:    -3,
 			Message: "生成HTTP-POST请求错误",
-			Err:     err,
+			Err:     redactURL(err),
 		}
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -456,7 +543,7 @@ func uploadMsgFile(msg string) (string, error) {
 		return "", &SendError{
 			Code:    -3,
 			Message: "HTTP请求错误",
-			Err:     err,
+			Err:     redactURL(err),
 		}
 	}
 	defer func() {
@@ -508,14 +595,10 @@ func getWxRobotID(webhook string, msg string, t time.Time) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-func GetWebHookKey() string {
-	onceGetKey.Do(func() {
-		u, err := url.Parse(flagparser.Webhook)
-		if err == nil {
-			key = u.Query().Get("key")
-		} else {
-			key = ""
-		}
-	})
-	return key
+func getWebHookKey(webhook string) string {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("key")
 }
@@ -0,0 +1,218 @@
+package sender
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/sender/internal"
+	"strings"
+	"time"
+)
+
+const NotifierJSONWebhook = "jsonwebhook"
+
+const JSONWebhookVersion = 1
+
+const jsonWebhookRetryBaseDelay = 2 * time.Second
+
+// JSONWebhookDocument 推送的 JSON 文档，字段保持稳定，新增字段只追加不修改
+type JSONWebhookDocument struct {
+	Version     int        `json:"version"`
+	MailID      string     `json:"mail_id"`
+	Type        string     `json:"type"`
+	TypeName    string     `json:"type_name"`
+	System      string     `json:"system"`
+	SiteID      string     `json:"site_id"` // 默认站点为空
+	Site        string     `json:"site"`
+	Refer       string     `json:"refer"`
+	Origin      string     `json:"origin"`
+	Host        string     `json:"host"`
+	IP          string     `json:"ip"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Anonymous   bool       `json:"anonymous"`
+	Subject     string     `json:"subject"`
+	MessageID   string     `json:"message_id"`
+	Sender      string     `json:"sender"`
+	From        string     `json:"from"`
+	ReplyTo     string     `json:"reply_to"`
+	To          string     `json:"to"`
+	Content     string     `json:"content"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	SentAt      *time.Time `json:"sent_at"` // 仅邮件留言有发送时间，其余为 null
+	DeliveredAt time.Time  `json:"delivered_at"`
+	NameSafe    bool       `json:"name_safe"`
+	ContentSafe bool       `json:"content_safe"`
+}
+
+type jsonWebhookNotifier struct {
+	urls   []string
+	secret string
+	retry  int
+	system string
+}
+
+func init() {
+	notifier.Register(NotifierJSONWebhook, newJSONWebhookNotifier)
+}
+
+func newJSONWebhookNotifier(opt *flagparser.SiteOptions) (notifier.Notifier, error) {
+	urls := make([]string, 0, 5)
+	for _, u := range strings.Split(opt.JSONWebhookURL, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			if !isHttpURL(u) {
+				return nil, fmt.Errorf("json webhook url must be http or https: %s", u)
+			}
+			urls = append(urls, u)
+		}
+	}
+
+	if len(urls) == 0 {
+		return nil, notifier.ErrNotConfigured
+	} else if opt.JSONWebhookSecret == "" {
+		return nil, fmt.Errorf("json webhook secret is empty, the payload must be signed")
+	}
+
+	retry := opt.JSONWebhookRetry
+	if retry < 0 {
+		retry = 0
+	}
+
+	return &jsonWebhookNotifier{
+		urls:   urls,
+		secret: opt.JSONWebhookSecret,
+		retry:  retry,
+		system: opt.Name,
+	}, nil
+}
+
+func (*jsonWebhookNotifier) Name() string {
+	return NotifierJSONWebhook
+}
+
+func (*jsonWebhookNotifier) Capability() notifier.Capability {
+	return notifier.Capability{
+		MaxLength:   0,
+		SupportFile: false,
+	}
+}
+
+func (j *jsonWebhookNotifier) Send(ctx context.Context, n notifier.Notification) error {
+	var errs []error
+	var done []string
+	for i, u := range j.urls {
+		part := jsonWebhookPart(u)
+		if n.IsDone(part) {
+			continue // 重试时跳过已经推送成功的地址
+		}
+
+		err := j.sendTo(ctx, u, n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("url %d: %w", i+1, err)) // 地址中可能包含密钥，只记录序号
+		} else {
+			done = append(done, part)
+		}
+	}
+
+	return notifier.Partial(done, errors.Join(errs...))
+}
+
+func (j *jsonWebhookNotifier) sendTo(ctx context.Context, target string, n notifier.Notification) error {
+	var err error
+
+	for i := 0; i <= j.retry; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(jsonWebhookRetryBaseDelay << (i - 1)):
+			}
+		}
+
+		// 每次重试都重新生成时间戳和签名
+		now := time.Now()
+
+		body, jsonErr := json.Marshal(j.document(n, now))
+		if jsonErr != nil {
+			return &internal.SendError{
+				Code:    -1,
+				Message: "编码请求结构体为json错误",
+				Err:     jsonErr,
+			}
+		}
+
+		var retry bool
+		retry, err = internal.JSONWebhookPost(ctx, target, j.secret, n.MailID, body, now)
+		if err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (j *jsonWebhookNotifier) document(n notifier.Notification, now time.Time) *JSONWebhookDocument {
+	site := n.Meta.Refer
+	if site == "" {
+		site = n.Meta.Origin
+	}
+
+	var sentAt *time.Time
+	if !n.Meta.SendTime.IsZero() {
+		sentAt = &n.Meta.SendTime
+	}
+
+	return &JSONWebhookDocument{
+		Version:     JSONWebhookVersion,
+		MailID:      n.MailID,
+		Type:        jsonWebhookType(n.Type),
+		TypeName:    string(n.Type),
+		System:      j.system,
+		SiteID:      n.SiteID,
+		Site:        site,
+		Refer:       n.Meta.Refer,
+		Origin:      n.Meta.Origin,
+		Host:        n.Meta.Host,
+		IP:          n.Meta.IP,
+		Name:        n.Meta.Name,
+		Email:       n.Meta.Email,
+		Anonymous:   n.Meta.Anonymous,
+		Subject:     n.Meta.Subject,
+		MessageID:   n.Meta.MessageID,
+		Sender:      n.Meta.Sender,
+		From:        n.Meta.From,
+		ReplyTo:     n.Meta.ReplyTo,
+		To:          n.Meta.To,
+		Content:     n.Content,
+		ReceivedAt:  n.Time,
+		SentAt:      sentAt,
+		DeliveredAt: now,
+		NameSafe:    n.Meta.NameSafe,
+		ContentSafe: n.Meta.ContentSafe,
+	}
+}
+
+// jsonWebhookPart 投递队列中记录已推送的地址时只保存地址的哈希
+func jsonWebhookPart(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return "url:" + hex.EncodeToString(sum[:8])
+}
+
+func jsonWebhookType(msgType database.MsgType) string {
+	switch msgType {
+	case database.MsgTypeWebsite:
+		return "website"
+	case database.MsgTypeEmail:
+		return "email"
+	case database.MsgTypeSystem:
+		return "system"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,52 @@
+package sender
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"strings"
+)
+
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"*", "\\*",
+	"_", "\\_",
+	"`", "\\`",
+	"#", "\\#",
+	"[", "\\[",
+	"]", "\\]",
+	"!", "\\!",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+// markdownEscape 转义用户内容，避免其中的链接、图片等标记生效
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// renderMarkdown 以列表展示头部信息，正文放在分割线之后
+func renderMarkdown(title string, n notifier.Notification) string {
+	var msgBuilder strings.Builder
+
+	msgBuilder.WriteString(fmt.Sprintf("#### %s\n\n", markdownEscape(title)))
+
+	for _, f := range append(n.StdFields(), n.Fields...) {
+		msgBuilder.WriteString(fmt.Sprintf("- **%s**：%s\n", markdownEscape(f.Name), markdownEscape(f.Value)))
+	}
+
+	msgBuilder.WriteString(fmt.Sprintf("- **消息长度**：%d\n\n---\n\n", len(n.Content)))
+	msgBuilder.WriteString(strings.ReplaceAll(markdownEscape(n.Content), "\n", "  \n"))
+
+	return msgBuilder.String()
+}
+
+func notificationTitle(n notifier.Notification) string {
+	if n.Subject != "" {
+		return n.Subject
+	}
+	return string(n.Type)
+}
+
+func isHttpURL(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
@@ -0,0 +1,173 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/sender/internal"
+)
+
+const NotifierSlack = "slack"
+
+// Block Kit 的长度限制
+const (
+	slackMaxHeaderLength  = 150
+	slackMaxFieldLength   = 2000
+	slackMaxSectionLength = 3000
+	slackMaxSectionFields = 10
+)
+
+const slackTruncateTip = "\n……（消息过长，已截断，请查看邮箱）"
+
+// slackWebhookPart 通过 Webhook 发送的消息，上传文件失败时记录为已完成
+const slackWebhookPart = "webhook"
+
+type slackNotifier struct {
+	webhook string
+	apiURL  string
+	token   string
+	channel string
+	name    string
+}
+
+func init() {
+	notifier.Register(NotifierSlack, newSlackNotifier)
+}
+
+func newSlackNotifier(opt *flagparser.SiteOptions) (notifier.Notifier, error) {
+	if opt.SlackWebhook == "" {
+		return nil, notifier.ErrNotConfigured
+	}
+
+	return &slackNotifier{
+		webhook: opt.SlackWebhook,
+		apiURL:  opt.SlackAPIURL,
+		token:   opt.SlackToken,
+		channel: opt.SlackChannel,
+		name:    opt.Name,
+	}, nil
+}
+
+func (*slackNotifier) Name() string {
+	return NotifierSlack
+}
+
+func (s *slackNotifier) Capability() notifier.Capability {
+	return notifier.Capability{
+		MaxLength:     slackMaxSectionLength,
+		LengthInRunes: true,
+		SupportFile:   s.token != "" && s.channel != "", // incoming webhook 不能上传文件，需要机器人 Token
+	}
+}
+
+func (s *slackNotifier) Send(ctx context.Context, n notifier.Notification) error {
+	capability := s.Capability()
+
+	title := n.Subject
+	if title == "" {
+		title = string(n.Type)
+	}
+
+	blocks := make([]*internal.SlackBlock, 0, 10)
+	blocks = append(blocks, &internal.SlackBlock{
+		Type: "header",
+		Text: &internal.SlackText{
+			Type: "plain_text",
+			Text: truncateRunes(fmt.Sprintf("【%s】%s", s.name, title), slackMaxHeaderLength),
+		},
+	})
+
+	fields := make([]*internal.SlackText, 0, slackMaxSectionFields)
+	for _, f := range append(n.StdFields(), n.Fields...) {
+		if f.Value == "" {
+			continue
+		}
+
+		fields = append(fields, &internal.SlackText{
+			Type: "mrkdwn",
+			Text: truncateRunes(fmt.Sprintf("*%s*\n%s", internal.SlackEscape(f.Name), internal.SlackEscape(f.Value)), slackMaxFieldLength),
+		})
+
+		if len(fields) == slackMaxSectionFields {
+			blocks = append(blocks, &internal.SlackBlock{Type: "section", Fields: fields})
+			fields = make([]*internal.SlackText, 0, slackMaxSectionFields)
+		}
+	}
+
+	if len(fields) > 0 {
+		blocks = append(blocks, &internal.SlackBlock{Type: "section", Fields: fields})
+	}
+
+	blocks = append(blocks, &internal.SlackBlock{Type: "divider"})
+
+	content := n.Content
+	file := ""
+	if len([]rune(content)) > capability.MaxLength {
+		if capability.SupportFile {
+			file = content
+			content = notifier.SendFileTip
+		} else {
+			content = truncateRunes(content, capability.MaxLength-len([]rune(slackTruncateTip))) + slackTruncateTip
+		}
+	}
+
+	if content != "" {
+		// 正文使用 plain_text，防止用户内容中的 <!channel> 等标记生效
+		blocks = append(blocks, &internal.SlackBlock{
+			Type: "section",
+			Text: &internal.SlackText{
+				Type: "plain_text",
+				Text: content,
+			},
+		})
+	}
+
+	blocks = append(blocks, &internal.SlackBlock{
+		Type: "context",
+		Elements: []*internal.SlackText{
+			{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("消息长度：%d", len(n.Content)),
+			},
+		},
+	})
+
+	var text string
+	if file != "" {
+		text = n.Header() + notifier.SendFileTip
+	} else {
+		text = n.Header() + notifier.MessageStart + content + notifier.MessageStop
+	}
+
+	if !n.IsDone(slackWebhookPart) {
+		err := internal.SlackPostWebhook(ctx, s.webhook, &internal.ReqSlackWebhook{
+			Text:   internal.SlackEscape(text),
+			Blocks: blocks,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if file != "" {
+		err := internal.SlackUploadSnippet(ctx, s.apiURL, s.token, s.channel, fmt.Sprintf("%s.txt", n.MailID), title, file)
+		if err != nil {
+			return notifier.Partial([]string{slackWebhookPart}, err) // 消息已发送，重试时只上传文件
+		}
+	}
+
+	return nil
+}
+
+func truncateRunes(s string, max int) string {
+	r := []rune(s)
+	if max < 0 {
+		max = 0
+	}
+
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max])
+}
@@ -20,27 +20,6 @@ func SNDataBase(mailID string, subject string, content string, t time.Time) erro
 	return nil
 }
 
-func SNWechatRobot(msg string) (string, error) {
-	internal.WeChatRobotLock.Lock()
-	defer internal.WeChatRobotLock.Unlock()
-
-	return internal.WechatRobotSystemNotifyToSelf(msg)
-}
-
-func SNEmail(subject string, msg string, t time.Time) (smtpID string, err error) {
-	if subject != "" {
-		smtpID, err = internal.EmailSendToSelf(fmt.Sprintf("系统通知：%s", subject), msg, t)
-	} else {
-		return smtpID, &internal.SendError{
-			Code:    -1,
-			Message: "subject 为空",
-			Err:     nil,
-		}
-	}
-
-	if err != nil {
-		return smtpID, err
-	}
-
-	return smtpID, nil
+func SNSubject(subject string) string {
+	return fmt.Sprintf("系统通知：%s", subject)
 }
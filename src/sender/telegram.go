@@ -0,0 +1,90 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/sender/internal"
+	"strings"
+)
+
+const NotifierTelegram = "telegram"
+
+type telegramNotifier struct {
+	apiURL  string
+	token   string
+	chatIDs []string
+}
+
+func init() {
+	notifier.Register(NotifierTelegram, newTelegramNotifier)
+}
+
+func newTelegramNotifier(opt *flagparser.SiteOptions) (notifier.Notifier, error) {
+	if opt.TelegramToken == "" {
+		return nil, notifier.ErrNotConfigured
+	}
+
+	chatIDs := make([]string, 0, 5)
+	for _, id := range strings.Split(opt.TelegramChatID, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			chatIDs = append(chatIDs, id)
+		}
+	}
+
+	if len(chatIDs) == 0 {
+		return nil, fmt.Errorf("telegram chat id is empty")
+	}
+
+	return &telegramNotifier{
+		apiURL:  opt.TelegramAPIURL,
+		token:   opt.TelegramToken,
+		chatIDs: chatIDs,
+	}, nil
+}
+
+func (*telegramNotifier) Name() string {
+	return NotifierTelegram
+}
+
+func (*telegramNotifier) Capability() notifier.Capability {
+	return notifier.Capability{
+		MaxLength:     4096,
+		LengthInRunes: true,
+		SupportFile:   true,
+	}
+}
+
+func (t *telegramNotifier) Send(ctx context.Context, n notifier.Notification) error {
+	text, file := n.Fit(t.Capability())
+
+	var errs []error
+	var done []string
+	for _, chatID := range t.chatIDs {
+		// 消息和文件分别记录，重试时跳过已经收到的部分
+		textPart := "chat:" + chatID + ":text"
+		filePart := "chat:" + chatID + ":file"
+
+		if !n.IsDone(textPart) {
+			err := internal.TelegramSendMessage(ctx, t.apiURL, t.token, chatID, text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+				continue // 消息发送成功后再发送文件
+			}
+			done = append(done, textPart)
+		}
+
+		if file != "" && !n.IsDone(filePart) {
+			err := internal.TelegramSendDocument(ctx, t.apiURL, t.token, chatID, fmt.Sprintf("%s.txt", n.MailID), file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("chat %s file: %w", chatID, err))
+				continue
+			}
+			done = append(done, filePart)
+		}
+	}
+
+	return notifier.Partial(done, errors.Join(errs...))
+}
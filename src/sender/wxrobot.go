@@ -0,0 +1,89 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
+	"github.com/SongZihuan/anonymous-message/src/sender/internal"
+)
+
+const NotifierWxRobot = "wxrobot"
+
+type wxRobotNotifier struct {
+	webhook string
+}
+
+func init() {
+	notifier.Register(NotifierWxRobot, newWxRobotNotifier)
+}
+
+func newWxRobotNotifier(opt *flagparser.SiteOptions) (notifier.Notifier, error) {
+	if opt.Webhook == "" {
+		return nil, notifier.ErrNotConfigured
+	}
+
+	return &wxRobotNotifier{
+		webhook: opt.Webhook,
+	}, nil
+}
+
+func (*wxRobotNotifier) Name() string {
+	return NotifierWxRobot
+}
+
+func (*wxRobotNotifier) Capability() notifier.Capability {
+	return notifier.Capability{
+		MaxLength:   2040,
+		SupportFile: true,
+	}
+}
+
+func (w *wxRobotNotifier) Send(ctx context.Context, n notifier.Notification) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	internal.WeChatRobotLock.Lock()
+	defer internal.WeChatRobotLock.Unlock()
+
+	var wxrobotID string
+
+	if n.Type == database.MsgTypeSystem {
+		// 系统通知需要@所有人，且不使用文件
+		wxrobotID, err = internal.WechatRobotSystemNotifyToSelf(w.webhook, n.Text())
+	} else {
+		text, file := n.Fit(w.Capability())
+
+		wxrobotID, err = internal.WechatRobotToSelf(w.webhook, text)
+		if err == nil && file != "" {
+			wxrobotID, _, err = internal.WechatRobotFileToSelf(w.webhook, file, wxrobotID)
+		}
+
+		if err == nil {
+			// 附件发送失败不影响通知本身，避免重试时重复发送消息
+			for _, a := range n.Attachments {
+				if a.Path == "" {
+					continue
+				}
+
+				_err := internal.WechatRobotAttachmentToSelf(w.webhook, a.Name, a.Path)
+				if _err != nil {
+					fmt.Printf("企业微信发送附件 %s 出现错误: %s\n", a.Name, _err.Error())
+				}
+			}
+		}
+	}
+
+	switch n.Type {
+	case database.MsgTypeWebsite:
+		_ = database.UpdateAMWxRobotSendMsg(n.MailID, wxrobotID)
+	case database.MsgTypeEmail:
+		_ = database.UpdateIMAPWxRobotSendMsg(n.MailID, wxrobotID)
+	case database.MsgTypeSystem:
+		_ = database.UpdateSNWxRobotSendMsg(n.MailID, wxrobotID)
+	}
+
+	return err
+}
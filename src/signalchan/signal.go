@@ -7,7 +7,10 @@ import (
 	"syscall"
 )
 
-var SignalChan = make(chan os.Signal)
+var SignalChan = make(chan os.Signal, 1)
+
+// ReloadChan 收到 SIGHUP 时重新加载配置
+var ReloadChan = make(chan os.Signal, 1)
 
 func InitSignal() (err error) {
 	defer func() {
@@ -18,10 +21,13 @@ func InitSignal() (err error) {
 	}()
 
 	signal.Notify(SignalChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(ReloadChan, syscall.SIGHUP)
 	return nil
 }
 
 func CloseSignal() {
 	signal.Stop(SignalChan)
+	signal.Stop(ReloadChan)
 	close(SignalChan)
+	close(ReloadChan)
 }
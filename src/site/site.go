@@ -0,0 +1,295 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package site
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"github.com/SongZihuan/anonymous-message/src/utils"
+	"net/mail"
+	"strings"
+	"sync/atomic"
+)
+
+// Site 一个站点，默认站点的ID为空
+type Site struct {
+	ID     string
+	Name   string
+	WebURL string
+
+	Origins []string // 为空时：默认站点允许所有Origin，其他站点沿用默认站点的规则
+	Hosts   []string
+	Refers  []string
+
+	NoticeAddressList []*mail.Address
+	NoticeAddress     map[string]*mail.Address
+	RecipientAddress  map[string]*mail.Address
+}
+
+func (s *Site) IsDefault() bool {
+	return s.ID == flagparser.DefaultSiteID
+}
+
+// Address 站点发信使用的地址（SMTP账号，名称为站点名称）
+func (s *Site) Address() *mail.Address {
+	return &mail.Address{
+		Name:    s.Name,
+		Address: flagparser.SMTPUser,
+	}
+}
+
+// IsNoticeAddress 地址是否在站点的通知地址列表中（不区分大小写）
+func (s *Site) IsNoticeAddress(address string) bool {
+	for _, addr := range s.NoticeAddressList {
+		if strings.EqualFold(addr.Address, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// Label 日志和通知中显示的站点名称
+func (s *Site) Label() string {
+	if s.IsDefault() {
+		return s.Name
+	}
+	return fmt.Sprintf("%s（%s）", s.Name, s.ID)
+}
+
+func (s *Site) hasOrigin(origin string) bool {
+	for _, o := range s.Origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+var sites atomic.Pointer[[]*Site]
+
+// InitSite 根据默认参数和配置文件中的站点创建站点，全部创建成功后才会替换
+func InitSite() error {
+	options, err := flagparser.AllSiteOptions()
+	if err != nil {
+		return err
+	}
+
+	res := make([]*Site, 0, len(options))
+	for _, opt := range options {
+		s, err := newSite(opt)
+		if err != nil {
+			if opt.IsDefault() {
+				return err
+			}
+			return fmt.Errorf("site %s: %s", opt.ID, err.Error())
+		}
+		res = append(res, s)
+	}
+
+	sites.Store(&res)
+	return nil
+}
+
+func newSite(opt *flagparser.SiteOptions) (*Site, error) {
+	res := &Site{
+		ID:     opt.ID,
+		Name:   opt.Name,
+		WebURL: opt.WebURL,
+		Origins: splitList(opt.Origin, func(s string) string {
+			if s == "*" {
+				return s
+			}
+			return utils.OriginClear(s)
+		}),
+		Hosts:  splitList(opt.Host, strings.ToLower),
+		Refers: splitList(opt.Refer, nil),
+	}
+
+	var err error
+	res.NoticeAddressList, err = mail.ParseAddressList(opt.NoticeList)
+	if err != nil {
+		return nil, fmt.Errorf("parser notice email address list fail: %s", err.Error())
+	}
+
+	if len(res.NoticeAddressList) == 0 {
+		return nil, fmt.Errorf("notice address list is empty")
+	}
+
+	res.NoticeAddress = make(map[string]*mail.Address, len(res.NoticeAddressList))
+	for _, address := range res.NoticeAddressList {
+		res.NoticeAddress[utils.NormalizeEmailAddress(address.Address)] = address
+	}
+
+	res.RecipientAddress = make(map[string]*mail.Address, 5)
+
+	if opt.IsDefault() {
+		if flagparser.IMAPUser != "" {
+			res.RecipientAddress[utils.NormalizeEmailAddress(flagparser.IMAPUser)] = &mail.Address{
+				Name:    opt.Name,
+				Address: flagparser.IMAPUser,
+			}
+		}
+
+		if flagparser.POP3User != "" {
+			res.RecipientAddress[utils.NormalizeEmailAddress(flagparser.POP3User)] = &mail.Address{
+				Name:    opt.Name,
+				Address: flagparser.POP3User,
+			}
+		}
+	}
+
+	for _, account := range flagparser.IMAPAccounts {
+		if account.SiteID == opt.ID {
+			res.RecipientAddress[utils.NormalizeEmailAddress(account.User)] = &mail.Address{
+				Name:    opt.Name,
+				Address: account.User,
+			}
+		}
+	}
+
+	if opt.RecipientList != "" {
+		recipientList, err := mail.ParseAddressList(opt.RecipientList)
+		if err != nil {
+			return nil, fmt.Errorf("parser recipient email address list fail: %s", err.Error())
+		}
+
+		for _, address := range recipientList {
+			res.RecipientAddress[utils.NormalizeEmailAddress(address.Address)] = address
+		}
+	}
+
+	return res, nil
+}
+
+func splitList(s string, clean func(string) string) []string {
+	res := make([]string, 0, 5)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if clean != nil {
+			item = clean(item)
+		}
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// List 所有站点，默认站点排在第一位
+func List() []*Site {
+	res := sites.Load()
+	if res == nil {
+		return nil
+	}
+	return *res
+}
+
+func Default() *Site {
+	list := List()
+	if len(list) == 0 {
+		return &Site{
+			Name: flagparser.Name,
+		}
+	}
+	return list[0]
+}
+
+// Get 根据ID获取站点，不存在时返回默认站点
+func Get(id string) *Site {
+	for _, s := range List() {
+		if s.ID == id {
+			return s
+		}
+	}
+	return Default()
+}
+
+// MatchRequest 根据 Origin、Host 和 refer 匹配网页留言所属的站点，依次匹配，都不匹配时返回默认站点
+// 只有没有设置 Origin 的站点可以通过 refer 匹配，避免其他网站通过 refer 冒充
+func MatchRequest(origin string, host string, refer string) *Site {
+	list := List()
+	host = strings.ToLower(host)
+
+	if origin != "" {
+		for _, s := range list[min(1, len(list)):] {
+			for _, o := range s.Origins {
+				if o == origin {
+					return s
+				}
+			}
+		}
+	}
+
+	if host != "" {
+		for _, s := range list[min(1, len(list)):] {
+			for _, h := range s.Hosts {
+				if h == host {
+					return s
+				}
+			}
+		}
+	}
+
+	if refer != "" {
+		for _, s := range list[min(1, len(list)):] {
+			if len(s.Origins) != 0 {
+				continue
+			}
+
+			for _, r := range s.Refers {
+				if r == refer {
+					return s
+				}
+			}
+		}
+	}
+
+	return Default()
+}
+
+// AllowOrigin 是否允许该Origin跨域：任一站点明确允许，或默认站点允许
+func AllowOrigin(origin string) bool {
+	list := List()
+
+	for _, s := range list[min(1, len(list)):] {
+		if origin != "" && s.hasOrigin(origin) {
+			return true
+		}
+	}
+
+	d := Default()
+	if len(d.Origins) == 0 {
+		return true
+	}
+	return origin != "" && d.hasOrigin(origin)
+}
+
+// AllowAllOrigin 是否不做跨域检查（默认站点没有设置 Origin）
+func AllowAllOrigin() bool {
+	return len(Default().Origins) == 0
+}
+
+// MatchRecipient 根据收件地址匹配邮件所属的站点，优先匹配非默认站点，域名不区分大小写
+func MatchRecipient(address string) (*Site, *mail.Address, bool) {
+	list := List()
+	address = utils.NormalizeEmailAddress(address)
+	if address == "" {
+		return Default(), nil, false
+	}
+
+	for i := len(list) - 1; i >= 0; i-- {
+		if rec, ok := list[i].RecipientAddress[address]; ok {
+			return list[i], rec, true
+		}
+	}
+
+	return Default(), nil, false
+}
+
+// IsRecipient 地址是否为任一站点的收件地址
+func IsRecipient(address string) bool {
+	_, _, ok := MatchRecipient(address)
+	return ok
+}
@@ -0,0 +1,44 @@
+package site
+
+import (
+	"github.com/SongZihuan/anonymous-message/src/flagparser"
+	"testing"
+)
+
+// 只设置了SMTP账号时，IMAP使用SMTP的账号，默认站点的收件地址为该账号
+func TestRecipientWithOnlySMTPUser(t *testing.T) {
+	flagparser.IMAPAddress = "imap.example.com:993"
+	flagparser.IMAPUser = ""
+	flagparser.IMAPPassword = ""
+	flagparser.SMTPUser = "support@example.com"
+	flagparser.SMTPPassword = "password"
+	flagparser.NoticeList = "admin@example.com"
+
+	flagparser.DefaultIMAPUser()
+
+	err := InitSite()
+	if err != nil {
+		t.Fatalf("init site: %s", err.Error())
+	}
+
+	s, rec, ok := MatchRecipient("support@example.com")
+	if !ok || rec == nil || !s.IsDefault() {
+		t.Fatalf("support@example.com should be a recipient of the default site")
+	}
+
+	if !IsRecipient("support@example.com") {
+		t.Fatalf("support@example.com should be a recipient")
+	}
+
+	if !IsRecipient("support@EXAMPLE.com") {
+		t.Fatalf("domain of the recipient should be case-insensitive")
+	}
+
+	if IsRecipient("") {
+		t.Fatalf("empty address should not be a recipient")
+	}
+
+	if flagparser.IMAPPassword != "password" {
+		t.Fatalf("imap password should default to the smtp password")
+	}
+}
@@ -4,10 +4,9 @@ import (
 	"fmt"
 	"github.com/SongZihuan/anonymous-message/src/database"
 	"github.com/SongZihuan/anonymous-message/src/flagparser"
-	"github.com/SongZihuan/anonymous-message/src/messageutils"
+	"github.com/SongZihuan/anonymous-message/src/notifier"
 	"github.com/SongZihuan/anonymous-message/src/sender"
 	"github.com/SongZihuan/anonymous-message/src/utils"
-	"strings"
 	"time"
 )
 
@@ -37,61 +36,34 @@ func SendNotify(notifySubject string, notifyContent string) {
 	}()
 
 	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				if _err, ok := r.(error); ok {
-					fmt.Printf("企业微信发送消息出现致命错误: %s\n", _err.Error())
-				} else {
-					fmt.Printf("企业微信发送消息出现致命错误（非error）: %v\n", r)
-				}
-			}
-		}()
-
-		var msgBuilder strings.Builder
-		// 标准头部
-		messageutils.WriteSNMessageStdHeader(&msgBuilder, database.MsgTypeSystem, notifyMailID, now)
-
-		msgBuilder.WriteString(fmt.Sprintf("主题: %s\n", notifySubject))
-		msgBuilder.WriteString(fmt.Sprintf("日期: %s %s\n", now.Format("2006-01-02 15:04:05"), now.Location().String()))
-		msgBuilder.WriteString(fmt.Sprintf("消息长度：%d\n", len(notifyContent)))
-		msgBuilder.WriteString(fmt.Sprintf("---消息开始---\n%s\n---消息结束---", notifyContent))
-		messageStr := msgBuilder.String()
+		<-initchan
 
-		wxrobotID, err := sender.SNWechatRobot(messageStr)
-		if err != nil {
-			fmt.Printf("企业微信发送消息出现错误: %s\n", err.Error())
-			return
+		fields := []notifier.Field{
+			{Name: "主题", Value: notifySubject},
+			{Name: "日期", Value: fmt.Sprintf("%s %s", now.Format("2006-01-02 15:04:05"), now.Location().String())},
 		}
 
-		_ = database.UpdateSNWxRobotSendMsg(notifyMailID, wxrobotID)
-	}()
-
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				if _err, ok := r.(error); ok {
-					fmt.Printf("邮件发送消息出现致命错误: %s\n", _err.Error())
-				} else {
-					fmt.Printf("邮件发送消息出现致命错误（非error）: %v\n", r)
-				}
+		if database.Ready() {
+			unhandled, err := database.CountUnhandledMail()
+			if err != nil {
+				fmt.Printf("统计未处理信件出现错误: %s\n", err.Error())
+			} else {
+				fields = append(fields, notifier.Field{Name: "未处理信件", Value: fmt.Sprintf("%d 封", unhandled)})
 			}
-		}()
-
-		var msgBuilder strings.Builder
-		// 标准头部
-		messageutils.WriteSNMessageStdHeader(&msgBuilder, database.MsgTypeSystem, notifyMailID, now)
-
-		msgBuilder.WriteString(fmt.Sprintf("主题: %s\n", notifySubject))
-		msgBuilder.WriteString(fmt.Sprintf("日期: %s %s\n", now.Format("2006-01-02 15:04:05"), now.Location().String()))
-		msgBuilder.WriteString(fmt.Sprintf("消息长度：%d\n", len(notifyContent)))
-		msgBuilder.WriteString(fmt.Sprintf("---消息开始---\n%s\n---消息结束---", notifyContent))
-		messageStr := msgBuilder.String()
-
-		smtpID, err := sender.SNEmail(notifySubject, messageStr, now)
-		if err != nil {
-			fmt.Printf("邮件发送消息出现错误: %s\n", err.Error())
 		}
 
-		_ = database.UpdateSNEmailSendMsg(notifyMailID, smtpID)
+		notifier.SendAll(notifier.Notification{
+			Type:    database.MsgTypeSystem,
+			MailID:  notifyMailID,
+			Time:    now,
+			Subject: sender.SNSubject(notifySubject),
+			Fields:  fields,
+			Content: notifyContent,
+			Meta: notifier.Meta{
+				Subject:     notifySubject,
+				NameSafe:    true,
+				ContentSafe: true,
+			},
+		})
 	}()
 }
@@ -0,0 +1,66 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ticket
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"strings"
+	"time"
+)
+
+// Prefix 查询令牌的前缀
+const Prefix = "tk_"
+
+var ErrInvalidTicket = fmt.Errorf("invalid ticket")
+
+// New 为网页留言创建查询令牌，数据库中只保存其哈希，令牌明文只返回给留言人
+func New(mailID string) (string, error) {
+	if !database.Ready() {
+		return "", fmt.Errorf("sqlite is not enabled")
+	}
+
+	buf := make([]byte, 24)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	token := Prefix + hex.EncodeToString(buf)
+
+	err = database.SaveTicket(Hash(token), mailID, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Find 返回令牌对应的信件ID
+func Find(token string) (string, error) {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, Prefix) {
+		return "", ErrInvalidTicket
+	}
+
+	record, err := database.FindTicket(Hash(token))
+	if err != nil && errors.Is(err, database.ErrNotFound) {
+		return "", ErrInvalidTicket
+	} else if err != nil {
+		return "", err
+	}
+
+	return record.MailID, nil
+}
+
+func Hash(token string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(token))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
@@ -190,3 +190,15 @@ func SplitEmailAddress(email string) (string, string, error) {
 	domainPart := email[atIndex+1:]
 	return localPart, domainPart, nil
 }
+
+// NormalizeEmailAddress 去掉首尾空白并把域名部分转为小写，用于比较收件地址（本地部分保持原样）
+func NormalizeEmailAddress(email string) string {
+	email = strings.TrimSpace(email)
+
+	atIndex := strings.LastIndex(email, "@")
+	if atIndex == -1 {
+		return email
+	}
+
+	return email[:atIndex+1] + strings.ToLower(email[atIndex+1:])
+}
@@ -27,3 +27,10 @@ func GetSNMailID(subject string, content string, t time.Time) string {
 	hasher.Write([]byte(text))
 	return hex.EncodeToString(hasher.Sum(nil))
 }
+
+func GetReplyID(mailID string, to string, subject string, content string, t time.Time) string {
+	text := fmt.Sprintf("REPLY-%s\n%s\n%s\n%s\n%d", mailID, to, subject, content, t.UnixNano())
+	hasher := sha256.New()
+	hasher.Write([]byte(text))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
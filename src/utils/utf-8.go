@@ -51,3 +51,15 @@ func ChangeDisplaySafeUTF8(s string) (res string, safe bool) {
 	}
 	return string(resRune), true
 }
+
+// TruncateUTF8 截断到最多 max 个字节，不会截断在一个字符的中间
+func TruncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 AnonymousMessage Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package workflow
+
+import (
+	"fmt"
+	"github.com/SongZihuan/anonymous-message/src/database"
+	"strings"
+	"time"
+)
+
+var statusName = map[database.MailStatus]string{
+	database.MailStatusNew:      "新信件",
+	database.MailStatusRead:     "已读",
+	database.MailStatusReplied:  "已回复",
+	database.MailStatusArchived: "已归档",
+	database.MailStatusSpam:     "垃圾信件",
+}
+
+// AllStatus 所有状态，按处理流程排序
+var AllStatus = []database.MailStatus{
+	database.MailStatusNew,
+	database.MailStatusRead,
+	database.MailStatusReplied,
+	database.MailStatusArchived,
+	database.MailStatusSpam,
+}
+
+func ParseStatus(s string) (database.MailStatus, error) {
+	status := database.MailStatus(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := statusName[status]; !ok {
+		return "", fmt.Errorf("unknown status: %s (new, read, replied, archived or spam)", s)
+	}
+	return status, nil
+}
+
+// ParseStatusList 解析以英文逗号分隔的状态列表
+func ParseStatusList(s string) ([]database.MailStatus, error) {
+	var res []database.MailStatus
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		status, err := ParseStatus(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+func StatusName(status database.MailStatus) string {
+	if name, ok := statusName[status]; ok {
+		return name
+	}
+	return string(status)
+}
+
+// SetStatus 修改信件的处理状态，信件不存在时返回 database.ErrNotFound，状态没有变化时返回 false
+func SetStatus(mailID string, status database.MailStatus, operator string, note string) (bool, error) {
+	_, err := database.FindMailRecord(mailID)
+	if err != nil {
+		return false, err
+	}
+
+	return database.UpdateMailStatus(mailID, status, operator, note, time.Now())
+}
+
+// MarkRead 新信件标记为已读，其他状态不变
+func MarkRead(mailID string, operator string) (bool, error) {
+	state, err := database.FindMailState(mailID)
+	if err == nil && state.Status != database.MailStatusNew {
+		return false, nil
+	}
+
+	return SetStatus(mailID, database.MailStatusRead, operator, "")
+}
+
+// Assign 指派信件的处理人，assignee 为空表示取消指派
+func Assign(mailID string, assignee string, operator string, note string) (bool, error) {
+	assignee = strings.TrimSpace(assignee)
+	if len(assignee) > 40 {
+		return false, fmt.Errorf("assignee is too long")
+	}
+
+	_, err := database.FindMailRecord(mailID)
+	if err != nil {
+		return false, err
+	}
+
+	return database.UpdateMailAssignee(mailID, assignee, operator, note, time.Now())
+}